@@ -5,7 +5,11 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/muhammadardie/echo-cms/components/users"
 	DB "github.com/muhammadardie/echo-cms/db"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/trash"
 	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/rs/xid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
@@ -19,6 +23,7 @@ type Token struct {
 	ID           primitive.ObjectID `json:"_id"`
 	Username     string             `json:"username"`
 	Email        string             `json:"email"`
+	Role         string             `json:"role"`
 	AccessToken  string             `json:"access_token"`
 	RefreshToken string             `json:"refresh_token"`
 }
@@ -31,13 +36,13 @@ type Token struct {
 // @Accept  json
 // @Produce  json
 // @Param user body users.UserLogin true "Credentials to use"
-// @Success 200 {object} utils.HttpSuccess{data=string{_id=string,username=string,email=string,access_token=string,refresh_token=string}}
+// @Success 200 {object} utils.HttpSuccess{data=string{_id=string,username=string,email=string,role=string,access_token=string,refresh_token=string}}
 // @Failure 400 {object} utils.HttpError
 // @Failure 401 {object} utils.HttpError
+// @Failure 403 {object} utils.HttpError
 // @Failure 500 {object} utils.HttpError
 // @Router /login [post]
 func Login(c echo.Context) error {
-	ctx := context.Background()
 	user := new(users.UserLogin)
 
 	if err := c.Bind(user); err != nil {
@@ -49,15 +54,15 @@ func Login(c echo.Context) error {
 		return err
 	}
 
-	db, err := DB.Connect()
+	repo, err := repository.Open("users")
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	selector := bson.M{"email": user.Email}
+	selector := trash.Active(bson.M{"email": user.Email})
 	var dbUser users.Users
 
-	if err = db.Collection("users").FindOne(ctx, selector).Decode(&dbUser); err != nil {
+	if err = repo.FindOne(selector, &dbUser); err != nil {
 		return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
 	}
 
@@ -70,20 +75,30 @@ func Login(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, "Invalid password")
 	}
 
-	ts, err := CreateToken(dbUser.ID.Hex())
+	// accounts created before the verification have no flag, they may log in
+	if dbUser.EmailVerified != nil && !*dbUser.EmailVerified {
+		return echo.NewHTTPError(http.StatusForbidden, "Email is not verified")
+	}
+
+	ts, err := CreateToken(dbUser.ID.Hex(), dbUser.Role, xid.New().String())
 	if err != nil {
 		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
 	}
 
 	saveErr := CreateAuth(dbUser.ID.Hex(), ts)
 	if saveErr != nil {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, saveErr.Error())
+	}
+
+	if saveErr = SaveSession(c, dbUser.ID.Hex(), ts, nil); saveErr != nil {
+		return echo.NewHTTPError(http.StatusUnprocessableEntity, saveErr.Error())
 	}
 
 	tokens := &Token{
 		ID:           dbUser.ID,
 		Username:     dbUser.Username,
 		Email:        dbUser.Email,
+		Role:         dbUser.Role,
 		AccessToken:  ts.AccessToken,
 		RefreshToken: ts.RefreshToken,
 	}
@@ -108,14 +123,41 @@ func Logout(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
 	}
 
+	// the refresh token of the session goes with it, tokens issued
+	// before sessions only have the access one
 	delErr := DeleteAuth(au.AccessUuid)
+	if delErr == nil && au.SessionId != "" {
+		if err = tokens.End(au.UserId, au.SessionId); err != nil && err != tokens.ErrInvalid {
+			delErr = err
+		}
+	}
 	if delErr != nil { //if any goes wrong
-		return echo.NewHTTPError(http.StatusUnauthorized, err.Error())
+		return echo.NewHTTPError(http.StatusUnauthorized, delErr.Error())
 	}
 
 	return c.JSON(http.StatusOK, utils.NewSuccess("", "Successfully logged out"))
 }
 
+// FetchRole returns the role of the given user
+func FetchRole(userid string) (string, error) {
+	id, err := primitive.ObjectIDFromHex(userid)
+	if err != nil {
+		return "", err
+	}
+
+	repo, err := repository.Open("users")
+	if err != nil {
+		return "", err
+	}
+
+	var dbUser users.Users
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &dbUser); err != nil {
+		return "", err
+	}
+
+	return dbUser.Role, nil
+}
+
 func CreateAuth(userid string, td *TokenDetails) error {
 	client := DB.InitRedis()
 	at := time.Unix(td.AtExpires, 0) //converting Unix to UTC(to Time object)
@@ -145,9 +187,9 @@ func FetchAuth(authD *AccessDetails) (string, error) {
 	return userid, nil
 }
 
-func DeleteAuth(accessUuid string) error {
+func DeleteAuth(uuids ...string) error {
 	client := DB.InitRedis()
-	err := client.Del(ctx, accessUuid).Err()
+	err := client.Del(ctx, uuids...).Err()
 	if err != nil {
 		return err
 	}
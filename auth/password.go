@@ -0,0 +1,176 @@
+package auth
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/users"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// mail with the link to reset the password of a user
+const templateReset = "password-reset"
+
+// kind of the tokens of the reset links
+const resetKind = "password-reset"
+
+const (
+	// how long the link of a reset mail can be used
+	resetTTL = time.Hour
+	// requests per minute from an IP when PASSWORD_RESET_RATE_LIMIT is not set
+	defaultResetRateLimit = 3
+)
+
+type ForgotPassword struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" validate:"required"`
+	Password string `json:"password" validate:"required"`
+}
+
+func init() {
+	mail.Register(templateReset, "Sent to a user who asked to reset the password, the link works once within an hour",
+		`Reset your password`,
+		"Hello {{.Username}},\n\nSomeone asked to reset the password of your account. Follow the link within an hour to choose a new one:\n\n{{.Link}}\n\nIgnore this mail if it wasn't you, the password stays the same.\n",
+		`<p>Hello {{.Username}},</p><p>Someone asked to reset the password of your account. Follow the link within an hour to choose a new one:</p><p><a href="{{.Link}}">{{.Link}}</a></p><p>Ignore this mail if it wasn't you, the password stays the same.</p>`,
+		mail.Data{"Username": "jane", "Link": "https://example.com/reset-password?token=0123456789abcdef"})
+}
+
+// ResetRateLimit limits the reset requests sent from an IP, configured by
+// PASSWORD_RESET_RATE_LIMIT as a number of requests per minute
+func ResetRateLimit() echo.MiddlewareFunc {
+	return utils.RateLimit("PASSWORD_RESET_RATE_LIMIT", defaultResetRateLimit, "Too many requests, try again in a minute")
+}
+
+// RequestReset godoc
+// @Summary Ask to reset the password
+// @Description Mail a link to reset the password to the user of the email, the link opens PASSWORD_RESET_URL with the token and works once within an hour. The answer is the same whether the user exists or not.
+// @ID forgot-password
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param user body ForgotPassword true "Email of the user"
+// @Success 202 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Failure 429 {object} utils.HttpError
+// @Router /password/forgot [post]
+func RequestReset(c echo.Context) error {
+	request := new(ForgotPassword)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	request.Email = strings.TrimSpace(request.Email)
+	if err := utils.Validate(c, request); err != nil {
+		return err
+	}
+
+	accepted := utils.NewSuccess(nil, "A link to reset the password is sent if the email belongs to a user")
+	accepted.Code = http.StatusAccepted
+
+	repo, err := repository.Open("users")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	/* nobody learns from the answer which emails have an account */
+	var user users.Users
+	if err = repo.FindOne(trash.Active(bson.M{"email": request.Email}), &user); err != nil {
+		return c.JSON(http.StatusAccepted, accepted)
+	}
+
+	token, err := tokens.Issue(resetKind, user.ID.Hex(), resetTTL)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to create token")
+	}
+
+	data := mail.Data{"Username": user.Username, "Link": mail.Secret(os.Getenv("PASSWORD_RESET_URL") + "?token=" + token)}
+	if err = mail.Send(templateReset, []string{user.Email}, "", data); err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusAccepted, accepted)
+}
+
+// Reset godoc
+// @Summary Reset the password
+// @Description Choose a new password with the token of a reset mail, the token can't be used again. Every session of the user ends.
+// @ID reset-password
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Param user body ResetPassword true "Token of the mail and new password"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Failure 429 {object} utils.HttpError
+// @Router /password/reset [post]
+func Reset(c echo.Context) error {
+	request := new(ResetPassword)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	if err := utils.Validate(c, request); err != nil {
+		return err
+	}
+
+	invalid := echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired token")
+
+	userid, err := tokens.Redeem(resetKind, request.Token)
+	if err == tokens.ErrInvalid {
+		return invalid
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	id, err := primitive.ObjectIDFromHex(userid)
+	if err != nil {
+		return invalid
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(request.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+	}
+
+	repo, err := repository.Open("users")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	before := audits.Snapshot("users", id)
+	update := version.Bump(bson.M{"$set": bson.M{"password": string(hashedPassword), "updated_at": time.Now()}})
+	result, err := repo.Update(trash.Active(bson.M{"_id": id}), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update password")
+	}
+	if result.MatchedCount == 0 {
+		return invalid
+	}
+
+	audits.Record(c, audits.ActionUpdate, "users", id, before)
+
+	// whoever knew the old password is logged out
+	if err = tokens.Revoke(userid); err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(nil, "Password changed"))
+}
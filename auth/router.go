@@ -0,0 +1,12 @@
+package auth
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func SessionsRegister(g *echo.Group) {
+	sessions := g.Group("/sessions")
+	sessions.GET("", GetSessions)
+	sessions.DELETE("", DestroySessions)
+	sessions.DELETE("/:id", DestroySession)
+}
@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/utils"
+)
+
+// marks of the user agent with the name they stand for, the first one
+// found names the browser or the system of the device
+var (
+	browsers = [][2]string{{"Edg/", "Edge"}, {"OPR/", "Opera"}, {"Firefox/", "Firefox"}, {"Chrome/", "Chrome"}, {"Safari/", "Safari"}}
+	systems  = [][2]string{{"Android", "Android"}, {"iPhone", "iPhone"}, {"iPad", "iPad"}, {"Windows", "Windows"}, {"Mac OS X", "macOS"}, {"Linux", "Linux"}}
+)
+
+// SaveSession keeps the session of the tokens with the device it is used
+// from, the previous one when the tokens are refreshed
+func SaveSession(c echo.Context, userid string, td *TokenDetails, previous *tokens.Session) error {
+	now := time.Now()
+	session := &tokens.Session{
+		ID:         td.SessionId,
+		UserId:     userid,
+		IP:         c.RealIP(),
+		UserAgent:  c.Request().UserAgent(),
+		Device:     device(c.Request().UserAgent()),
+		CreatedAt:  now,
+		LastUsedAt: now,
+		ExpiresAt:  time.Unix(td.RtExpires, 0),
+		Keys:       []string{td.TokenUuid, td.RefreshUuid},
+	}
+	if previous != nil {
+		session.CreatedAt = previous.CreatedAt
+	}
+
+	return tokens.Save(session)
+}
+
+// device names the browser and system of the user agent, e.g. "Firefox on
+// Windows", it is empty for the clients that tell neither
+func device(userAgent string) string {
+	var names []string
+	for _, marks := range [][][2]string{browsers, systems} {
+		for _, mark := range marks {
+			if strings.Contains(userAgent, mark[0]) {
+				names = append(names, mark[1])
+				break
+			}
+		}
+	}
+
+	return strings.Join(names, " on ")
+}
+
+// GetSessions godoc
+// @Summary Get my sessions
+// @Description Get the sessions the authenticated user is logged in with, the newest first. Current tells the one of the request. The last use is the login or the last refresh of the tokens.
+// @ID get-sessions
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Success 200 {object} utils.HttpSuccess{data=[]tokens.Session}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /sessions [get]
+func GetSessions(c echo.Context) error {
+	actor := rbac.GetActor(c)
+
+	sessions, err := tokens.Sessions(actor.UserId)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == actor.SessionId
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(sessions, ""))
+}
+
+// DestroySession godoc
+// @Summary Revoke one of my sessions
+// @Description Log the authenticated user out of a session, its access and refresh tokens stop working
+// @ID delete-session
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the session"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /sessions/{id} [delete]
+func DestroySession(c echo.Context) error {
+	err := tokens.End(rbac.GetActor(c).UserId, c.Param("id"))
+	if err == tokens.ErrInvalid {
+		return echo.NewHTTPError(http.StatusNotFound, "Session not found")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(nil, "Session revoked"))
+}
+
+// DestroySessions godoc
+// @Summary Revoke all my sessions
+// @Description Log the authenticated user out of every session, the one of the request too
+// @ID delete-sessions
+// @Tags Auth
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /sessions [delete]
+func DestroySessions(c echo.Context) error {
+	if err := tokens.Revoke(rbac.GetActor(c).UserId); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(nil, "Every session revoked"))
+}
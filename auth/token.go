@@ -9,6 +9,8 @@ import (
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/labstack/echo/v4"
+	DB "github.com/muhammadardie/echo-cms/db"
+	"github.com/muhammadardie/echo-cms/tokens"
 	"github.com/rs/xid"
 )
 
@@ -19,6 +21,7 @@ type TokenDetails struct {
 	RefreshToken string
 	TokenUuid    string
 	RefreshUuid  string
+	SessionId    string
 	AtExpires    int64
 	RtExpires    int64
 }
@@ -26,10 +29,12 @@ type TokenDetails struct {
 type AccessDetails struct {
 	AccessUuid string
 	UserId     string
+	Role       string
+	SessionId  string
 }
 
-func CreateToken(userId string) (*TokenDetails, error) {
-	td := &TokenDetails{}
+func CreateToken(userId string, role string, sessionId string) (*TokenDetails, error) {
+	td := &TokenDetails{SessionId: sessionId}
 	td.AtExpires = time.Now().Add(time.Minute * 30).Unix() //expires after 30 min
 	td.TokenUuid = xid.New().String()
 
@@ -38,6 +43,8 @@ func CreateToken(userId string) (*TokenDetails, error) {
 	atClaims := jwt.MapClaims{}
 	atClaims["access_uuid"] = td.TokenUuid
 	atClaims["user_id"] = userId
+	atClaims["role"] = role
+	atClaims["session_id"] = sessionId
 	atClaims["exp"] = td.AtExpires
 	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
 	td.AccessToken, err = at.SignedString([]byte(os.Getenv("ACCESS_SECRET")))
@@ -52,6 +59,7 @@ func CreateToken(userId string) (*TokenDetails, error) {
 	rtClaims := jwt.MapClaims{}
 	rtClaims["refresh_uuid"] = td.RefreshUuid
 	rtClaims["user_id"] = userId
+	rtClaims["session_id"] = sessionId
 	rtClaims["exp"] = td.RtExpires
 	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
 
@@ -113,10 +121,14 @@ func ExtractTokenMetadata(c echo.Context) (*AccessDetails, error) {
 	if token.Valid {
 		accessUuid := claims["access_uuid"].(string)
 		userId := claims["user_id"].(string)
+		role, _ := claims["role"].(string)            // tokens issued before roles have none, they grant nothing
+		sessionId, _ := claims["session_id"].(string) // nor before sessions
 
 		return &AccessDetails{
 			AccessUuid: accessUuid,
 			UserId:     userId,
+			Role:       role,
+			SessionId:  sessionId,
 		}, nil
 	}
 
@@ -153,13 +165,29 @@ func Refresh(c echo.Context) error {
 		refreshUuid := claims["refresh_uuid"].(string) //convert the interface to string
 		userId := claims["user_id"].(string)
 
-		//Delete the previous Refresh Token
-		delErr := DeleteAuth(refreshUuid)
+		//Delete the previous Refresh Token, the one of a revoked session is gone already
+		deleted, delErr := DB.InitRedis().Del(ctx, refreshUuid).Result()
 		if delErr != nil { //if any goes wrong
 			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Failed refresh token")
 		}
+		if deleted == 0 {
+			return echo.NewHTTPError(http.StatusUnauthorized, "Refresh token expired")
+		}
+		//Carry on the session, tokens issued before sessions start one
+		sessionId, _ := claims["session_id"].(string)
+		previous, _ := tokens.Find(sessionId)
+		if previous == nil {
+			sessionId = xid.New().String()
+		} else if err := DeleteAuth(previous.Keys...); err != nil {
+			return echo.NewHTTPError(http.StatusUnprocessableEntity, "Failed refresh token")
+		}
+		//Load the current role, it may have changed since the last login
+		role, roleErr := FetchRole(userId)
+		if roleErr != nil {
+			return echo.NewHTTPError(http.StatusUnauthorized, "User not found")
+		}
 		//Create new pairs of refresh and access tokens
-		ts, createErr := CreateToken(userId)
+		ts, createErr := CreateToken(userId, role, sessionId)
 		if createErr != nil {
 			return echo.NewHTTPError(http.StatusForbidden, createErr.Error())
 		}
@@ -168,6 +196,9 @@ func Refresh(c echo.Context) error {
 		if saveErr != nil {
 			return echo.NewHTTPError(http.StatusForbidden, saveErr.Error())
 		}
+		if saveErr = SaveSession(c, userId, ts, previous); saveErr != nil {
+			return echo.NewHTTPError(http.StatusForbidden, saveErr.Error())
+		}
 		tokens := map[string]string{
 			"access_token":  ts.AccessToken,
 			"refresh_token": ts.RefreshToken,
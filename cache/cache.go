@@ -0,0 +1,248 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	DB "github.com/muhammadardie/echo-cms/db"
+)
+
+var ctx = context.Background()
+
+const keyPrefix = "cache:"
+
+const (
+	headerETag            = "ETag"
+	headerIfNoneMatch     = "If-None-Match"
+	headerCache           = "X-Cache"
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+	headerVary            = "Vary"
+)
+
+// responses are kept for 5 minutes unless CACHE_TTL says otherwise,
+// it is also the longest delay before a scheduled blog post shows up
+const defaultTTL = 5 * time.Minute
+
+// collections whose cached responses depend on every other collection
+var dependents = []string{"search"}
+
+// collections whose cached responses depend on other collections, by the
+// collection they depend on
+var related = map[string][]string{}
+var relatedMutex sync.RWMutex
+
+// Depend drops the cached responses of the collection whenever one of the
+// others changes, e.g. the blogs showing the names of their categories
+func Depend(collection string, on ...string) {
+	relatedMutex.Lock()
+	defer relatedMutex.Unlock()
+
+	for _, other := range on {
+		related[other] = append(related[other], collection)
+	}
+}
+
+// entry is a cached response
+type entry struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType"`
+	Language    string `json:"language,omitempty"`
+	ETag        string `json:"etag"`
+	Body        []byte `json:"body"`
+}
+
+// recorder holds the response back until it is cached
+type recorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+// TTL returns how long responses are cached, configured in seconds by CACHE_TTL, 0 disables the cache
+func TTL() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("CACHE_TTL")); err == nil && value >= 0 {
+		return time.Duration(value) * time.Second
+	}
+
+	return defaultTTL
+}
+
+// Middleware caches the successful GET responses in Redis, keyed by
+// collection, path, query and Accept-Language since the texts depend on
+// the locale. Every response carries an ETag so clients sending a
+// matching If-None-Match get a 304 Not Modified.
+func Middleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		ttl := TTL()
+		if c.Request().Method != http.MethodGet || ttl == 0 {
+			return next(c)
+		}
+
+		client := DB.InitRedis()
+		key := keyPrefix + collection(c) + ":" + c.Request().URL.Path + "?" + c.QueryParams().Encode() +
+			"#" + strings.ToLower(strings.ReplaceAll(c.Request().Header.Get(headerAcceptLanguage), " ", ""))
+
+		if cached, err := client.Get(ctx, key).Bytes(); err == nil {
+			var hit entry
+			if err = json.Unmarshal(cached, &hit); err == nil {
+				c.Response().Header().Set(headerCache, "HIT")
+				return write(c, &hit)
+			}
+		}
+
+		original := c.Response().Writer
+		rec := &recorder{ResponseWriter: original, status: http.StatusOK}
+		c.Response().Writer = rec
+
+		err := next(c)
+		c.Response().Writer = original
+		if err != nil {
+			return err
+		}
+
+		// nothing reached the client yet, let the response be written again
+		c.Response().Committed = false
+		c.Response().Size = 0
+
+		sum := sha1.Sum(rec.body.Bytes())
+		miss := &entry{
+			Status:      rec.status,
+			ContentType: c.Response().Header().Get(echo.HeaderContentType),
+			Language:    c.Response().Header().Get(headerContentLanguage),
+			ETag:        `"` + hex.EncodeToString(sum[:]) + `"`,
+			Body:        rec.body.Bytes(),
+		}
+
+		if miss.Status == http.StatusOK {
+			if value, err := json.Marshal(miss); err == nil {
+				if err = client.Set(ctx, key, value, ttl).Err(); err != nil {
+					c.Logger().Error(err)
+				}
+			}
+		}
+
+		c.Response().Header().Set(headerCache, "MISS")
+		return write(c, miss)
+	}
+}
+
+// Invalidator drops the cached responses of a collection once a write
+// request on it succeeded
+func Invalidator(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		err := next(c)
+		if err != nil || c.Request().Method == http.MethodGet || c.Response().Status >= http.StatusBadRequest {
+			return err
+		}
+
+		name := collection(c)
+
+		relatedMutex.RLock()
+		names := append(append([]string{name}, dependents...), related[name]...)
+		relatedMutex.RUnlock()
+
+		if err := Invalidate(names...); err != nil {
+			c.Logger().Error(err)
+		}
+
+		return nil
+	}
+}
+
+// Invalidate drops every cached response of the given collections
+func Invalidate(collections ...string) error {
+	client := DB.InitRedis()
+
+	for _, name := range collections {
+		iter := client.Scan(ctx, 0, keyPrefix+name+":*", 100).Iterator()
+		keys := make([]string, 0)
+
+		for iter.Next(ctx) {
+			keys = append(keys, iter.Val())
+		}
+
+		if err := iter.Err(); err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if err := client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// collection returns the first path segment after the api prefix,
+// e.g. "blogs" for both /api/public/blogs/:id and /api/blogs/:id
+func collection(c echo.Context) string {
+	path := strings.TrimPrefix(c.Request().URL.Path, "/api/")
+	path = strings.TrimPrefix(path, "public/")
+
+	return strings.SplitN(path, "/", 2)[0]
+}
+
+func write(c echo.Context, response *entry) error {
+	header := c.Response().Header()
+	if !varies(header.Values(headerVary), headerAcceptLanguage) {
+		header.Add(headerVary, headerAcceptLanguage)
+	}
+	if response.Language != "" {
+		header.Set(headerContentLanguage, response.Language)
+	}
+
+	if response.Status == http.StatusOK {
+		header.Set(headerETag, response.ETag)
+
+		if match := c.Request().Header.Get(headerIfNoneMatch); match != "" && etagMatches(match, response.ETag) {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+
+	return c.Blob(response.Status, response.ContentType, response.Body)
+}
+
+// varies tells whether the Vary header values name the request header
+func varies(values []string, name string) bool {
+	for _, value := range values {
+		for _, field := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(field), name) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+
+	return false
+}
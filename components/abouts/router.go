@@ -5,11 +5,5 @@ import (
 )
 
 func AboutsRegister(g *echo.Group) {
-	abouts := g.Group("/abouts")
-	abouts.GET("", Get)
-	abouts.POST("", Create)
-	abouts.GET("/:id", Find)
-	abouts.PUT("/:id", Update)
-	abouts.DELETE("/:id", Destroy)
-
+	resource.Register(g)
 }
@@ -0,0 +1,241 @@
+package audits
+
+import (
+	"net/http"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "audits"
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"user":       "user_id",
+	"action":     "action",
+	"collection": "collection",
+}
+
+// fields whose values never end up in the audit log, only the fact they changed
+var redacted = map[string]bool{
+	"password": true,
+}
+
+const redactedValue = "[redacted]"
+
+// Get Audits godoc
+// @Summary Get audit log
+// @Description Get the changes made to every collection, most recent first
+// @ID get-audits
+// @Tags Audits
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param user query string false "Filter by ID of the user who made the change"
+// @Param action query string false "Filter by action (create, update, delete)"
+// @Param collection query string false "Filter by collection"
+// @Param document query string false "Filter by ID of the changed document"
+// @Param from query string false "Changes made from this date (YYYY-MM-DD or RFC3339)"
+// @Param to query string false "Changes made until this date (YYYY-MM-DD or RFC3339)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Audits}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 403 {object} utils.HttpError
+// @Router /audits [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Audits{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if document := c.QueryParam("document"); document != "" {
+		id, err := primitive.ObjectIDFromHex(document)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+		}
+		query.Where(bson.M{"document_id": id})
+	}
+
+	period := bson.M{}
+
+	if from := c.QueryParam("from"); from != "" {
+		date, _, err := utils.ParseDate(from)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "from must be a date (YYYY-MM-DD) or RFC3339 time")
+		}
+		period["$gte"] = date
+	}
+
+	if to := c.QueryParam("to"); to != "" {
+		date, wholeDay, err := utils.ParseDate(to)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "to must be a date (YYYY-MM-DD) or RFC3339 time")
+		}
+
+		// a date includes every change made on that day
+		if wholeDay {
+			period["$lt"] = date.AddDate(0, 0, 1)
+		} else {
+			period["$lte"] = date
+		}
+	}
+
+	if len(period) > 0 {
+		query.Where(bson.M{"created_at": period})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Audits, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Audits godoc
+// @Summary Find audit entry by ID
+// @Description Find audit entry by ID
+// @ID find-audits
+// @Tags Audits
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the audit entry to get"
+// @Success 200 {object} utils.HttpSuccess{data=Audits}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 403 {object} utils.HttpError
+// @Router /audits/{id} [get]
+func Find(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := bson.M{"_id": id}
+
+	var record Audits
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// Snapshot returns the stored document of a collection, nil when it does
+// not exist. Take it before a change to pass it to Record afterwards.
+func Snapshot(collection string, id primitive.ObjectID) bson.M {
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return nil
+	}
+
+	var document bson.M
+	if err = repo.FindByID(id, &document); err != nil {
+		return nil
+	}
+
+	return document
+}
+
+// Record logs the change made by the authenticated user to a document,
+// before is the snapshot taken ahead of the change (nil on create) and the
+// document as stored now is compared against it. Failing to record is
+// logged without failing the request, the change has been made already.
+func Record(c echo.Context, action string, collection string, id primitive.ObjectID, before bson.M) {
+	changes := Diff(before, Snapshot(collection, id))
+	if len(changes) == 0 {
+		return
+	}
+
+	entry := &Audits{
+		ID:         primitive.NewObjectID(),
+		Action:     action,
+		Collection: collection,
+		DocumentId: id,
+		Changes:    changes,
+		CreatedAt:  time.Now(),
+	}
+
+	// public routes have no authenticated user
+	if actor := rbac.GetActor(c); actor != nil {
+		entry.UserId = actor.UserId
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		c.Logger().Error(err)
+		return
+	}
+
+	if err = repo.Insert(entry); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// Diff returns the fields whose value differs between both documents,
+// the values of sensitive fields are redacted
+func Diff(before bson.M, after bson.M) []Change {
+	fields := make([]string, 0)
+	for field := range before {
+		fields = append(fields, field)
+	}
+	for field := range after {
+		if _, ok := before[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	changes := make([]Change, 0)
+	for _, field := range fields {
+		if field == "_id" || reflect.DeepEqual(before[field], after[field]) {
+			continue
+		}
+
+		change := Change{Field: field, Before: before[field], After: after[field]}
+		if redacted[field] {
+			change.Before = redact(change.Before)
+			change.After = redact(change.After)
+		}
+
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func redact(value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+
+	return redactedValue
+}
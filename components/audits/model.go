@@ -0,0 +1,33 @@
+package audits
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	ActionCreate  = "create"
+	ActionUpdate  = "update"
+	ActionDelete  = "delete"
+	ActionRestore = "restore"
+)
+
+// Audits records a single change made to a document
+type Audits struct {
+	ID         primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty"`
+	UserId     string             `json:"userId" bson:"user_id"`
+	Action     string             `json:"action" bson:"action"`
+	Collection string             `json:"collection" bson:"collection"`
+	DocumentId primitive.ObjectID `json:"documentId" bson:"document_id"`
+	Changes    []Change           `json:"changes" bson:"changes"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"created_at"`
+}
+
+// Change holds the value of a field before and after the change,
+// a missing value is stored as null
+type Change struct {
+	Field  string      `json:"field" bson:"field"`
+	Before interface{} `json:"before" bson:"before"`
+	After  interface{} `json:"after" bson:"after"`
+}
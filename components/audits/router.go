@@ -0,0 +1,12 @@
+package audits
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+)
+
+func AuditsRegister(g *echo.Group) {
+	audits := g.Group("/audits", rbac.Allow(rbac.ReadAudits))
+	audits.GET("", Get)
+	audits.GET("/:id", Find)
+}
@@ -0,0 +1,251 @@
+package blogs
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	alice = primitive.NewObjectID()
+	bob   = primitive.NewObjectID()
+)
+
+// newServer serves the blogs from a memory driver holding the posts, the
+// requests are made by the user of the X-User header with the role of
+// the X-Role header
+func newServer(t *testing.T, posts ...Blogs) *echo.Echo {
+	t.Helper()
+
+	repository.Use(repository.NewMemory())
+	repo, _ := repository.Open(resource.Collection)
+	for _, post := range posts {
+		post.Image = "post.jpg"
+		post.Version = 1
+		if err := repo.Insert(post); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e := echo.New()
+	e.Validator = middleware.NewValidator()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+
+	e.GET("/public/blogs", resource.GetPublic)
+	e.GET("/public/blogs/authors", GetAuthors)
+	e.GET("/public/blogs/authors/:author", GetByAuthor)
+	e.GET("/public/blogs/tags", GetTags)
+
+	g := e.Group("/api", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rbac.SetActor(c, &rbac.Actor{UserId: c.Request().Header.Get("X-User"), Role: c.Request().Header.Get("X-Role")})
+
+			return next(c)
+		}
+	})
+	resource.Register(g)
+
+	return e
+}
+
+// patch sends the merge patch as the user with the role
+func patch(e *echo.Echo, id primitive.ObjectID, user primitive.ObjectID, role string, doc string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/api/blogs/"+id.Hex(), strings.NewReader(doc))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	req.Header.Set("X-User", user.Hex())
+	req.Header.Set("X-Role", role)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestAuthorsChangeTheirOwnPosts(t *testing.T) {
+	own := Blogs{ID: primitive.NewObjectID(), Title: "Own", Content: "text", Author: alice, Status: StatusDraft}
+	other := Blogs{ID: primitive.NewObjectID(), Title: "Other", Content: "text", Author: bob, Status: StatusDraft}
+	e := newServer(t, own, other)
+
+	if rec := patch(e, own.ID, alice, rbac.RoleAuthor, `{"content":"changed"}`); rec.Code != http.StatusOK {
+		t.Fatalf("author patching an own post = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := patch(e, other.ID, alice, rbac.RoleAuthor, `{"content":"changed"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("author patching the post of another = %d, want 403", rec.Code)
+	}
+	if rec := patch(e, other.ID, alice, rbac.RoleEditor, `{"content":"changed"}`); rec.Code != http.StatusOK {
+		t.Fatalf("editor patching the post of another = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := patch(e, own.ID, alice, rbac.RoleViewer, `{"content":"changed"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("viewer patching a post = %d, want 403", rec.Code)
+	}
+}
+
+func TestOnlyEditorsPublish(t *testing.T) {
+	post := Blogs{ID: primitive.NewObjectID(), Title: "Post", Content: "text", Author: alice, Status: StatusDraft}
+	e := newServer(t, post)
+
+	if rec := patch(e, post.ID, alice, rbac.RoleAuthor, `{"status":"review"}`); rec.Code != http.StatusOK {
+		t.Fatalf("author submitting for review = %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := patch(e, post.ID, alice, rbac.RoleAuthor, `{"status":"published"}`); rec.Code != http.StatusForbidden {
+		t.Fatalf("author publishing = %d, want 403", rec.Code)
+	}
+	if rec := patch(e, post.ID, bob, rbac.RoleEditor, `{"status":"published"}`); rec.Code != http.StatusOK {
+		t.Fatalf("editor publishing = %d %s", rec.Code, rec.Body.String())
+	}
+
+	repo, _ := repository.Open(resource.Collection)
+	var published Blogs
+	if err := repo.FindByID(post.ID, &published); err != nil {
+		t.Fatal(err)
+	}
+	if published.Status != StatusPublished || published.PublishedAt.IsZero() || published.Version != 3 {
+		t.Fatalf("published = %+v", published)
+	}
+}
+
+func TestUpdateFromJSON(t *testing.T) {
+	post := Blogs{ID: primitive.NewObjectID(), Title: "Post", Content: "text", Author: alice, Status: StatusDraft}
+	e := newServer(t, post)
+
+	category, tag := primitive.NewObjectID(), primitive.NewObjectID()
+	categories, _ := repository.Open("categories")
+	categories.Insert(bson.M{"_id": category, "name": "News", "slug": "news"})
+	tags, _ := repository.Open("tags")
+	tags.Insert(bson.M{"_id": tag, "name": "Go", "slug": "go"})
+
+	put := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/blogs/"+post.ID.Hex(), strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		req.Header.Set("X-User", bob.Hex())
+		req.Header.Set("X-Role", rbac.RoleEditor)
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	/* the IDs added must exist */
+	if rec := put(`{"tags":["` + primitive.NewObjectID().Hex() + `"],"version":1}`); rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("update with an unknown tag = %d, want 422", rec.Code)
+	}
+
+	body := `{"status":"published","publishedAt":"2021-03-01T10:00:00Z","categories":["` + category.Hex() + `"],"tags":["` + tag.Hex() + `"],"version":1}`
+	if rec := put(body); rec.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", rec.Code, rec.Body.String())
+	}
+
+	repo, _ := repository.Open(resource.Collection)
+	var updated Blogs
+	if err := repo.FindByID(post.ID, &updated); err != nil {
+		t.Fatal(err)
+	}
+	if !updated.PublishedAt.Equal(time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("published at = %s", updated.PublishedAt)
+	}
+	if len(updated.Categories) != 1 || updated.Categories[0] != category || len(updated.Tags) != 1 || updated.Tags[0] != tag {
+		t.Fatalf("categories %v tags %v", updated.Categories, updated.Tags)
+	}
+}
+
+func TestPublicListsPublishedPosts(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	e := newServer(t,
+		Blogs{ID: primitive.NewObjectID(), Title: "Live", Content: "text", Author: alice, Status: StatusPublished, PublishedAt: past},
+		Blogs{ID: primitive.NewObjectID(), Title: "Scheduled", Content: "text", Author: alice, Status: StatusPublished, PublishedAt: time.Now().Add(time.Hour)},
+		Blogs{ID: primitive.NewObjectID(), Title: "Draft", Content: "text", Author: alice, Status: StatusDraft},
+		Blogs{ID: primitive.NewObjectID(), Title: "Legacy", Content: "text", Author: bob},
+	)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/blogs?sort=title", nil))
+
+	var res struct {
+		Data []Blogs `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+
+	titles := make([]string, 0, len(res.Data))
+	for _, post := range res.Data {
+		titles = append(titles, post.Title)
+	}
+	if strings.Join(titles, ",") != "Legacy,Live" {
+		t.Fatalf("public posts = %v", titles)
+	}
+}
+
+func TestPublicCounts(t *testing.T) {
+	past := time.Now().Add(-time.Hour)
+	tag := primitive.NewObjectID()
+	e := newServer(t,
+		Blogs{ID: primitive.NewObjectID(), Title: "One", Content: "text", Author: alice, Status: StatusPublished, PublishedAt: past, Tags: []primitive.ObjectID{tag, tag}},
+		Blogs{ID: primitive.NewObjectID(), Title: "Two", Content: "text", Author: alice, Status: StatusPublished, PublishedAt: past, Tags: []primitive.ObjectID{tag}},
+		Blogs{ID: primitive.NewObjectID(), Title: "Three", Content: "text", Author: bob, Status: StatusPublished, PublishedAt: past},
+		Blogs{ID: primitive.NewObjectID(), Title: "Draft", Content: "text", Author: bob, Status: StatusDraft, Tags: []primitive.ObjectID{tag}},
+	)
+
+	users, _ := repository.Open("users")
+	for _, user := range []bson.M{{"_id": alice, "username": "alice", "display_name": "Alice Liddell"}, {"_id": bob, "username": "bob"}} {
+		if err := users.Insert(user); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tags, _ := repository.Open("tags")
+	if err := tags.Insert(bson.M{"_id": tag, "name": "Go", "slug": "go"}); err != nil {
+		t.Fatal(err)
+	}
+
+	get := func(target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+
+	rec := get("/public/blogs/authors")
+	if strings.Contains(rec.Body.String(), "alice") {
+		t.Fatalf("authors expose the usernames: %s", rec.Body.String())
+	}
+	var authors struct {
+		Data []AuthorCount `json:"data"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &authors); err != nil {
+		t.Fatal(err)
+	}
+	if len(authors.Data) != 2 || authors.Data[0].ID != alice || authors.Data[0].Count != 2 || authors.Data[1].Count != 1 {
+		t.Fatalf("authors = %+v", authors.Data)
+	}
+	if authors.Data[0].DisplayName != "Alice Liddell" || authors.Data[1].DisplayName != "" {
+		t.Fatalf("display names = %q %q", authors.Data[0].DisplayName, authors.Data[1].DisplayName)
+	}
+
+	var counts struct {
+		Data []TagCount `json:"data"`
+	}
+	if err := json.Unmarshal(get("/public/blogs/tags").Body.Bytes(), &counts); err != nil {
+		t.Fatal(err)
+	}
+	if len(counts.Data) != 1 || counts.Data[0].Count != 2 {
+		t.Fatalf("tags = %+v", counts.Data)
+	}
+
+	if rec := get("/public/blogs/authors/alice"); rec.Code != http.StatusNotFound {
+		t.Fatalf("author by username = %d, want 404", rec.Code)
+	}
+	if rec := get("/public/blogs/authors/" + alice.Hex()); rec.Code != http.StatusOK {
+		t.Fatalf("author by ID = %d %s", rec.Code, rec.Body.String())
+	}
+}
@@ -1,319 +1,176 @@
 package blogs
 
 import (
-	"context"
-	"io"
+	"fmt"
 	"net/http"
-	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/labstack/echo/v4"
-	DB "github.com/muhammadardie/echo-cms/db"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/crud"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
 	"github.com/muhammadardie/echo-cms/utils"
-	"github.com/rs/xid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var ctx = context.Background()
-
-const colName = "blogs"
-
-// Get Blogs godoc
-// @Summary Get recent blog
-// @Contentription get most recent blog
-// @ID get-blogs
-// @Tags Blogs
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Success 200 {object} utils.HttpSuccess{data=[]Blogs}
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /blogs [get]
-func Get(c echo.Context) error {
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	csr, err := db.Collection(colName).Find(ctx, bson.M{})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	defer csr.Close(ctx)
-
-	result := make([]Blogs, 0)
-	if err = csr.All(ctx, &result); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(result, ""))
-}
-
-// Find Blogs godoc
-// @Summary Find blog by ID
-// @Description Find blog by ID
-// @ID find-blogs
-// @Tags Blogs
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of the blog to get"
-// @Success 200 {object} utils.HttpSuccess{data=Blogs}
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /blogs/{id} [get]
-func Find(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
-	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
-	}
-
-	selector := bson.M{"_id": id}
-
-	var record Blogs
-
-	if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
-}
-
-// Create Blogs godoc
-// @Summary Create blog
-// @Description Create a blog content
-// @ID create-blogs
-// @Tags Blogs
-// @Accept  mpfd
-// @Produce  json
-// @Security Bearer
-// @Param image formData file true "Blog image"
-// @Param title formData string true "Blog title"
-// @Param content formData string true "Blog content"
-// @Success 200 {object} Blogs
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /blogs [post]
-func Create(c echo.Context) error {
-	/* upload image first */
-	file, err := c.FormFile("image")
+// resource serves the blogs from the model, authors write their own posts
+// and only the published ones are on the public site
+var resource = crud.New(crud.Options{
+	Collection: "blogs",
+	Model:      Blogs{},
+	ListFields: utils.Fields{
+		"title":       "title",
+		"status":      "status",
+		"publishedAt": "published_at",
+	},
+	Slug:         "title",
+	UploadDir:    "blog",
+	Write:        rbac.WriteBlogs,
+	Translatable: []string{"title", "content"},
+	Public:       true,
+	PublicScope:  PublishedFilter,
+	Prepare:      prepare,
+	Authorize:    authorize,
+})
+
+// prepare sets the workflow of the post, new posts are drafts of the
+// current user unless told otherwise
+func prepare(c echo.Context, record interface{}, current interface{}) error {
+	blog := record.(*Blogs)
+
+	value, err := crud.Values(c)
 	if err != nil {
 		return err
 	}
 
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	src, err := file.Open()
-	if err != nil {
-		return err
+	/* the status is bound with the other fields, the publish time is read here */
+	if publishedAt := value("publishedAt"); publishedAt != "" {
+		value, err := time.Parse(time.RFC3339, publishedAt)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "publishedAt must be a RFC3339 time")
+		}
+		blog.PublishedAt = value
 	}
-	defer src.Close()
 
-	// Destination
-	path := "./uploaded_files/blog/"
-	name := file.Filename
-	extension := filepath.Ext(name)
-	filename := xid.New().String() + extension
-
-	dst, err := os.OpenFile(path+filename, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return err
-	}
-	defer dst.Close()
+	previous := &Blogs{}
+	if current == nil {
+		if blog.Status == "" {
+			blog.Status = StatusDraft
+		}
 
-	// Copy
-	if _, err = io.Copy(dst, src); err != nil {
-		return err
+		blog.Author, _ = primitive.ObjectIDFromHex(rbac.GetActor(c).UserId)
+	} else {
+		// a post published again keeps its original publish time
+		previous = current.(*Blogs)
 	}
-	/* end upload image */
 
-	/* store record to db */
-	blogsRecord := &Blogs{
-		ID:        primitive.NewObjectID(),
-		Title:     c.FormValue("title"),
-		Content:   c.FormValue("content"),
-		Image:     filename,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	// a published post without publish time goes live immediately
+	if blog.Status == StatusPublished && blog.PublishedAt.IsZero() {
+		blog.PublishedAt = time.Now()
 	}
 
-	_, err = db.Collection(colName).InsertOne(ctx, blogsRecord)
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+	// authors submit their posts for review, publishing is up to the editors
+	changed := blog.Status != previous.Status || !blog.PublishedAt.Equal(previous.PublishedAt)
+	if changed && (blog.Status == StatusPublished || blog.Status == StatusArchived) && !rbac.GetActor(c).Can(rbac.WriteContent) {
+		return echo.NewHTTPError(http.StatusForbidden, "Only editors can publish or archive blog posts")
 	}
 
-	return c.JSON(http.StatusOK, utils.NewSuccess(blogsRecord, "Saved"))
-}
-
-// Update Blog godoc
-// @Summary Update blog
-// @Description Update blog
-// @ID update-blog
-// @Tags Blogs
-// @Accept  mpfd
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of blog to get"
-// @Param image formData file false "Blog image"
-// @Param title formData string false "Blog title"
-// @Param content formData string false "Blog content"
-// @Success 200 {object} Blogs
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /blogs/{id} [put]
-func Update(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	/* categories and tags are lists of IDs, the ones added must exist */
+	invalid := make([]utils.FieldError, 0)
+	if fieldErr := readIDs(c, "categories", "categories", &blog.Categories, previous.Categories); fieldErr != nil {
+		invalid = append(invalid, *fieldErr)
 	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	if fieldErr := readIDs(c, "tags", "tags", &blog.Tags, previous.Tags); fieldErr != nil {
+		invalid = append(invalid, *fieldErr)
 	}
-
-	selector := bson.M{"_id": id}
-
-	changes := &Blogs{
-		Title:   c.FormValue("title"),
-		Content: c.FormValue("content"),
-		Image:   "",
+	if len(invalid) > 0 {
+		return utils.NewValidationError(invalid...)
 	}
 
-	/* check image exist first */
-	file, err := c.FormFile("image")
-	// if no error then there is valid image request
-	if err == nil {
-		/* delete existing file if exist */
-		path := "./uploaded_files/blog/"
-		var record Blogs
-
-		if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err)
-		}
+	return nil
+}
 
-		if record.Image != "" {
-			filePath := path + record.Image
+// readIDs reads the IDs of the field when the request has it, and
+// checks that the ones not in the previous IDs are in the collection
+func readIDs(c echo.Context, field string, collection string, ids *[]primitive.ObjectID, previous []primitive.ObjectID) *utils.FieldError {
+	// the body is read by prepare already, it is valid
+	if values, ok, _ := crud.List(c, field); ok {
+		*ids = make([]primitive.ObjectID, 0, len(values))
 
-			// Ensure the file path exists before attempting deletion
-			if _, err := os.Stat(filePath); err == nil {
-				// File exists, proceed to delete
-				err := os.Remove(filePath)
-				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, "Failed to delete old file: "+err.Error())
-				}
-			} else if os.IsNotExist(err) {
-				// File does not exist, skip deletion
-			} else {
-				// Other errors
-				return echo.NewHTTPError(http.StatusInternalServerError, "Error checking file: "+err.Error())
+		for _, value := range values {
+			id, err := primitive.ObjectIDFromHex(value)
+			if err != nil {
+				return &utils.FieldError{Field: field, Code: "id", Message: fmt.Sprintf("%s must be a list of IDs", field)}
 			}
+			*ids = append(*ids, id)
 		}
+		*ids = unique(*ids)
+	}
 
-		/* upload new file */
-		name := file.Filename
-		extension := filepath.Ext(name)
-		filename := xid.New().String() + extension
-
-		src, err := file.Open()
-		if err != nil {
-			return err
-		}
-		defer src.Close()
-
-		// Destination
-		dst, err := os.OpenFile(path+filename, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			return err
-		}
-		defer dst.Close()
-
-		// Copy
-		if _, err = io.Copy(dst, src); err != nil {
-			return err
+	added := bson.A{}
+	seen := map[primitive.ObjectID]bool{}
+	for _, id := range previous {
+		seen[id] = true
+	}
+	for _, id := range *ids {
+		if !seen[id] {
+			seen[id] = true
+			added = append(added, id)
 		}
-
-		changes.Image = filename
 	}
-
-	update, err := db.Collection(colName).UpdateOne(ctx, selector, bson.M{"$set": changes})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+	if len(added) == 0 {
+		return nil
 	}
 
-	return c.JSON(http.StatusOK, utils.NewSuccess(update, "Updated"))
-
-}
-
-// Delete Blog godoc
-// @Summary Delete a blog
-// @Description Delete a blog
-// @ID delete-blog
-// @Tags Blogs
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of the blog"
-// @Success 200 {object} Blogs
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /blogs/{id} [delete]
-func Destroy(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
+	repo, err := repository.Open(collection)
 	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+		return &utils.FieldError{Field: field, Code: "exists", Message: err.Error()}
 	}
 
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	count, err := repo.Count(trash.Active(bson.M{"_id": bson.M{"$in": added}}))
+	if err != nil || count != int64(len(added)) {
+		return &utils.FieldError{Field: field, Code: "exists", Message: fmt.Sprintf("%s must be existing %s", field, collection)}
 	}
 
-	selector := bson.M{"_id": id}
-
-	/* delete any exist image */
-	path := "./uploaded_files/blog/"
-	var record Blogs
+	return nil
+}
 
-	if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+// authorize forbids authors to touch the posts of others
+func authorize(c echo.Context, record interface{}, action string) error {
+	if canModify(c, record.(*Blogs)) {
+		return nil
 	}
 
-	if record.Image != "" {
-		filePath := path + record.Image
-
-		// Check if the file exists
-		if _, err := os.Stat(filePath); err == nil {
-			// File exists, proceed to delete
-			err := os.Remove(filePath)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, err)
-			}
-		}
+	verb := "change"
+	switch action {
+	case audits.ActionDelete:
+		verb = "delete"
+	case audits.ActionRestore:
+		verb = "restore"
 	}
 
-	/* delete record */
-	result, err := db.Collection(colName).DeleteOne(ctx, selector)
+	return echo.NewHTTPError(http.StatusForbidden, "You can only "+verb+" your own blog posts")
+}
 
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
+// canModify reports whether the current user may change the blog,
+// authors are only allowed to change their own posts
+func canModify(c echo.Context, record *Blogs) bool {
+	actor := rbac.GetActor(c)
+	if actor.Can(rbac.WriteContent) {
+		return true
 	}
 
-	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+	return actor != nil && record.Author.Hex() == actor.UserId
+}
+
+// PublishedFilter matches the posts visible on the public site,
+// scheduled posts stay hidden until their publish time has passed
+func PublishedFilter() bson.M {
+	return bson.M{"$or": bson.A{
+		bson.M{"status": StatusPublished, "published_at": bson.M{"$lte": time.Now()}},
+		bson.M{"status": bson.M{"$exists": false}}, // posts created before the workflow existed
+	}}
 }
@@ -1,15 +1,36 @@
 package blogs
 
 import (
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/images"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
+const (
+	StatusDraft     = "draft"
+	StatusReview    = "review"
+	StatusPublished = "published"
+	StatusArchived  = "archived"
+)
+
 type Blogs struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Title     string             `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
-	Content   string             `json:"content" bson:"content,omitempty" form:"content" query:"content" validate:"required"`
-	Image     string             `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	ID            primitive.ObjectID   `json:"id,omitempty" bson:"_id,omitempty"`
+	Title         string               `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
+	Slug          string               `json:"slug" bson:"slug,omitempty" form:"slug" query:"slug"`
+	PreviousSlugs []string             `json:"previousSlugs,omitempty" bson:"previous_slugs,omitempty"`
+	Content       string               `json:"content" bson:"content,omitempty" form:"content" query:"content" validate:"required"`
+	Image         string               `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
+	Variants      *images.Variants     `json:"variants,omitempty" bson:"variants,omitempty"`
+	Media         primitive.ObjectID   `json:"media,omitempty" bson:"media,omitempty" form:"media" query:"media"`
+	Author        primitive.ObjectID   `json:"author,omitempty" bson:"author,omitempty"`
+	Categories    []primitive.ObjectID `json:"categories,omitempty" bson:"categories,omitempty" form:"categories" query:"categories"`
+	Tags          []primitive.ObjectID `json:"tags,omitempty" bson:"tags,omitempty" form:"tags" query:"tags"`
+	Status        string               `json:"status" bson:"status,omitempty" form:"status" query:"status" validate:"omitempty,oneof=draft review published archived"`
+	PublishedAt   time.Time            `json:"publishedAt,omitempty" bson:"published_at,omitempty" form:"publishedAt" query:"publishedAt"`
+	Version       int64                `json:"version" bson:"version" form:"version"`
+	Translations  i18n.Translations    `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt     time.Time            `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time            `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time           `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
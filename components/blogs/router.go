@@ -2,13 +2,12 @@ package blogs
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/cache"
 )
 
 func BlogsRegister(g *echo.Group) {
-	blogs := g.Group("/blogs")
-	blogs.GET("", Get)
-	blogs.POST("", Create)
-	blogs.GET("/:id", Find)
-	blogs.PUT("/:id", Update)
-	blogs.DELETE("/:id", Destroy)
+	resource.Register(g)
+
+	// the public lists of posts show their categories, tags and authors
+	cache.Depend(resource.Collection, "categories", "tags", "users")
 }
@@ -0,0 +1,366 @@
+package blogs
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/categories"
+	"github.com/muhammadardie/echo-cms/components/tags"
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// CategoryCount is a category with the number of published posts in it
+// or in the categories below it
+type CategoryCount struct {
+	categories.Categories `bson:",inline"`
+	Count                 int              `json:"count"`
+	Children              []*CategoryCount `json:"children"`
+}
+
+// TagCount is a tag with the number of published posts tagged with it
+type TagCount struct {
+	tags.Tags `bson:",inline"`
+	Count     int `json:"count"`
+}
+
+// AuthorCount is a user with the number of posts they published, shown
+// by the display name they chose. The usernames are used to log in and
+// are kept private, the name is empty for the users without one.
+type AuthorCount struct {
+	ID          primitive.ObjectID `json:"id"`
+	DisplayName string             `json:"displayName"`
+	Count       int                `json:"count"`
+}
+
+// GetCategories Blogs godoc
+// @Summary Get blog categories on the public site
+// @Description Get the tree of the blog categories with the number of published posts in each one, counting the ones of the categories below it, their text in the locale of ?lang= or Accept-Language
+// @ID get-public-blog-categories
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Success 200 {object} utils.HttpSuccess{data=[]CategoryCount}
+// @Failure 400 {object} utils.HttpError
+// @Router /public/blogs/categories [get]
+func GetCategories(c echo.Context) error {
+	all, err := categories.All()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	/* the posts sharing the same categories are counted together */
+	groups := make([]struct {
+		Categories []primitive.ObjectID `bson:"_id"`
+		Count      int                  `bson:"count"`
+	}, 0)
+	if err = published(&groups, bson.M{"$group": bson.M{"_id": "$categories", "count": bson.M{"$sum": 1}}}); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	i18n.Localize(c, &all, "name", "desc")
+
+	nodes := map[primitive.ObjectID]*CategoryCount{}
+	for _, category := range all {
+		nodes[category.ID] = &CategoryCount{Categories: category, Children: make([]*CategoryCount, 0)}
+	}
+
+	/* a post counts once in a category, even when it has several below it */
+	for _, group := range groups {
+		counted := map[primitive.ObjectID]bool{}
+
+		for _, id := range group.Categories {
+			for node := nodes[id]; node != nil && !counted[node.ID]; node, _ = parentOf(nodes, node) {
+				counted[node.ID] = true
+				node.Count += group.Count
+			}
+		}
+	}
+
+	roots := make([]*CategoryCount, 0)
+	for _, category := range all {
+		node := nodes[category.ID]
+		if parent, ok := parentOf(nodes, node); ok {
+			parent.Children = append(parent.Children, node)
+		} else {
+			roots = append(roots, node)
+		}
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(roots, ""))
+}
+
+// parentOf returns the node of the parent of the category, categories
+// whose parent is gone are roots
+func parentOf(nodes map[primitive.ObjectID]*CategoryCount, node *CategoryCount) (*CategoryCount, bool) {
+	if node.Parent == nil || *node.Parent == node.ID {
+		return nil, false
+	}
+
+	parent, ok := nodes[*node.Parent]
+
+	return parent, ok
+}
+
+// GetTags Blogs godoc
+// @Summary Get blog tags on the public site
+// @Description Get the blog tags with the number of published posts tagged with each one, the most used first, their text in the locale of ?lang= or Accept-Language
+// @ID get-public-blog-tags
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Success 200 {object} utils.HttpSuccess{data=[]TagCount}
+// @Failure 400 {object} utils.HttpError
+// @Router /public/blogs/tags [get]
+func GetTags(c echo.Context) error {
+	all, err := tags.All()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	/* a post tagged twice with a tag counts once */
+	groups := make([]struct {
+		Tag   primitive.ObjectID `bson:"_id"`
+		Count int                `bson:"count"`
+	}, 0)
+	err = published(&groups,
+		bson.M{"$unwind": "$tags"},
+		bson.M{"$group": bson.M{"_id": bson.M{"post": "$_id", "tag": "$tags"}}},
+		bson.M{"$group": bson.M{"_id": "$_id.tag", "count": bson.M{"$sum": 1}}},
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	i18n.Localize(c, &all, "name")
+
+	counts := map[primitive.ObjectID]int{}
+	for _, group := range groups {
+		counts[group.Tag] = group.Count
+	}
+
+	result := make([]TagCount, 0, len(all))
+	for _, tag := range all {
+		result = append(result, TagCount{Tags: tag, Count: counts[tag.ID]})
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Count > result[j].Count
+	})
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, ""))
+}
+
+// GetAuthors Blogs godoc
+// @Summary Get blog authors on the public site
+// @Description Get the users who published blog posts with their display name and the number of their posts, the most prolific first
+// @ID get-public-blog-authors
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} utils.HttpSuccess{data=[]AuthorCount}
+// @Failure 400 {object} utils.HttpError
+// @Router /public/blogs/authors [get]
+func GetAuthors(c echo.Context) error {
+	groups := make([]struct {
+		Author primitive.ObjectID `bson:"_id"`
+		Count  int                `bson:"count"`
+	}, 0)
+	err := published(&groups,
+		bson.M{"$match": bson.M{"author": bson.M{"$exists": true}}},
+		bson.M{"$group": bson.M{"_id": "$author", "count": bson.M{"$sum": 1}}},
+	)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]AuthorCount, 0, len(groups))
+	if len(groups) > 0 {
+		ids := bson.A{}
+		for _, group := range groups {
+			ids = append(ids, group.Author)
+		}
+
+		repo, err := repository.Open("users")
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		/* the posts of deleted users are not theirs to show anymore */
+		users := make([]struct {
+			ID          primitive.ObjectID `bson:"_id"`
+			DisplayName string             `bson:"display_name"`
+		}, 0)
+		filter := trash.Active(bson.M{"_id": bson.M{"$in": ids}})
+		if err = repo.FindAll(filter, options.Find().SetProjection(bson.M{"_id": 1, "display_name": 1}), &users); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		names := map[primitive.ObjectID]string{}
+		for _, user := range users {
+			names[user.ID] = user.DisplayName
+		}
+
+		for _, group := range groups {
+			if name, active := names[group.Author]; active {
+				result = append(result, AuthorCount{ID: group.Author, DisplayName: name, Count: group.Count})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].ID.Hex() < result[j].ID.Hex()
+	})
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, ""))
+}
+
+// GetByCategory Blogs godoc
+// @Summary Get blog posts of a category on the public site
+// @Description Get the published posts of a category, by ID or slug, or of the categories below it. A slug the category had before responds with 301 and the current slug.
+// @ID get-public-blogs-by-category
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Param category path string true "ID or slug of the category"
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -publishedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Blogs}
+// @Success 301 {object} utils.HttpSuccess{data=slug.Redirect}
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/blogs/categories/{category} [get]
+func GetByCategory(c echo.Context) error {
+	param := c.Param("category")
+
+	category, err := categories.Lookup(param)
+	if err != nil {
+		if moved := slug.Moved(c, "categories", param, bson.M{}); moved != repository.ErrNotFound {
+			return moved
+		}
+
+		return echo.NewHTTPError(http.StatusNotFound, "Category not found")
+	}
+
+	ids, err := categories.Subtree(category.ID)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	in := bson.A{}
+	for _, id := range ids {
+		in = append(in, id)
+	}
+
+	return resource.GetPublicWhere(c, bson.M{"categories": bson.M{"$in": in}})
+}
+
+// GetByTag Blogs godoc
+// @Summary Get blog posts of a tag on the public site
+// @Description Get the published posts tagged with a tag, by ID or slug. A slug the tag had before responds with 301 and the current slug.
+// @ID get-public-blogs-by-tag
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Param tag path string true "ID or slug of the tag"
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -publishedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Blogs}
+// @Success 301 {object} utils.HttpSuccess{data=slug.Redirect}
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/blogs/tags/{tag} [get]
+func GetByTag(c echo.Context) error {
+	param := c.Param("tag")
+
+	tag, err := tags.Lookup(param)
+	if err != nil {
+		if moved := slug.Moved(c, "tags", param, bson.M{}); moved != repository.ErrNotFound {
+			return moved
+		}
+
+		return echo.NewHTTPError(http.StatusNotFound, "Tag not found")
+	}
+
+	return resource.GetPublicWhere(c, bson.M{"tags": tag.ID})
+}
+
+// GetByAuthor Blogs godoc
+// @Summary Get blog posts of an author on the public site
+// @Description Get the published posts of an author by the ID of the user
+// @ID get-public-blogs-by-author
+// @Tags Blogs
+// @Accept  json
+// @Produce  json
+// @Param author path string true "ID of the author"
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -publishedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Blogs}
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/blogs/authors/{author} [get]
+func GetByAuthor(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("author"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Author not found")
+	}
+
+	repo, err := repository.Open("users")
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	if total, err := repo.Count(trash.Active(bson.M{"_id": id})); err != nil || total == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Author not found")
+	}
+
+	return resource.GetPublicWhere(c, bson.M{"author": id})
+}
+
+// published runs the stages over the posts on the public site, the
+// counting is left to the database instead of loading every post
+func published(result interface{}, stages ...bson.M) error {
+	repo, err := repository.Open(resource.Collection)
+	if err != nil {
+		return err
+	}
+
+	pipeline := append([]bson.M{{"$match": bson.M{"$and": bson.A{trash.Active(bson.M{}), PublishedFilter()}}}}, stages...)
+
+	return repo.Aggregate(pipeline, result)
+}
+
+func unique(ids []primitive.ObjectID) []primitive.ObjectID {
+	seen := map[primitive.ObjectID]bool{}
+	result := make([]primitive.ObjectID, 0, len(ids))
+
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+
+	return result
+}
@@ -5,10 +5,5 @@ import (
 )
 
 func CarouselsRegister(g *echo.Group) {
-	carousels := g.Group("/carousels")
-	carousels.GET("", Get)
-	carousels.POST("", Create)
-	carousels.GET("/:id", Find)
-	carousels.PUT("/:id", Update)
-	carousels.DELETE("/:id", Destroy)
+	resource.Register(g)
 }
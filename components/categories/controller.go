@@ -0,0 +1,132 @@
+package categories
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/crud"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const colName = "categories"
+
+// resource serves the categories of the blog posts from the model, a
+// category may be the child of another one, see crud.Options
+var resource = crud.New(crud.Options{
+	Collection: colName,
+	Model:      Categories{},
+	ListFields: utils.Fields{
+		"name": "name",
+		"slug": "slug",
+	},
+	Slug:         "name",
+	Translatable: []string{"name", "desc"},
+	Public:       true,
+	Prepare:      prepare,
+})
+
+// prepare reads the parent of the category, it must be another category
+// that is not below this one
+func prepare(c echo.Context, record interface{}, current interface{}) error {
+	category := record.(*Categories)
+
+	if values, ok := utils.FormList(c, "parent"); ok {
+		category.Parent = nil
+
+		if len(values) > 0 {
+			parent, err := primitive.ObjectIDFromHex(values[0])
+			if err != nil {
+				return utils.NewValidationError(utils.FieldError{Field: "parent", Code: "id", Message: "parent must be the ID of a category"})
+			}
+			category.Parent = &parent
+		}
+	}
+
+	if category.Parent == nil {
+		return nil
+	}
+
+	all, err := All()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	found := false
+	for _, other := range all {
+		found = found || other.ID == *category.Parent
+	}
+	if !found {
+		return utils.NewValidationError(utils.FieldError{Field: "parent", Code: "exists", Message: "parent must be an existing category"})
+	}
+
+	if current != nil {
+		for _, id := range subtree(all, current.(*Categories).ID) {
+			if id == *category.Parent {
+				return utils.NewValidationError(utils.FieldError{Field: "parent", Code: "cycle", Message: "parent can not be the category or one below it"})
+			}
+		}
+	}
+
+	return nil
+}
+
+// Lookup returns the category of the ID or of the slug
+func Lookup(param string) (*Categories, error) {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, err
+	}
+
+	record := new(Categories)
+	if err = repo.FindOne(trash.Active(slug.Selector(param)), record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// All returns every category by name
+func All() ([]Categories, error) {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Categories, 0)
+	err = repo.FindAll(trash.Active(bson.M{}), options.Find().SetSort(bson.D{{Key: "name", Value: 1}}), &records)
+
+	return records, err
+}
+
+// Subtree returns the ID of the category with the IDs of every category
+// below it
+func Subtree(id primitive.ObjectID) ([]primitive.ObjectID, error) {
+	all, err := All()
+	if err != nil {
+		return nil, err
+	}
+
+	return subtree(all, id), nil
+}
+
+func subtree(all []Categories, id primitive.ObjectID) []primitive.ObjectID {
+	ids := []primitive.ObjectID{id}
+	seen := map[primitive.ObjectID]bool{id: true}
+
+	for i := 0; i < len(ids); i++ {
+		for _, category := range all {
+			if category.Parent != nil && *category.Parent == ids[i] && !seen[category.ID] {
+				seen[category.ID] = true
+				ids = append(ids, category.ID)
+			}
+		}
+	}
+
+	return ids
+}
@@ -0,0 +1,9 @@
+package categories
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func CategoriesRegister(g *echo.Group) {
+	resource.Register(g)
+}
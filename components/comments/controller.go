@@ -0,0 +1,345 @@
+package comments
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "comments"
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"name":   "name",
+	"email":  "email",
+	"status": "status",
+}
+
+// Get Comments godoc
+// @Summary Get comments
+// @Description Get the comments of every blog post, whatever their status
+// @ID get-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param blog query string false "Filter by ID of the blog post"
+// @Param status query string false "Filter by status (pending, approved, rejected, spam)"
+// @Param name query string false "Filter by name"
+// @Param email query string false "Filter by email"
+// @Success 200 {object} utils.HttpSuccess{data=[]Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /comments [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Comments{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return list(c, query)
+}
+
+// Queue Comments godoc
+// @Summary Get the moderation queue
+// @Description Get the comments waiting for moderation, the oldest first
+// @ID queue-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param blog query string false "Filter by ID of the blog post"
+// @Success 200 {object} utils.HttpSuccess{data=[]Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /comments/queue [get]
+func Queue(c echo.Context) error {
+	query, err := utils.NewListQuery(c, utils.Fields{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Sort = bson.D{{Key: "created_at", Value: 1}}
+	query.Where(bson.M{"status": StatusPending})
+
+	return list(c, query)
+}
+
+func list(c echo.Context, query *utils.ListQuery) error {
+	query.Where(trash.Active(bson.M{}))
+
+	if blog := c.QueryParam("blog"); blog != "" {
+		id, err := primitive.ObjectIDFromHex(blog)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "blog must be an ID")
+		}
+		query.Where(bson.M{"blog": id})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Comments, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Comments godoc
+// @Summary Find comment by ID
+// @Description Find comment by ID
+// @ID find-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the comment to get"
+// @Success 200 {object} utils.HttpSuccess{data=Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /comments/{id} [get]
+func Find(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var record Comments
+
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// Approve Comments godoc
+// @Summary Approve comment
+// @Description Show the comment on the public site
+// @ID approve-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the comment"
+// @Param If-Match header string false "ETag of the comment the moderation is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /comments/{id}/approve [post]
+func Approve(c echo.Context) error {
+	return moderate(c, StatusApproved)
+}
+
+// Reject Comments godoc
+// @Summary Reject comment
+// @Description Keep the comment off the public site
+// @ID reject-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the comment"
+// @Param If-Match header string false "ETag of the comment the moderation is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /comments/{id}/reject [post]
+func Reject(c echo.Context) error {
+	return moderate(c, StatusRejected)
+}
+
+// Spam Comments godoc
+// @Summary Mark comment as spam
+// @Description Keep the comment off the public site as spam
+// @ID spam-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the comment"
+// @Param If-Match header string false "ETag of the comment the moderation is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /comments/{id}/spam [post]
+func Spam(c echo.Context) error {
+	return moderate(c, StatusSpam)
+}
+
+// moderate sets the status of the comment of the ID with the moderator
+func moderate(c echo.Context, status string) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	/* the moderation must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, 0)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Comments
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	if err = version.Check(submitted, record.Version, &record); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	record.Status = status
+	record.ModeratedAt = &now
+	record.UpdatedAt = now
+	if actor := rbac.GetActor(c); actor != nil {
+		record.ModeratedBy = actor.UserId
+	}
+
+	update := bson.M{"$set": bson.M{
+		"status":       record.Status,
+		"moderated_by": record.ModeratedBy,
+		"moderated_at": now,
+		"updated_at":   now,
+	}}
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, record.Version), version.Bump(update))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, &Comments{})
+	}
+	record.Version++
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Updated"))
+}
+
+// Delete Comments godoc
+// @Summary Delete comment
+// @Description Move the comment to the trash
+// @ID delete-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the comment"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /comments/{id} [delete]
+func Destroy(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	before := audits.Snapshot(colName, id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionDelete, colName, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// Trash Comments godoc
+// @Summary Get deleted comments
+// @Description Get the comments in the trash, they are purged for good after the retention period
+// @ID trash-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Comments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /comments/trash [get]
+func Trash(c echo.Context) error {
+	return trash.List(c, colName, &[]Comments{})
+}
+
+// Restore Comments godoc
+// @Summary Restore deleted comments
+// @Description Take a comment out of the trash
+// @ID restore-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the deleted record"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /comments/{id}/restore [post]
+func Restore(c echo.Context) error {
+	return trash.Restore(c, colName)
+}
@@ -0,0 +1,14 @@
+package comments
+
+import "github.com/muhammadardie/echo-cms/mail"
+
+// mail telling the staff of a comment waiting for moderation
+const templateComment = "new-comment"
+
+func init() {
+	mail.Register(templateComment, "Sent to the staff of MAIL_STAFF when a visitor comments on a blog post",
+		`New comment on {{.Post}}`,
+		"{{.Name}} commented on {{.Post}}, the comment waits for moderation:\n\n{{.Content}}\n",
+		`<p><strong>{{.Name}}</strong> commented on <em>{{.Post}}</em>, the comment waits for moderation:</p><blockquote style="white-space: pre-wrap">{{.Content}}</blockquote>`,
+		mail.Data{"Name": "Jane Doe", "Post": "Hello world", "Content": "Great post!"})
+}
@@ -0,0 +1,54 @@
+package comments
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	StatusPending  = "pending"
+	StatusApproved = "approved"
+	StatusRejected = "rejected"
+	StatusSpam     = "spam"
+)
+
+type Comments struct {
+	ID          primitive.ObjectID  `json:"id,omitempty" bson:"_id,omitempty"`
+	Blog        primitive.ObjectID  `json:"blog" bson:"blog"`
+	Parent      *primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	Thread      primitive.ObjectID  `json:"thread" bson:"thread"`
+	Name        string              `json:"name" bson:"name"`
+	Email       string              `json:"email,omitempty" bson:"email,omitempty"`
+	Content     string              `json:"content" bson:"content"`
+	Status      string              `json:"status" bson:"status"`
+	IP          string              `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent   string              `json:"userAgent,omitempty" bson:"user_agent,omitempty"`
+	ModeratedBy string              `json:"moderatedBy,omitempty" bson:"moderated_by,omitempty"`
+	ModeratedAt *time.Time          `json:"moderatedAt,omitempty" bson:"moderated_at,omitempty"`
+	Version     int64               `json:"version" bson:"version"`
+	CreatedAt   time.Time           `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time           `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt   *time.Time          `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// PublicComments are the approved comments shown on the public site,
+// without the details of the people who wrote them
+type PublicComments struct {
+	ID        primitive.ObjectID  `json:"id" bson:"_id"`
+	Parent    *primitive.ObjectID `json:"parent,omitempty" bson:"parent,omitempty"`
+	Thread    primitive.ObjectID  `json:"-" bson:"thread"`
+	Name      string              `json:"name" bson:"name"`
+	Content   string              `json:"content" bson:"content"`
+	CreatedAt time.Time           `json:"createdAt" bson:"created_at"`
+	Replies   []*PublicComments   `json:"replies" bson:"-"`
+}
+
+// NewComment is the comment sent from the public site, Website is a
+// honeypot hidden from the people filling the form
+type NewComment struct {
+	Name    string `json:"name" form:"name" validate:"required,max=100"`
+	Email   string `json:"email" form:"email" validate:"omitempty,email"`
+	Content string `json:"content" form:"content" validate:"required,max=5000"`
+	Parent  string `json:"parent" form:"parent"`
+	Website string `json:"website" form:"website"`
+}
@@ -0,0 +1,217 @@
+package comments
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/blogs"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// comments a visitor may send per minute when COMMENTS_RATE_LIMIT is not set
+const defaultRateLimit = 5
+
+// RateLimit limits the comments sent from an IP, configured by
+// COMMENTS_RATE_LIMIT as a number of comments per minute
+func RateLimit() echo.MiddlewareFunc {
+	return utils.RateLimit("COMMENTS_RATE_LIMIT", defaultRateLimit, "Too many comments, try again in a minute")
+}
+
+// CreatePublic Comments godoc
+// @Summary Comment on a blog post
+// @Description Send a comment on a published blog post, by ID or slug, or a reply to an approved comment of the post. The comment is shown once a moderator approved it, the staff of MAIL_STAFF is told by mail. Comments are limited per IP by COMMENTS_RATE_LIMIT per minute.
+// @ID create-public-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID or slug of the blog post"
+// @Param name body string true "Name shown with the comment"
+// @Param email body string false "Email, never shown"
+// @Param content body string true "Text of the comment"
+// @Param parent body string false "ID of the approved comment replied to"
+// @Success 202 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Failure 429 {object} utils.HttpError
+// @Router /public/blogs/{id}/comments [post]
+func CreatePublic(c echo.Context) error {
+	comment := new(NewComment)
+	if err := c.Bind(comment); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	accepted := utils.NewSuccess(nil, "Comment awaiting moderation")
+	accepted.Code = http.StatusAccepted
+
+	/* only bots fill the hidden field, they are told the comment was taken */
+	if comment.Website != "" {
+		return c.JSON(http.StatusAccepted, accepted)
+	}
+
+	comment.Name = strings.TrimSpace(comment.Name)
+	comment.Content = strings.TrimSpace(comment.Content)
+	if err := utils.Validate(c, comment); err != nil {
+		return err
+	}
+
+	blog, err := publishedBlog(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	now := time.Now()
+	record := &Comments{
+		ID:        primitive.NewObjectID(),
+		Blog:      blog.ID,
+		Name:      comment.Name,
+		Email:     comment.Email,
+		Content:   comment.Content,
+		Status:    StatusPending,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	record.Thread = record.ID
+
+	/* a reply joins the thread of an approved comment of the same post */
+	if comment.Parent != "" {
+		parentID, err := primitive.ObjectIDFromHex(comment.Parent)
+		if err != nil {
+			return utils.NewValidationError(utils.FieldError{Field: "parent", Code: "id", Message: "parent must be the ID of a comment"})
+		}
+
+		var parent Comments
+		selector := trash.Active(bson.M{"_id": parentID, "blog": blog.ID, "status": StatusApproved})
+		if err = repo.FindOne(selector, &parent); err != nil {
+			return utils.NewValidationError(utils.FieldError{Field: "parent", Code: "exists", Message: "parent must be an approved comment of the post"})
+		}
+
+		record.Parent = &parent.ID
+		record.Thread = parent.Thread
+	}
+
+	if err = repo.Insert(record); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save comment")
+	}
+
+	audits.Record(c, audits.ActionCreate, colName, record.ID, nil)
+
+	// the comment is in the queue even when the staff can't be told
+	data := mail.Data{"Name": record.Name, "Post": blog.Title, "Content": record.Content}
+	if err = mail.Send(templateComment, mail.Staff(), "", data); err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusAccepted, accepted)
+}
+
+// GetPublic Comments godoc
+// @Summary Get comments of a blog post
+// @Description Get the approved comments of a published blog post, by ID or slug, with their replies nested. Pages count the comments that start a thread.
+// @ID get-public-comments
+// @Tags Comments
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID or slug of the blog post"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. createdAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]PublicComments}
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/blogs/{id}/comments [get]
+func GetPublic(c echo.Context) error {
+	query, err := utils.NewListQuery(c, utils.Fields{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	blog, err := publishedBlog(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	approved := trash.Active(bson.M{"blog": blog.ID, "status": StatusApproved})
+	query.Where(approved)
+	query.Where(bson.M{"parent": bson.M{"$exists": false}})
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]*PublicComments, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	threads := bson.A{}
+	nodes := map[primitive.ObjectID]*PublicComments{}
+	for _, comment := range result {
+		comment.Replies = make([]*PublicComments, 0)
+		nodes[comment.ID] = comment
+		threads = append(threads, comment.ID)
+	}
+
+	if len(threads) > 0 {
+		replies := make([]*PublicComments, 0)
+		selector := bson.M{"$and": bson.A{approved, bson.M{"thread": bson.M{"$in": threads}, "parent": bson.M{"$exists": true}}}}
+		if err = repo.FindAll(selector, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}), &replies); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		for _, reply := range replies {
+			reply.Replies = make([]*PublicComments, 0)
+			nodes[reply.ID] = reply
+		}
+
+		// replies to a comment that is not approved are left out
+		for _, reply := range replies {
+			if parent, ok := nodes[*reply.Parent]; ok {
+				parent.Replies = append(parent.Replies, reply)
+			}
+		}
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// publishedBlog returns the blog post of the ID or slug when it is on
+// the public site
+func publishedBlog(param string) (*blogs.Blogs, error) {
+	repo, err := repository.Open("blogs")
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var blog blogs.Blogs
+	selector := bson.M{"$and": bson.A{trash.Active(slug.Selector(param)), blogs.PublishedFilter()}}
+	if err = repo.FindOne(selector, &blog); err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Blog post not found")
+	}
+
+	return &blog, nil
+}
@@ -0,0 +1,26 @@
+package comments
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/cache"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/trash"
+)
+
+func CommentsRegister(g *echo.Group) {
+	trash.Register(colName, nil)
+
+	// the approved comments are listed under the public blog posts
+	cache.Depend("blogs", colName)
+
+	comments := g.Group("/comments")
+	comments.GET("", Get, rbac.Allow(rbac.ReadContent))
+	comments.GET("/queue", Queue, rbac.Allow(rbac.ReadContent))
+	comments.GET("/trash", Trash, rbac.Allow(rbac.ReadContent))
+	comments.GET("/:id", Find, rbac.Allow(rbac.ReadContent))
+	comments.POST("/:id/approve", Approve, rbac.Allow(rbac.WriteContent))
+	comments.POST("/:id/reject", Reject, rbac.Allow(rbac.WriteContent))
+	comments.POST("/:id/spam", Spam, rbac.Allow(rbac.WriteContent))
+	comments.DELETE("/:id", Destroy, rbac.Allow(rbac.WriteContent))
+	comments.POST("/:id/restore", Restore, rbac.Allow(rbac.WriteContent))
+}
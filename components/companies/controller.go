@@ -1,314 +1,18 @@
 package companies
 
 import (
-	"context"
-	"io"
-	"net/http"
-	"os"
-	"path/filepath"
-	"time"
-
-	"github.com/labstack/echo/v4"
-	DB "github.com/muhammadardie/echo-cms/db"
+	"github.com/muhammadardie/echo-cms/crud"
 	"github.com/muhammadardie/echo-cms/utils"
-	"github.com/rs/xid"
-	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-var ctx = context.Background()
-
-const colName = "companies"
-
-// Get Companies godoc
-// @Summary Get recent company
-// @Contentription get most recent company
-// @ID get-companies
-// @Tags Companies
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Success 200 {object} utils.HttpSuccess{data=[]Companies}
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /companies [get]
-func Get(c echo.Context) error {
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	csr, err := db.Collection(colName).Find(ctx, bson.M{})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	defer csr.Close(ctx)
-
-	result := make([]Companies, 0)
-	if err = csr.All(ctx, &result); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(result, ""))
-}
-
-// Find Companies godoc
-// @Summary Find company by ID
-// @Description Find company by ID
-// @ID find-companies
-// @Tags Companies
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of the company to get"
-// @Success 200 {object} utils.HttpSuccess{data=Companies}
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /companies/{id} [get]
-func Find(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
-	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
-	}
-
-	selector := bson.M{"_id": id}
-
-	var record Companies
-
-	if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
-}
-
-// Create Companies godoc
-// @Summary Create company
-// @Description Create a company content
-// @ID create-companies
-// @Tags Companies
-// @Accept  mpfd
-// @Produce  json
-// @Security Bearer
-// @Param image formData file true "Blog image"
-// @Param title formData string true "Blog title"
-// @Param desc formData string true "Blog description"
-// @Success 200 {object} Companies
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /companies [post]
-func Create(c echo.Context) error {
-	/* upload image first */
-	file, err := c.FormFile("image")
-	if err != nil {
-		return err
-	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-	}
-
-	src, err := file.Open()
-	if err != nil {
-		return err
-	}
-	defer src.Close()
-
-	// Destination
-	path := "./uploaded_files/company/"
-	name := file.Filename
-	extension := filepath.Ext(name)
-	filename := xid.New().String() + extension
-
-	dst, err := os.OpenFile(path+filename, os.O_WRONLY|os.O_CREATE, 0666)
-	if err != nil {
-		return err
-	}
-	defer dst.Close()
-
-	// Copy
-	if _, err = io.Copy(dst, src); err != nil {
-		return err
-	}
-	/* end upload image */
-
-	/* store record to db */
-	companiesRecord := &Companies{
-		ID:        primitive.NewObjectID(),
-		Title:     c.FormValue("title"),
-		Desc:      c.FormValue("desc"),
-		Image:     filename,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
-	}
-
-	_, err = db.Collection(colName).InsertOne(ctx, companiesRecord)
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(companiesRecord, "Saved"))
-}
-
-// Update Blog godoc
-// @Summary Update company
-// @Description Update company
-// @ID update-company
-// @Tags Companies
-// @Accept  mpfd
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of company to get"
-// @Param image formData file false "Blog image"
-// @Param title formData string false "Blog title"
-// @Param desc formData string false "Blog description"
-// @Success 200 {object} Companies
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /companies/{id} [put]
-func Update(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
-	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
-	}
-
-	selector := bson.M{"_id": id}
-
-	changes := &Companies{
-		Title: c.FormValue("title"),
-		Desc:  c.FormValue("desc"),
-		Image: "",
-	}
-
-	/* check image exist first */
-	file, err := c.FormFile("image")
-	// if no error then there is valid image request
-	if err == nil {
-		/* delete existing file if exist */
-		path := "./uploaded_files/company/"
-		var record Companies
-
-		if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-			return echo.NewHTTPError(http.StatusBadRequest, err)
-		}
-
-		if record.Image != "" {
-			filePath := path + record.Image
-
-			// Check if the file exists
-			if _, err := os.Stat(filePath); err == nil {
-				// File exists, proceed to delete
-				err := os.Remove(filePath)
-				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, err)
-				}
-			}
-		}
-
-		/* upload new file */
-		name := file.Filename
-		extension := filepath.Ext(name)
-		filename := xid.New().String() + extension
-
-		src, err := file.Open()
-		if err != nil {
-			return err
-		}
-		defer src.Close()
-
-		// Destination
-		dst, err := os.OpenFile(path+filename, os.O_WRONLY|os.O_CREATE, 0666)
-		if err != nil {
-			return err
-		}
-		defer dst.Close()
-
-		// Copy
-		if _, err = io.Copy(dst, src); err != nil {
-			return err
-		}
-
-		changes.Image = filename
-	}
-
-	update, err := db.Collection(colName).UpdateOne(ctx, selector, bson.M{"$set": changes})
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(update, "Updated"))
-
-}
-
-// Delete Blog godoc
-// @Summary Delete a company
-// @Description Delete a company
-// @ID delete-company
-// @Tags Companies
-// @Accept  json
-// @Produce  json
-// @Security Bearer
-// @Param id path string true "ID of the company"
-// @Success 200 {object} Companies
-// @Failure 400 {object} utils.HttpError
-// @Failure 401 {object} utils.HttpError
-// @Router /companies/{id} [delete]
-func Destroy(c echo.Context) error {
-	id, err := primitive.ObjectIDFromHex(c.Param("id"))
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
-	}
-
-	db, err := DB.Connect()
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
-	}
-
-	selector := bson.M{"_id": id}
-
-	/* delete any exist image */
-	path := "./uploaded_files/company/"
-	var record Companies
-
-	if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	if record.Image != "" {
-		filePath := path + record.Image
-
-		// Check if the file exists
-		if _, err := os.Stat(filePath); err == nil {
-			// File exists, proceed to delete
-			err := os.Remove(filePath)
-			if err != nil {
-				return echo.NewHTTPError(http.StatusBadRequest, err)
-			}
-		}
-	}
-
-	/* delete record */
-	result, err := db.Collection(colName).DeleteOne(ctx, selector)
-
-	if err != nil {
-		return echo.NewHTTPError(http.StatusBadRequest, err)
-	}
-
-	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
-}
+// resource serves the companies from the model, see crud.Options
+var resource = crud.New(crud.Options{
+	Collection: "companies",
+	Model:      Companies{},
+	ListFields: utils.Fields{
+		"title": "title",
+	},
+	UploadDir:    "company",
+	Translatable: []string{"title", "desc"},
+	Public:       true,
+})
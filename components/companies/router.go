@@ -5,10 +5,5 @@ import (
 )
 
 func CompaniesRegister(g *echo.Group) {
-	companies := g.Group("/companies")
-	companies.GET("", Get)
-	companies.POST("", Create)
-	companies.GET("/:id", Find)
-	companies.PUT("/:id", Update)
-	companies.DELETE("/:id", Destroy)
+	resource.Register(g)
 }
@@ -5,11 +5,5 @@ import (
 )
 
 func ContactsRegister(g *echo.Group) {
-	contacts := g.Group("/contacts")
-	contacts.GET("", Get)
-	contacts.POST("", Create)
-	contacts.GET("/:id", Find)
-	contacts.PUT("/:id", Update)
-	contacts.DELETE("/:id", Destroy)
-
+	resource.Register(g)
 }
@@ -1,16 +1,25 @@
 package galleries
 
 import (
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/images"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Galleries struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Url       string             `json:"url" bson:"url" form:"url" query:"url" validate:"required"`
-	Title     string             `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
-	Desc      string             `json:"desc" bson:"desc,omitempty" form:"desc" query:"desc" validate:"required"`
-	Image     string             `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Url           string             `json:"url" bson:"url" form:"url" query:"url" validate:"required"`
+	Title         string             `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
+	Slug          string             `json:"slug" bson:"slug,omitempty" form:"slug" query:"slug"`
+	PreviousSlugs []string           `json:"previousSlugs,omitempty" bson:"previous_slugs,omitempty"`
+	Desc          string             `json:"desc" bson:"desc,omitempty" form:"desc" query:"desc" validate:"required"`
+	Image         string             `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
+	Variants      *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+	Media         primitive.ObjectID `json:"media,omitempty" bson:"media,omitempty" form:"media" query:"media"`
+	Version       int64              `json:"version" bson:"version" form:"version"`
+	Translations  i18n.Translations  `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
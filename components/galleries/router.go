@@ -5,11 +5,5 @@ import (
 )
 
 func GalleriesRegister(g *echo.Group) {
-	galleries := g.Group("/galleries")
-	galleries.GET("", Get)
-	galleries.POST("", Create)
-	galleries.GET("/:id", Find)
-	galleries.PUT("/:id", Update)
-	galleries.DELETE("/:id", Destroy)
-
+	resource.Register(g)
 }
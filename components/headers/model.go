@@ -1,16 +1,23 @@
 package headers
 
 import (
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/images"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Headers struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Image     string             `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
-	Page      string             `json:"page" bson:"page" form:"page" query:"page" validate:"required"`
-	Tagline   string             `json:"tagline" bson:"tagline,omitempty" form:"tagline" query:"content" validate:"required"`
-	Tagdesc   string             `json:"tagdesc,omitempty" bson:"tagdesc,omitempty" form:"tagdesc" query:"image" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	ID           primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Image        string             `json:"image,omitempty" bson:"image,omitempty" form:"image" query:"image" validate:"required"`
+	Variants     *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+	Media        primitive.ObjectID `json:"media,omitempty" bson:"media,omitempty" form:"media" query:"media"`
+	Page         string             `json:"page" bson:"page" form:"page" query:"page" validate:"required"`
+	Tagline      string             `json:"tagline" bson:"tagline,omitempty" form:"tagline" query:"content" validate:"required"`
+	Tagdesc      string             `json:"tagdesc,omitempty" bson:"tagdesc,omitempty" form:"tagdesc" query:"image" validate:"required"`
+	Version      int64              `json:"version" bson:"version" form:"version"`
+	Translations i18n.Translations  `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt    time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt    *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
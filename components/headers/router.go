@@ -2,14 +2,10 @@ package headers
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
 )
 
 func HeadersRegister(g *echo.Group) {
-	headers := g.Group("/headers")
-	headers.GET("/page/:pagename", FindByPage)
-	headers.GET("", Get)
-	headers.POST("", Create)
-	headers.GET("/:id", Find)
-	headers.PUT("/:id", Update)
-	headers.DELETE("/:id", Destroy)
+	g.GET("/headers/page/:pagename", FindByPage, rbac.Allow(rbac.ReadContent))
+	resource.Register(g)
 }
@@ -0,0 +1,626 @@
+package media
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/images"
+	"github.com/muhammadardie/echo-cms/patch"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/storage"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "media"
+
+// directory of the uploaded files in the storage
+const uploadDir = "media"
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"name":        "name",
+	"tag":         "tags",
+	"contentType": "content_type",
+}
+
+// Get Media godoc
+// @Summary Get media library
+// @Description Get media library, search by name, alt text and tags
+// @ID get-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param q query string false "Search name, alt text and tags"
+// @Param tag query string false "Filter by tag"
+// @Param contentType query string false "Filter by content type"
+// @Success 200 {object} utils.HttpSuccess{data=[]Media}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /media [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Media{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Where(trash.Active(bson.M{}))
+
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		query.Where(bson.M{"$or": bson.A{
+			bson.M{"name": pattern},
+			bson.M{"alt": pattern},
+			bson.M{"tags": pattern},
+		}})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Media, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Media godoc
+// @Summary Find media by ID
+// @Description Find media by ID
+// @ID find-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the media to get"
+// @Success 200 {object} utils.HttpSuccess{data=Media}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /media/{id} [get]
+func Find(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Media
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// FindPublic Media godoc
+// @Summary Find media by ID on the public site
+// @Description Find a media item by ID, without the records using it
+// @ID find-public-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Param id path string true "ID of the media to get"
+// @Success 200 {object} utils.HttpSuccess{data=PublicMedia}
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/media/{id} [get]
+func FindPublic(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var record PublicMedia
+
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Media not found")
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// Create Media godoc
+// @Summary Upload media
+// @Description Upload an image to the media library
+// @ID create-media
+// @Tags Media
+// @Accept  mpfd
+// @Produce  json
+// @Security Bearer
+// @Param file formData file true "Media image"
+// @Param name formData string false "Media name, defaults to the file name"
+// @Param alt formData string false "Media alt text"
+// @Param tags formData string false "Media tags, comma separated"
+// @Success 200 {object} Media
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 413 {object} utils.HttpError
+// @Failure 415 {object} utils.HttpError
+// @Router /media [post]
+func Create(c echo.Context) error {
+	/* upload image first */
+	file, err := c.FormFile("file")
+	if err != nil {
+		return utils.NewValidationError(utils.FieldError{Field: "file", Code: "required", Message: "file is required"})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	filename, variants, err := images.Upload(file, uploadDir)
+	if err != nil {
+		return err
+	}
+	// end upload image
+
+	name := c.FormValue("name")
+	if name == "" {
+		name = file.Filename
+	}
+
+	/* store record to db */
+	media := &Media{
+		ID:          primitive.NewObjectID(),
+		Name:        name,
+		Filename:    filename,
+		URL:         storage.URL(uploadDir, filename),
+		ContentType: mime.TypeByExtension(filepath.Ext(filename)),
+		Size:        file.Size,
+		Alt:         c.FormValue("alt"),
+		Tags:        splitTags(c.FormValue("tags")),
+		Variants:    variants,
+		References:  []Reference{},
+		Version:     1,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	err = repo.Insert(media)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionCreate, colName, media.ID, nil)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(media, "Saved"))
+}
+
+// Update Media godoc
+// @Summary Update media
+// @Description Update alt text and tags of a media
+// @ID update-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of media to get"
+// @Param alt body string false "Media alt text"
+// @Param tags body []string false "Media tags"
+// @Success 200 {object} Media
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /media/{id} [put]
+func Update(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	changes := new(UpdateMedia)
+
+	if err := c.Bind(changes); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	if err := c.Validate(changes); err != nil {
+		return err
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, changes.Version)
+	if err != nil {
+		return err
+	}
+
+	updateFields := bson.M{
+		"updated_at": time.Now(),
+	}
+
+	if changes.Alt != nil {
+		updateFields["alt"] = *changes.Alt
+	}
+
+	if changes.Tags != nil {
+		updateFields["tags"] = cleanTags(changes.Tags)
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+	if submitted != 0 {
+		selector = version.Selector(selector, submitted)
+	}
+	update := version.Bump(bson.M{"$set": updateFields})
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(selector, update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update media")
+	}
+	if result.MatchedCount == 0 && submitted != 0 {
+		return version.Reload(repo, id, new(Media))
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Updated"))
+}
+
+// Patch Media godoc
+// @Summary Change some fields of a media
+// @Description Change the name, alt text or tags given by a JSON merge patch (RFC 7396), the others are left as they are. An If-Match header, or the version of the patch, makes the change fail with 409 Conflict when the media changed since that version.
+// @ID patch-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of media to change"
+// @Param If-Match header string false "ETag, the version of the media the change is based on"
+// @Param patch body UpdateMedia true "Fields to change"
+// @Success 200 {object} utils.HttpSuccess{data=Media}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /media/{id} [patch]
+func Patch(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	doc, based, err := patch.Read(c)
+	if err != nil {
+		return err
+	}
+
+	submitted, err := version.Submitted(c, based)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Media
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	latest := record.Version
+	if err = version.Check(submitted, latest, &record); err != nil {
+		return err
+	}
+
+	update, err := doc.Apply(&record, "name", "alt", "tags")
+	if err != nil {
+		return err
+	}
+
+	if _, ok := doc["tags"]; ok && record.Tags != nil {
+		record.Tags = cleanTags(record.Tags)
+		update["$set"].(bson.M)["tags"] = record.Tags
+	}
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update media")
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, new(Media))
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Updated"))
+}
+
+// Delete Media godoc
+// @Summary Delete a media
+// @Description Delete a media, refused while the media is still in use
+// @ID delete-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the media"
+// @Success 200 {object} Media
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /media/{id} [delete]
+func Destroy(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Media
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if len(record.References) > 0 {
+		used := make([]string, 0, len(record.References))
+		for _, ref := range record.References {
+			used = append(used, ref.Collection+"/"+ref.ID.Hex())
+		}
+
+		return echo.NewHTTPError(http.StatusConflict,
+			fmt.Sprintf("Media is still used by %s", strings.Join(used, ", ")))
+	}
+
+	/* move record to the trash only while it is still unused */
+	selector["references"] = bson.M{"$size": 0}
+	before := audits.Snapshot(colName, id)
+
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return echo.NewHTTPError(http.StatusConflict, "Media is still in use")
+	}
+
+	audits.Record(c, audits.ActionDelete, colName, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// ReadImage reads the image of a content record from the request, either a
+// file uploaded in field and stored in dir, or the ID of a media item in the
+// "media" form value. It returns nil when the request has neither.
+func ReadImage(c echo.Context, field string, dir string) (*Image, error) {
+	return ReadImageField(c, field, "media", dir)
+}
+
+// ReadImageField is ReadImage for records with several images, the ID of
+// the media item is read from mediaField instead
+func ReadImageField(c echo.Context, field string, mediaField string, dir string) (*Image, error) {
+	if mediaID := c.FormValue(mediaField); mediaID != "" {
+		id, err := primitive.ObjectIDFromHex(mediaID)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid media ID")
+		}
+
+		repo, err := repository.Open(colName)
+		if err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+		}
+
+		var record Media
+		if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &record); err != nil {
+			return nil, echo.NewHTTPError(http.StatusBadRequest, "Media not found")
+		}
+
+		return &Image{Variants: record.Variants, Media: record.ID}, nil
+	}
+
+	file, err := c.FormFile(field)
+	if err != nil {
+		return nil, nil
+	}
+
+	filename, variants, err := images.Upload(file, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Image{Filename: filename, Variants: variants}, nil
+}
+
+// Use records that the content record uses the media item of the image,
+// uploaded images have nothing to record
+func Use(image *Image, ref Reference) error {
+	if image == nil || image.Media.IsZero() {
+		return nil
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return err
+	}
+
+	result, err := repo.Update(trash.Active(bson.M{"_id": image.Media}),
+		bson.M{"$addToSet": bson.M{"references": ref}})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return fmt.Errorf("media %s no longer exists", image.Media.Hex())
+	}
+
+	return nil
+}
+
+// Release frees the image of a content record, uploaded files are removed
+// from dir and media items forget the reference of the record
+func Release(image *Image, dir string, ref Reference) error {
+	if image != nil && image.Media.IsZero() {
+		return images.Remove(dir, image.Filename, image.Variants)
+	}
+
+	return Detach(image, ref)
+}
+
+// Detach frees the image of a content record but keeps uploaded files,
+// they are still used by the revisions of the record
+func Detach(image *Image, ref Reference) error {
+	if image == nil || image.Media.IsZero() {
+		return nil
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return err
+	}
+
+	_, err = repo.Update(bson.M{"_id": image.Media},
+		bson.M{"$pull": bson.M{"references": ref}})
+
+	return err
+}
+
+// Unset returns the fields of a content record to clear when its image is
+// replaced, field is the name of the uploaded file field
+func Unset(image *Image, field string) bson.M {
+	if image.Media.IsZero() {
+		return bson.M{"media": ""}
+	}
+
+	return bson.M{field: ""}
+}
+
+func splitTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+
+	return cleanTags(strings.Split(tags, ","))
+}
+
+func cleanTags(tags []string) []string {
+	result := make([]string, 0, len(tags))
+	seen := map[string]bool{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		seen[tag] = true
+		result = append(result, tag)
+	}
+
+	return result
+}
+
+// Trash Media godoc
+// @Summary Get deleted media
+// @Description Get the media in the trash, they are purged for good after the retention period
+// @ID trash-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Media}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /media/trash [get]
+func Trash(c echo.Context) error {
+	return trash.List(c, colName, &[]Media{})
+}
+
+// Restore Media godoc
+// @Summary Restore deleted media
+// @Description Take a record of media out of the trash
+// @ID restore-media
+// @Tags Media
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the deleted record"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /media/{id}/restore [post]
+func Restore(c echo.Context) error {
+	return trash.Restore(c, colName)
+}
+
+// purge removes the files of a media deleted for good
+func purge(id primitive.ObjectID) error {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return err
+	}
+
+	var record Media
+
+	if err = repo.FindByID(id, &record); err != nil {
+		return err
+	}
+
+	return images.Remove(uploadDir, record.Filename, record.Variants)
+}
@@ -0,0 +1,41 @@
+package media
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPublicMediaHidesReferences(t *testing.T) {
+	repository.Use(repository.NewMemory())
+	repo, _ := repository.Open(colName)
+
+	draft := primitive.NewObjectID()
+	item := Media{ID: primitive.NewObjectID(), Name: "photo", Filename: "photo.jpg", URL: "/uploaded_files/photo.jpg", References: []Reference{{Collection: "blogs", ID: draft}}, Version: 1}
+	if err := repo.Insert(item); err != nil {
+		t.Fatal(err)
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+	e.GET("/public/media/:id", FindPublic)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/media/"+item.ID.Hex(), nil))
+
+	body := rec.Body.String()
+	if rec.Code != http.StatusOK || !strings.Contains(body, "photo.jpg") {
+		t.Fatalf("public media = %d %s", rec.Code, body)
+	}
+	if strings.Contains(body, "references") || strings.Contains(body, draft.Hex()) {
+		t.Fatalf("public media shows the records using it: %s", body)
+	}
+}
@@ -0,0 +1,58 @@
+package media
+
+import (
+	"github.com/muhammadardie/echo-cms/images"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Media struct {
+	ID          primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name        string             `json:"name" bson:"name" form:"name" query:"name"`
+	Filename    string             `json:"filename" bson:"filename"`
+	URL         string             `json:"url" bson:"url"`
+	ContentType string             `json:"contentType" bson:"content_type"`
+	Size        int64              `json:"size" bson:"size"`
+	Alt         string             `json:"alt" bson:"alt" form:"alt" query:"alt"`
+	Tags        []string           `json:"tags" bson:"tags" form:"tags" query:"tags"`
+	Variants    *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+	References  []Reference        `json:"references" bson:"references"`
+	Version     int64              `json:"version" bson:"version" form:"version"`
+	CreatedAt   time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt   *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// PublicMedia is a media item shown on the public site, without the
+// records using it, which may be drafts or in the trash
+type PublicMedia struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id"`
+	Name        string             `json:"name" bson:"name"`
+	Filename    string             `json:"filename" bson:"filename"`
+	URL         string             `json:"url" bson:"url"`
+	ContentType string             `json:"contentType" bson:"content_type"`
+	Size        int64              `json:"size" bson:"size"`
+	Alt         string             `json:"alt" bson:"alt"`
+	Tags        []string           `json:"tags" bson:"tags"`
+	Variants    *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+}
+
+type UpdateMedia struct {
+	Alt     *string  `json:"alt" form:"alt"`
+	Tags    []string `json:"tags" form:"tags"`
+	Version int64    `json:"version,omitempty" form:"version"`
+}
+
+// Reference is a content record using a media item
+type Reference struct {
+	Collection string             `json:"collection" bson:"collection"`
+	ID         primitive.ObjectID `json:"id" bson:"id"`
+}
+
+// Image is the image of a content record, uploaded with the record or
+// picked from the media library
+type Image struct {
+	Filename string
+	Variants *images.Variants
+	Media    primitive.ObjectID
+}
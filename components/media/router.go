@@ -0,0 +1,21 @@
+package media
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/trash"
+)
+
+func MediaRegister(g *echo.Group) {
+	trash.Register(colName, purge)
+
+	media := g.Group("/media")
+	media.GET("", Get, rbac.Allow(rbac.ReadContent))
+	media.GET("/trash", Trash, rbac.Allow(rbac.ReadContent))
+	media.POST("", Create, rbac.Allow(rbac.WriteBlogs))
+	media.GET("/:id", Find, rbac.Allow(rbac.ReadContent))
+	media.PUT("/:id", Update, rbac.Allow(rbac.WriteContent))
+	media.PATCH("/:id", Patch, rbac.Allow(rbac.WriteContent))
+	media.DELETE("/:id", Destroy, rbac.Allow(rbac.WriteContent))
+	media.POST("/:id/restore", Restore, rbac.Allow(rbac.WriteContent))
+}
@@ -0,0 +1,398 @@
+package messages
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "contact_messages"
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"name":    "name",
+	"email":   "email",
+	"subject": "subject",
+	"status":  "status",
+}
+
+// Get Messages godoc
+// @Summary Get contact messages
+// @Description Get the messages of the visitors, the unread and read ones unless ?status= asks for the archived or spam ones
+// @ID get-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param q query string false "Search name, email, subject and message"
+// @Param status query string false "Filter by status (unread, read, archived, spam)"
+// @Param name query string false "Filter by name"
+// @Param email query string false "Filter by email"
+// @Param subject query string false "Filter by subject"
+// @Success 200 {object} utils.HttpSuccess{data=[]Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /contact-messages [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Messages{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Where(trash.Active(bson.M{}))
+
+	if c.QueryParam("status") == "" {
+		query.Where(bson.M{"status": bson.M{"$in": bson.A{StatusUnread, StatusRead}}})
+	}
+
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		query.Where(bson.M{"$or": bson.A{
+			bson.M{"name": pattern},
+			bson.M{"email": pattern},
+			bson.M{"subject": pattern},
+			bson.M{"message": pattern},
+		}})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Messages, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Messages godoc
+// @Summary Find contact message by ID
+// @Description Find contact message by ID, reading it does not mark it as read
+// @ID find-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message to get"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /contact-messages/{id} [get]
+func Find(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var record Messages
+
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// MarkRead Messages godoc
+// @Summary Mark contact message as read
+// @Description Mark contact message as read
+// @ID read-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Param If-Match header string false "ETag of the message the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /contact-messages/{id}/read [post]
+func MarkRead(c echo.Context) error {
+	return setStatus(c, StatusRead)
+}
+
+// MarkUnread Messages godoc
+// @Summary Mark contact message as unread
+// @Description Mark contact message as unread
+// @ID unread-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Param If-Match header string false "ETag of the message the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /contact-messages/{id}/unread [post]
+func MarkUnread(c echo.Context) error {
+	return setStatus(c, StatusUnread)
+}
+
+// Archive Messages godoc
+// @Summary Archive contact message
+// @Description Take the contact message out of the inbox
+// @ID archive-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Param If-Match header string false "ETag of the message the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /contact-messages/{id}/archive [post]
+func Archive(c echo.Context) error {
+	return setStatus(c, StatusArchived)
+}
+
+// Spam Messages godoc
+// @Summary Mark contact message as spam
+// @Description Take the contact message out of the inbox as spam
+// @ID spam-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Param If-Match header string false "ETag of the message the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /contact-messages/{id}/spam [post]
+func Spam(c echo.Context) error {
+	return setStatus(c, StatusSpam)
+}
+
+// setStatus moves the message of the ID to another state
+func setStatus(c echo.Context, status string) error {
+	return change(c, 0, func(record *Messages) (bson.M, error) {
+		record.Status = status
+
+		return bson.M{"status": status}, nil
+	})
+}
+
+// Reply Messages godoc
+// @Summary Reply to contact message
+// @Description Mail the reply to the visitor and keep it with the message, which is marked as read. The delivery log tells when the mail is sent.
+// @ID reply-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Param body body string true "Text of the reply"
+// @Param If-Match header string false "ETag of the message the reply is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Failure 502 {object} utils.HttpError
+// @Router /contact-messages/{id}/replies [post]
+func CreateReply(c echo.Context) error {
+	reply := new(NewReply)
+	if err := c.Bind(reply); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	reply.Body = strings.TrimSpace(reply.Body)
+	if err := utils.Validate(c, reply); err != nil {
+		return err
+	}
+
+	return change(c, reply.Version, func(record *Messages) (bson.M, error) {
+		/* the reply is only kept once it is queued for the visitor */
+		data := mail.Data{"Name": record.Name, "Subject": record.Subject, "Message": record.Message, "Reply": reply.Body}
+		if err := mail.Send(templateReply, []string{record.Email}, "", data); err != nil {
+			c.Logger().Error(err)
+			return nil, echo.NewHTTPError(http.StatusBadGateway, "Failed to send reply")
+		}
+
+		sent := Reply{Body: reply.Body, SentAt: time.Now()}
+		if actor := rbac.GetActor(c); actor != nil {
+			sent.UserId = actor.UserId
+		}
+
+		record.Replies = append(record.Replies, sent)
+		record.Status = StatusRead
+
+		return bson.M{"replies": record.Replies, "status": record.Status}, nil
+	})
+}
+
+// change applies the changes made by apply to the message of the ID, the
+// version sent in If-Match or in the body must be the current one
+func change(c echo.Context, body int64, apply func(record *Messages) (bson.M, error)) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, body)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Messages
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	if err = version.Check(submitted, record.Version, &record); err != nil {
+		return err
+	}
+
+	set, err := apply(&record)
+	if err != nil {
+		return err
+	}
+
+	record.UpdatedAt = time.Now()
+	set["updated_at"] = record.UpdatedAt
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, record.Version), version.Bump(bson.M{"$set": set}))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, &Messages{})
+	}
+	record.Version++
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Updated"))
+}
+
+// Delete Messages godoc
+// @Summary Delete contact message
+// @Description Move the contact message to the trash
+// @ID delete-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the message"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /contact-messages/{id} [delete]
+func Destroy(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	before := audits.Snapshot(colName, id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionDelete, colName, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// Trash Messages godoc
+// @Summary Get deleted contact messages
+// @Description Get the contact messages in the trash, they are purged for good after the retention period
+// @ID trash-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Messages}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /contact-messages/trash [get]
+func Trash(c echo.Context) error {
+	return trash.List(c, colName, &[]Messages{})
+}
+
+// Restore Messages godoc
+// @Summary Restore deleted contact messages
+// @Description Take a contact message out of the trash
+// @ID restore-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the deleted record"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contact-messages/{id}/restore [post]
+func Restore(c echo.Context) error {
+	return trash.Restore(c, colName)
+}
@@ -0,0 +1,24 @@
+package messages
+
+import "github.com/muhammadardie/echo-cms/mail"
+
+const (
+	// mail telling the staff of a new message
+	templateMessage = "contact-message"
+	// mail of a reply to the visitor
+	templateReply = "contact-reply"
+)
+
+func init() {
+	mail.Register(templateMessage, "Sent to the staff of MAIL_STAFF when a visitor sends a message",
+		`New message from {{.Name}}{{if .Subject}}: {{.Subject}}{{end}}`,
+		"{{.Name}} <{{.Email}}> wrote:\n\n{{.Message}}\n",
+		`<p><strong>{{.Name}}</strong> &lt;{{.Email}}&gt; wrote:</p><blockquote style="white-space: pre-wrap">{{.Message}}</blockquote>`,
+		mail.Data{"Name": "Jane Doe", "Email": "jane@example.com", "Subject": "Opening hours", "Message": "Are you open on Sundays?"})
+
+	mail.Register(templateReply, "Sent to the visitor when the staff replies to their message",
+		`Re: {{if .Subject}}{{.Subject}}{{else}}your message{{end}}`,
+		"{{.Reply}}\n\n---\n{{.Name}} wrote:\n{{.Message}}\n",
+		`<p style="white-space: pre-wrap">{{.Reply}}</p><hr><p>{{.Name}} wrote:</p><blockquote style="white-space: pre-wrap">{{.Message}}</blockquote>`,
+		mail.Data{"Name": "Jane Doe", "Subject": "Opening hours", "Message": "Are you open on Sundays?", "Reply": "Yes, from 10am to 4pm."})
+}
@@ -0,0 +1,74 @@
+package messages
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// newServer serves the contact form from an empty memory driver, the mails
+// to the staff go through a memory queue to the fake transport
+func newServer() (*echo.Echo, *mail.Fake) {
+	repository.Use(repository.NewMemory())
+	mail.UseQueue(mail.NewMemory())
+	fake := mail.NewFake()
+	mail.Use(fake)
+	os.Setenv("MAIL_STAFF", "staff@example.com")
+
+	e := echo.New()
+	e.Validator = middleware.NewValidator()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+
+	e.POST("/public/contact-messages", CreatePublic)
+
+	return e, fake
+}
+
+func send(e *echo.Echo, doc string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/public/contact-messages", strings.NewReader(doc))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestStaffIsNotified(t *testing.T) {
+	e, fake := newServer()
+
+	if rec := send(e, `{"name":"Jane","email":"jane@example.com","subject":"Hours","message":"Are you open on Sundays?"}`); rec.Code != http.StatusAccepted {
+		t.Fatalf("send = %d %s", rec.Code, rec.Body.String())
+	}
+	spam := `{"name":"Bot","email":"bot@example.com","message":"http://a http://b http://c http://d"}`
+	if rec := send(e, spam); rec.Code != http.StatusAccepted {
+		t.Fatalf("send spam = %d %s", rec.Code, rec.Body.String())
+	}
+
+	if err := mail.Process(e.Logger); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 {
+		t.Fatalf("sent = %+v", sent)
+	}
+	if sent[0].To[0] != "staff@example.com" || sent[0].ReplyTo != "jane@example.com" || sent[0].Subject != "New message from Jane: Hours" {
+		t.Fatalf("notification = %+v", sent[0])
+	}
+
+	repo, _ := repository.Open(colName)
+	if total, err := repo.Count(bson.M{"status": StatusSpam}); err != nil || total != 1 {
+		t.Fatalf("spam = %d %v", total, err)
+	}
+}
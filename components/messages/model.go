@@ -0,0 +1,52 @@
+package messages
+
+import (
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+const (
+	StatusUnread   = "unread"
+	StatusRead     = "read"
+	StatusArchived = "archived"
+	StatusSpam     = "spam"
+)
+
+type Messages struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name"`
+	Email     string             `json:"email" bson:"email"`
+	Subject   string             `json:"subject,omitempty" bson:"subject,omitempty"`
+	Message   string             `json:"message" bson:"message"`
+	Status    string             `json:"status" bson:"status"`
+	Replies   []Reply            `json:"replies,omitempty" bson:"replies,omitempty"`
+	IP        string             `json:"ip,omitempty" bson:"ip,omitempty"`
+	UserAgent string             `json:"userAgent,omitempty" bson:"user_agent,omitempty"`
+	Version   int64              `json:"version" bson:"version"`
+	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// Reply is an answer sent by the staff to the visitor
+type Reply struct {
+	Body   string    `json:"body" bson:"body"`
+	UserId string    `json:"userId" bson:"user_id"`
+	SentAt time.Time `json:"sentAt" bson:"sent_at"`
+}
+
+// NewMessage is the message sent from the contact form, Website is a
+// honeypot hidden from the people filling the form
+type NewMessage struct {
+	Name    string `json:"name" form:"name" validate:"required,max=100"`
+	Email   string `json:"email" form:"email" validate:"required,email"`
+	Subject string `json:"subject" form:"subject" validate:"max=200"`
+	Message string `json:"message" form:"message" validate:"required,max=5000"`
+	Website string `json:"website" form:"website"`
+}
+
+// NewReply is the answer to a message written in the inbox
+type NewReply struct {
+	Body    string `json:"body" form:"body" validate:"required,max=10000"`
+	Version int64  `json:"version" form:"version"` // version the reply is based on
+}
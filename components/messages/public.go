@@ -0,0 +1,108 @@
+package messages
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// messages a visitor may send per minute when MESSAGES_RATE_LIMIT is not set
+const defaultRateLimit = 3
+
+// messages with more links than this go to spam without notifying the staff
+const maxLinks = 3
+
+var links = regexp.MustCompile(`(?i)https?://|www\.`)
+
+// RateLimit limits the messages sent from an IP, configured by
+// MESSAGES_RATE_LIMIT as a number of messages per minute
+func RateLimit() echo.MiddlewareFunc {
+	return utils.RateLimit("MESSAGES_RATE_LIMIT", defaultRateLimit, "Too many messages, try again in a minute")
+}
+
+// CreatePublic Messages godoc
+// @Summary Send a message to the staff
+// @Description Send a message from the contact form, the staff of MAIL_STAFF is told by mail. Messages are limited per IP by MESSAGES_RATE_LIMIT per minute.
+// @ID create-public-contact-messages
+// @Tags Messages
+// @Accept  json
+// @Produce  json
+// @Param name body string true "Name of the visitor"
+// @Param email body string true "Email the staff replies to"
+// @Param subject body string false "Subject of the message"
+// @Param message body string true "Text of the message"
+// @Success 202 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Failure 429 {object} utils.HttpError
+// @Router /public/contact-messages [post]
+func CreatePublic(c echo.Context) error {
+	message := new(NewMessage)
+	if err := c.Bind(message); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	accepted := utils.NewSuccess(nil, "Message sent")
+	accepted.Code = http.StatusAccepted
+
+	/* only bots fill the hidden field, they are told the message was taken */
+	if message.Website != "" {
+		return c.JSON(http.StatusAccepted, accepted)
+	}
+
+	message.Name = strings.TrimSpace(message.Name)
+	message.Email = strings.TrimSpace(message.Email)
+	message.Subject = strings.TrimSpace(message.Subject)
+	message.Message = strings.TrimSpace(message.Message)
+	if err := utils.Validate(c, message); err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	now := time.Now()
+	record := &Messages{
+		ID:        primitive.NewObjectID(),
+		Name:      message.Name,
+		Email:     message.Email,
+		Subject:   message.Subject,
+		Message:   message.Message,
+		Status:    StatusUnread,
+		IP:        c.RealIP(),
+		UserAgent: c.Request().UserAgent(),
+		Version:   1,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if len(links.FindAllStringIndex(record.Subject+" "+record.Message, -1)) > maxLinks {
+		record.Status = StatusSpam
+	}
+
+	if err = repo.Insert(record); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save message")
+	}
+
+	audits.Record(c, audits.ActionCreate, colName, record.ID, nil)
+
+	if record.Status != StatusSpam {
+		data := mail.Data{"Name": record.Name, "Email": record.Email, "Subject": record.Subject, "Message": record.Message}
+
+		// the message is in the inbox even when the staff can't be told
+		if err = mail.Send(templateMessage, mail.Staff(), record.Email, data); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	return c.JSON(http.StatusAccepted, accepted)
+}
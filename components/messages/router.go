@@ -0,0 +1,23 @@
+package messages
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/trash"
+)
+
+func MessagesRegister(g *echo.Group) {
+	trash.Register(colName, nil)
+
+	messages := g.Group("/contact-messages")
+	messages.GET("", Get, rbac.Allow(rbac.ManageMessages))
+	messages.GET("/trash", Trash, rbac.Allow(rbac.ManageMessages))
+	messages.GET("/:id", Find, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/read", MarkRead, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/unread", MarkUnread, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/archive", Archive, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/spam", Spam, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/replies", CreateReply, rbac.Allow(rbac.ManageMessages))
+	messages.DELETE("/:id", Destroy, rbac.Allow(rbac.ManageMessages))
+	messages.POST("/:id/restore", Restore, rbac.Allow(rbac.ManageMessages))
+}
@@ -0,0 +1,367 @@
+package revisions
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/cache"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/images"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "revisions"
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"collection": "collection",
+	"user":       "user_id",
+}
+
+// Get Revisions godoc
+// @Summary Get revisions
+// @Description Get the previous versions of content records, most recent first
+// @ID get-revisions
+// @Tags Revisions
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param collection query string false "Filter by collection"
+// @Param document query string false "Filter by ID of the content record"
+// @Param user query string false "Filter by ID of the user who made the change"
+// @Success 200 {object} utils.HttpSuccess{data=[]Revisions}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /revisions [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Revisions{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if document := c.QueryParam("document"); document != "" {
+		id, err := primitive.ObjectIDFromHex(document)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid document ID")
+		}
+		query.Where(bson.M{"document_id": id})
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Revisions, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Revisions godoc
+// @Summary Find revision by ID
+// @Description Find revision by ID
+// @ID find-revisions
+// @Tags Revisions
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the revision to get"
+// @Success 200 {object} utils.HttpSuccess{data=Revisions}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /revisions/{id} [get]
+func Find(c echo.Context) error {
+	revision, err := load(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(revision, ""))
+}
+
+// Compare Revisions godoc
+// @Summary Compare revisions
+// @Description Get the fields changed between a revision and another revision of the same record, or the current record
+// @ID diff-revisions
+// @Tags Revisions
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the revision"
+// @Param to query string false "ID of the revision to compare with, defaults to the current record"
+// @Success 200 {object} utils.HttpSuccess{data=[]audits.Change}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /revisions/{id}/diff [get]
+func Compare(c echo.Context) error {
+	revision, err := load(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	var to bson.M
+
+	if other := c.QueryParam("to"); other != "" {
+		target, err := load(other)
+		if err != nil {
+			return err
+		}
+
+		if target.Collection != revision.Collection || target.DocumentId != revision.DocumentId {
+			return echo.NewHTTPError(http.StatusBadRequest, "Revisions belong to different records")
+		}
+
+		to = target.Document
+	} else {
+		if to = audits.Snapshot(revision.Collection, revision.DocumentId); to == nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Record of the revision no longer exists")
+		}
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(audits.Diff(revision.Document, to), ""))
+}
+
+// Restore Revisions godoc
+// @Summary Restore revision
+// @Description Replace the content record by the revision, image included and slug excluded: the record keeps its current URL. The replaced version is kept as a new revision.
+// @ID restore-revisions
+// @Tags Revisions
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the revision to restore"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /revisions/{id}/restore [post]
+func Restore(c echo.Context) error {
+	revision, err := load(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	src, ok := source(revision.Collection)
+	if !ok {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("Revisions of %s can not be restored", revision.Collection))
+	}
+
+	repo, err := repository.Open(revision.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	before := audits.Snapshot(revision.Collection, revision.DocumentId)
+	if before == nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record of the revision no longer exists")
+	}
+
+	if _, deleted := before[trash.Field]; deleted {
+		return echo.NewHTTPError(http.StatusConflict, "Record of the revision is in the trash, restore it first")
+	}
+
+	/* use the images of the revision ahead of the write, undone when it fails */
+	ref := media.Reference{Collection: revision.Collection, ID: revision.DocumentId}
+	current := map[string]*media.Image{}
+	restored := map[string]*media.Image{}
+	if src.Images != nil {
+		current = src.Images(before)
+		restored = src.Images(revision.Document)
+	}
+
+	used := make([]*media.Image, 0)
+	for field, image := range restored {
+		if sameMedia(image, current[field]) {
+			continue
+		}
+
+		if err = media.Use(image, ref); err != nil {
+			detach(c, used, ref)
+			return echo.NewHTTPError(http.StatusConflict, err.Error())
+		}
+		used = append(used, image)
+	}
+
+	document := bson.M{}
+	for field, value := range revision.Document {
+		document[field] = value
+	}
+
+	/* the URLs stay as they are, the slug of the revision may be used by another document by now */
+	for _, field := range []string{slug.Field, slug.PreviousField} {
+		if value, ok := before[field]; ok {
+			document[field] = value
+		} else {
+			delete(document, field)
+		}
+	}
+
+	latest := version.Of(before)
+	document["updated_at"] = time.Now()
+	document[version.Field] = latest + 1
+
+	result, err := repo.Replace(version.Selector(trash.Active(bson.M{"_id": revision.DocumentId}), latest), document)
+	if err != nil {
+		detach(c, used, ref)
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to restore revision")
+	}
+	if result.MatchedCount == 0 {
+		detach(c, used, ref)
+		return version.Reload(repo, revision.DocumentId, &bson.M{})
+	}
+
+	/* the replaced images are detached, their files stay with the revisions */
+	for field, image := range current {
+		if sameMedia(image, restored[field]) {
+			continue
+		}
+
+		if err = media.Detach(image, ref); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	/* keep the replaced version, the restore can be undone as well */
+	Save(c, revision.Collection, revision.DocumentId, before)
+	audits.Record(c, audits.ActionRestore, revision.Collection, revision.DocumentId, before)
+
+	if err = cache.Invalidate(revision.Collection, "search"); err != nil {
+		c.Logger().Error(err)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(document, "Restored"))
+}
+
+// Save keeps the version of a content record as it was before a change,
+// before is the snapshot taken ahead of the change. Failing to save is
+// logged without failing the request, the change has been made already.
+func Save(c echo.Context, collection string, id primitive.ObjectID, before bson.M) {
+	if _, ok := source(collection); !ok || before == nil {
+		return
+	}
+
+	// nothing changed on records in the trash
+	if _, deleted := before[trash.Field]; deleted {
+		return
+	}
+
+	revision := &Revisions{
+		ID:         primitive.NewObjectID(),
+		Collection: collection,
+		DocumentId: id,
+		Document:   before,
+		CreatedAt:  time.Now(),
+	}
+
+	if actor := rbac.GetActor(c); actor != nil {
+		revision.UserId = actor.UserId
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		c.Logger().Error(err)
+		return
+	}
+
+	if err = repo.Insert(revision); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// Purge deletes the revisions of a content record deleted for good
+// together with the files uploaded for them
+func Purge(collection string, id primitive.ObjectID) error {
+	src, ok := source(collection)
+	if !ok {
+		return nil
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return err
+	}
+
+	selector := bson.M{"collection": collection, "document_id": id}
+
+	if src.Images != nil {
+		result := make([]Revisions, 0)
+		if err = repo.FindAll(selector, nil, &result); err != nil {
+			return err
+		}
+
+		for _, revision := range result {
+			for _, image := range src.Images(revision.Document) {
+				if !image.Media.IsZero() || image.Filename == "" {
+					continue
+				}
+
+				if err = images.Remove(src.UploadDir, image.Filename, image.Variants); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	_, err = repo.DeleteAll(selector)
+
+	return err
+}
+
+// load finds a revision by its hex ID
+func load(hex string) (*Revisions, error) {
+	id, err := primitive.ObjectIDFromHex(hex)
+
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var revision Revisions
+
+	if err = repo.FindByID(id, &revision); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	return &revision, nil
+}
+
+// detach frees the images used for a restore that failed
+func detach(c echo.Context, used []*media.Image, ref media.Reference) {
+	for _, image := range used {
+		if err := media.Detach(image, ref); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+}
+
+// sameMedia reports whether both images are the same media item
+func sameMedia(image *media.Image, other *media.Image) bool {
+	return other != nil && !image.Media.IsZero() && image.Media == other.Media
+}
@@ -0,0 +1,78 @@
+package revisions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/images"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Revisions is a version of a content record as it was before a change
+type Revisions struct {
+	ID         primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Collection string             `json:"collection" bson:"collection"`
+	DocumentId primitive.ObjectID `json:"documentId" bson:"document_id"`
+	UserId     string             `json:"userId" bson:"user_id"`
+	Document   bson.M             `json:"document" bson:"document"`
+	CreatedAt  time.Time          `json:"createdAt" bson:"created_at"`
+}
+
+// Source is a collection keeping revisions
+type Source struct {
+	// UploadDir is the directory of the images uploaded for the records
+	UploadDir string
+
+	// Images returns the images of a version of a record by field, it is
+	// nil when the records have no image
+	Images func(document bson.M) map[string]*media.Image
+}
+
+/* Used to know the collections keeping revisions and their images. */
+var sources = map[string]Source{}
+var sourcesMutex sync.Mutex
+
+// Register adds a collection to the ones keeping revisions
+func Register(collection string, src Source) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	sources[collection] = src
+}
+
+// ImageField returns the images of the records storing the filename of
+// their image in field, the variants and the media next to it
+func ImageField(field string) func(document bson.M) map[string]*media.Image {
+	return func(document bson.M) map[string]*media.Image {
+		image := &media.Image{}
+		image.Filename, _ = document[field].(string)
+
+		var fields imageFields
+		if raw, err := bson.Marshal(document); err == nil {
+			if err = bson.Unmarshal(raw, &fields); err == nil {
+				image.Variants = fields.Variants
+				image.Media = fields.Media
+			}
+		}
+
+		return map[string]*media.Image{field: image}
+	}
+}
+
+// source returns how the revisions of the collection are kept
+func source(collection string) (Source, bool) {
+	sourcesMutex.Lock()
+	defer sourcesMutex.Unlock()
+
+	src, ok := sources[collection]
+
+	return src, ok
+}
+
+// imageFields are the fields every record with an image stores next to it
+type imageFields struct {
+	Variants *images.Variants   `bson:"variants,omitempty"`
+	Media    primitive.ObjectID `bson:"media,omitempty"`
+}
@@ -0,0 +1,14 @@
+package revisions
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+)
+
+func RevisionsRegister(g *echo.Group) {
+	revisions := g.Group("/revisions")
+	revisions.GET("", Get, rbac.Allow(rbac.ReadContent))
+	revisions.GET("/:id", Find, rbac.Allow(rbac.ReadContent))
+	revisions.GET("/:id/diff", Compare, rbac.Allow(rbac.ReadContent))
+	revisions.POST("/:id/restore", Restore, rbac.Allow(rbac.WriteContent))
+}
@@ -0,0 +1,227 @@
+package search
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// name of the text index created on every searchable collection
+const indexName = "search"
+
+// characters of text shown around the first match of a snippet
+const (
+	snippetBefore = 60
+	snippetLength = 200
+)
+
+var tags = regexp.MustCompile(`<[^>]*>`)
+var spaces = regexp.MustCompile(`\s+`)
+
+// Search godoc
+// @Summary Search content
+// @Description Full-text search across blogs, services, abouts, galleries and companies, ranked by relevance
+// @ID search
+// @Tags Search
+// @Accept  json
+// @Produce  json
+// @Param q query string true "Search terms, use quotes for a phrase and - to exclude a term"
+// @Param type query string false "Comma separated collections to search (blogs, services, abouts, galleries, companies)"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Success 200 {object} utils.HttpSuccess{data=[]Hit}
+// @Failure 400 {object} utils.HttpError
+// @Router /public/search [get]
+func Search(c echo.Context) error {
+	q := strings.TrimSpace(c.QueryParam("q"))
+	if q == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "q is required")
+	}
+
+	query, err := utils.NewListQuery(c, utils.Fields{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	// hits of every collection are ranked together, they are paged by number
+	if query.HasCursor() {
+		return echo.NewHTTPError(http.StatusBadRequest, "cursor is not supported by search, use page")
+	}
+
+	selected, err := selectSources(c.QueryParam("type"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	highlight := highlighter(q)
+	hits := make([]Hit, 0)
+	var total int64
+
+	for _, src := range selected {
+		filter := bson.M{}
+		if src.Scope != nil {
+			filter = src.Scope()
+		}
+		trash.Active(filter)
+
+		repo, err := repository.Open(src.Collection)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		// every collection may fill the requested page on its own
+		docs := make([]bson.M, 0)
+		count, err := repo.Search(q, filter, query.Page*query.Limit, &docs)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		total += count
+
+		for _, doc := range docs {
+			id, _ := doc["_id"].(primitive.ObjectID)
+			title, _ := doc[src.Title].(string)
+			body, _ := doc[src.Body].(string)
+			relevance, _ := doc["score"].(float64)
+
+			hits = append(hits, Hit{
+				Type:    src.Collection,
+				ID:      id,
+				Title:   title,
+				Snippet: highlight(body),
+				Score:   relevance,
+			})
+		}
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		return hits[i].Score > hits[j].Score
+	})
+
+	start := (query.Page - 1) * query.Limit
+	end := start + query.Limit
+	if start > int64(len(hits)) {
+		start = int64(len(hits))
+	}
+	if end > int64(len(hits)) {
+		end = int64(len(hits))
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(hits[start:end], query.Paginate(total, nil)))
+}
+
+// selectSources returns the sources of the comma separated types, all of them when empty
+func selectSources(types string) ([]source, error) {
+	if types == "" {
+		return sources, nil
+	}
+
+	selected := make([]source, 0)
+	for _, typ := range strings.Split(types, ",") {
+		typ = strings.TrimSpace(typ)
+		found := false
+
+		for _, src := range sources {
+			if src.Collection == typ {
+				selected = append(selected, src)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("search in %s is not allowed", typ)
+		}
+	}
+
+	return selected, nil
+}
+
+// EnsureIndexes creates the text index of every searchable collection,
+// titles weigh more than the body when ranking
+func EnsureIndexes() error {
+	for _, src := range sources {
+		repo, err := repository.Open(src.Collection)
+		if err != nil {
+			return err
+		}
+
+		weights := bson.D{{Key: src.Title, Value: 10}, {Key: src.Body, Value: 1}}
+		if err = repo.EnsureText(indexName, weights); err != nil {
+			return fmt.Errorf("search: text index of %s: %w", src.Collection, err)
+		}
+	}
+
+	return nil
+}
+
+// highlighter returns a function building an html snippet of a text around
+// the first search term found, every term is wrapped in <mark>
+func highlighter(q string) func(string) string {
+	terms := make([]string, 0)
+	for _, term := range strings.Fields(q) {
+		term = strings.Trim(term, `"`)
+		if term == "" || strings.HasPrefix(term, "-") {
+			continue
+		}
+		terms = append(terms, regexp.QuoteMeta(term))
+	}
+
+	var marker *regexp.Regexp
+	if len(terms) > 0 {
+		marker = regexp.MustCompile(`(?i)` + strings.Join(terms, "|"))
+	}
+
+	return func(text string) string {
+		// search the plain text, bodies may contain html
+		text = html.UnescapeString(tags.ReplaceAllString(text, " "))
+		text = strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+		runes := []rune(text)
+
+		start := 0
+		if marker != nil {
+			if loc := marker.FindStringIndex(text); loc != nil {
+				start = utf8.RuneCountInString(text[:loc[0]]) - snippetBefore
+			}
+		}
+		if start < 0 {
+			start = 0
+		}
+
+		end := start + snippetLength
+		if end > len(runes) {
+			end = len(runes)
+		}
+
+		snippet := string(runes[start:end])
+		var b strings.Builder
+
+		if start > 0 {
+			b.WriteString("…")
+		}
+
+		last := 0
+		if marker != nil {
+			for _, loc := range marker.FindAllStringIndex(snippet, -1) {
+				b.WriteString(html.EscapeString(snippet[last:loc[0]]))
+				b.WriteString("<mark>" + html.EscapeString(snippet[loc[0]:loc[1]]) + "</mark>")
+				last = loc[1]
+			}
+		}
+		b.WriteString(html.EscapeString(snippet[last:]))
+
+		if end < len(runes) {
+			b.WriteString("…")
+		}
+
+		return b.String()
+	}
+}
@@ -0,0 +1,33 @@
+package search
+
+import (
+	"github.com/muhammadardie/echo-cms/components/blogs"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Hit is a single search result, Type is the collection it was found in
+type Hit struct {
+	Type    string             `json:"type"`
+	ID      primitive.ObjectID `json:"id"`
+	Title   string             `json:"title"`
+	Snippet string             `json:"snippet"`
+	Score   float64            `json:"score"`
+}
+
+// source is a collection searchable through its text index
+type source struct {
+	Collection string
+	Title      string
+	Body       string
+	// Scope limits the documents visible to the public
+	Scope func() bson.M
+}
+
+var sources = []source{
+	{Collection: "blogs", Title: "title", Body: "content", Scope: blogs.PublishedFilter},
+	{Collection: "services", Title: "title", Body: "desc"},
+	{Collection: "abouts", Title: "title", Body: "desc"},
+	{Collection: "galleries", Title: "title", Body: "desc"},
+	{Collection: "companies", Title: "title", Body: "desc"},
+}
@@ -0,0 +1,86 @@
+package search
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/blogs"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestSearch(t *testing.T) {
+	repository.Use(repository.NewMemory())
+	if err := EnsureIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	past := time.Now().Add(-time.Hour)
+	seed := map[string][]bson.M{
+		"blogs": {
+			{"_id": primitive.NewObjectID(), "title": "Web design tips", "content": "Colors", "status": blogs.StatusPublished, "published_at": past},
+			{"_id": primitive.NewObjectID(), "title": "Draft design", "content": "Secret", "status": blogs.StatusDraft},
+		},
+		"services": {
+			{"_id": primitive.NewObjectID(), "title": "Branding", "desc": "Logo design for <b>small</b> shops"},
+			{"_id": primitive.NewObjectID(), "title": "Design", "desc": "Old", "deleted_at": past},
+			{"_id": primitive.NewObjectID(), "title": "Hosting", "desc": "Servers"},
+		},
+	}
+	for collection, documents := range seed {
+		repo, _ := repository.Open(collection)
+		for _, document := range documents {
+			if err := repo.Insert(document); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+	e.GET("/public/search", Search)
+
+	search := func(q string) []Hit {
+		t.Helper()
+
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/public/search?q="+url.QueryEscape(q), nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("search %s = %d %s", q, rec.Code, rec.Body.String())
+		}
+
+		var res struct {
+			Data []Hit `json:"data"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Data
+	}
+
+	/* titles weigh more, drafts and deleted records are not found */
+	hits := search("design")
+	if len(hits) != 2 || hits[0].Title != "Web design tips" || hits[1].Title != "Branding" {
+		t.Fatalf("hits = %+v", hits)
+	}
+	if hits[1].Snippet != "Logo <mark>design</mark> for small shops" {
+		t.Fatalf("snippet = %s", hits[1].Snippet)
+	}
+
+	if hits = search("design -logo"); len(hits) != 1 || hits[0].Type != "blogs" {
+		t.Fatalf("hits excluding a word = %+v", hits)
+	}
+	if hits = search(`"logo design"`); len(hits) != 1 || hits[0].Type != "services" {
+		t.Fatalf("hits of a phrase = %+v", hits)
+	}
+}
@@ -1,15 +1,21 @@
 package services
 
 import (
+	"github.com/muhammadardie/echo-cms/i18n"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Services struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Title     string             `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
-	Icon      string             `json:"icon" bson:"icon,omitempty" form:"icon" query:"icon" validate:"required"`
-	Desc      string             `json:"desc" bson:"desc,omitempty" form:"desc" query:"desc" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Title         string             `json:"title" bson:"title" form:"title" query:"title" validate:"required"`
+	Slug          string             `json:"slug" bson:"slug,omitempty" form:"slug" query:"slug"`
+	PreviousSlugs []string           `json:"previousSlugs,omitempty" bson:"previous_slugs,omitempty"`
+	Icon          string             `json:"icon" bson:"icon,omitempty" form:"icon" query:"icon" validate:"required"`
+	Desc          string             `json:"desc" bson:"desc,omitempty" form:"desc" query:"desc" validate:"required"`
+	Version       int64              `json:"version" bson:"version" form:"version"`
+	Translations  i18n.Translations  `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
@@ -5,11 +5,5 @@ import (
 )
 
 func ServicesRegister(g *echo.Group) {
-	services := g.Group("/services")
-	services.GET("", Get)
-	services.POST("", Create)
-	services.GET("/:id", Find)
-	services.PUT("/:id", Update)
-	services.DELETE("/:id", Destroy)
-
+	resource.Register(g)
 }
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newServer serves the services and their revisions from an empty memory
+// driver, the requests are made by an admin
+func newServer() *echo.Echo {
+	repository.Use(repository.NewMemory())
+
+	// the cached responses are dropped on restore, there is no cache to drop here
+	os.Setenv("REDIS_URL", "redis://127.0.0.1:1")
+
+	e := echo.New()
+	e.Validator = middleware.NewValidator()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+
+	g := e.Group("/api", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rbac.SetActor(c, &rbac.Actor{UserId: primitive.NewObjectID().Hex(), Role: rbac.RoleAdmin})
+
+			return next(c)
+		}
+	})
+	ServicesRegister(g)
+	revisions.RevisionsRegister(g)
+
+	return e
+}
+
+// send serves the request with the form and decodes the data of the response
+func send(t *testing.T, e *echo.Echo, method string, target string, form url.Values, data interface{}) {
+	t.Helper()
+
+	req := httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%s %s = %d %s", method, target, rec.Code, rec.Body.String())
+	}
+
+	res := struct {
+		Data interface{} `json:"data"`
+	}{Data: data}
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRestoreKeepsSlug(t *testing.T) {
+	e := newServer()
+
+	var service Services
+	send(t, e, http.MethodPost, "/api/services", url.Values{"title": {"Web Design"}, "icon": {"web"}, "desc": {"Sites"}}, &service)
+	target := "/api/services/" + service.ID.Hex()
+	send(t, e, http.MethodPut, target, url.Values{"title": {"Branding"}}, nil)
+
+	/* the slug left behind is not taken again by another service */
+	var other Services
+	send(t, e, http.MethodPost, "/api/services", url.Values{"title": {"Web Design"}, "icon": {"web"}, "desc": {"Apps"}}, &other)
+	if other.Slug != "web-design-2" {
+		t.Fatalf("slug of the other service = %s", other.Slug)
+	}
+
+	var saved []revisions.Revisions
+	send(t, e, http.MethodGet, "/api/revisions?collection=services", nil, &saved)
+	if len(saved) != 1 {
+		t.Fatalf("revisions = %+v", saved)
+	}
+	send(t, e, http.MethodPost, "/api/revisions/"+saved[0].ID.Hex()+"/restore", nil, nil)
+
+	var restored Services
+	send(t, e, http.MethodGet, target, nil, &restored)
+	if restored.Title != "Web Design" || restored.Slug != "branding" {
+		t.Fatalf("restored = %+v", restored)
+	}
+	if len(restored.PreviousSlugs) != 1 || restored.PreviousSlugs[0] != "web-design" {
+		t.Fatalf("previous slugs = %v", restored.PreviousSlugs)
+	}
+}
@@ -1,15 +1,19 @@
 package socmeds
 
 import (
+	"github.com/muhammadardie/echo-cms/i18n"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"time"
 )
 
 type Socmeds struct {
-	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
-	Name      string             `json:"name" bson:"name" form:"name" query:"name" validate:"required"`
-	Icon      string             `json:"icon" bson:"icon,omitempty" form:"icon" query:"icon" validate:"required"`
-	Url       string             `json:"url" bson:"url,omitempty" form:"url" query:"url" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	ID           primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name         string             `json:"name" bson:"name" form:"name" query:"name" validate:"required"`
+	Icon         string             `json:"icon" bson:"icon,omitempty" form:"icon" query:"icon" validate:"required"`
+	Url          string             `json:"url" bson:"url,omitempty" form:"url" query:"url" validate:"required"`
+	Version      int64              `json:"version" bson:"version" form:"version"`
+	Translations i18n.Translations  `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt    time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt    time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt    *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
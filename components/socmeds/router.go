@@ -5,11 +5,5 @@ import (
 )
 
 func SocmedsRegister(g *echo.Group) {
-	socmeds := g.Group("/socmeds")
-	socmeds.GET("", Get)
-	socmeds.POST("", Create)
-	socmeds.GET("/:id", Find)
-	socmeds.PUT("/:id", Update)
-	socmeds.DELETE("/:id", Destroy)
-
+	resource.Register(g)
 }
@@ -0,0 +1,54 @@
+package tags
+
+import (
+	"github.com/muhammadardie/echo-cms/crud"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const colName = "tags"
+
+// resource serves the tags of the blog posts from the model, see crud.Options
+var resource = crud.New(crud.Options{
+	Collection: colName,
+	Model:      Tags{},
+	ListFields: utils.Fields{
+		"name": "name",
+		"slug": "slug",
+	},
+	Slug:         "name",
+	Translatable: []string{"name"},
+	Public:       true,
+})
+
+// Lookup returns the tag of the ID or of the slug
+func Lookup(param string) (*Tags, error) {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, err
+	}
+
+	record := new(Tags)
+	if err = repo.FindOne(trash.Active(slug.Selector(param)), record); err != nil {
+		return nil, err
+	}
+
+	return record, nil
+}
+
+// All returns every tag by name
+func All() ([]Tags, error) {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]Tags, 0)
+	err = repo.FindAll(trash.Active(bson.M{}), options.Find().SetSort(bson.D{{Key: "name", Value: 1}}), &records)
+
+	return records, err
+}
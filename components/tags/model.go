@@ -0,0 +1,19 @@
+package tags
+
+import (
+	"github.com/muhammadardie/echo-cms/i18n"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
+)
+
+type Tags struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name          string             `json:"name" bson:"name" form:"name" query:"name" validate:"required"`
+	Slug          string             `json:"slug" bson:"slug,omitempty" form:"slug" query:"slug"`
+	PreviousSlugs []string           `json:"previousSlugs,omitempty" bson:"previous_slugs,omitempty"`
+	Version       int64              `json:"version" bson:"version" form:"version"`
+	Translations  i18n.Translations  `json:"translations,omitempty" bson:"translations,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
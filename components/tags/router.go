@@ -0,0 +1,9 @@
+package tags
+
+import (
+	"github.com/labstack/echo/v4"
+)
+
+func TagsRegister(g *echo.Group) {
+	resource.Register(g)
+}
@@ -5,10 +5,5 @@ import (
 )
 
 func TeamsRegister(g *echo.Group) {
-	teams := g.Group("/teams")
-	teams.GET("", Get)
-	teams.POST("", Create)
-	teams.GET("/:id", Find)
-	teams.PUT("/:id", Update)
-	teams.DELETE("/:id", Destroy)
+	resource.Register(g)
 }
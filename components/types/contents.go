@@ -0,0 +1,982 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/patch"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// contents of every type are served under this path
+const contentsPath = "contents"
+
+// suffix of the form value holding the ID of a media item for an image field
+const mediaSuffix = "Media"
+
+// GetContents godoc
+// @Summary Get contents of a type
+// @Description Get the contents of a custom content type, its text fields can be used to filter and sort
+// @ID get-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type} [get]
+func GetContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return listContents(c, record, false)
+}
+
+// GetPublicContents godoc
+// @Summary Get public contents of a type
+// @Description Get the contents of a public content type, their text fields in the locale of ?lang= or Accept-Language following the fallback chain
+// @ID get-public-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Param type path string true "Name of the content type"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/contents/{type} [get]
+func GetPublicContents(c echo.Context) error {
+	record, err := contentType(c, true)
+	if err != nil {
+		return err
+	}
+
+	return listContents(c, record, true)
+}
+
+func listContents(c echo.Context, record *Types, public bool) error {
+	query, err := utils.NewListQuery(c, record.listFields())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Where(trash.Active(bson.M{}))
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]bson.M, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if public {
+		i18n.Localize(c, result, record.translatable()...)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// FindContents godoc
+// @Summary Find content by ID
+// @Description Find a content of a custom content type by ID
+// @ID find-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content to get"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type}/{id} [get]
+func FindContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return findContent(c, record, false)
+}
+
+// FindPublicContents godoc
+// @Summary Find public content by ID
+// @Description Find a content of a public content type by ID, its text fields in the locale of ?lang= or Accept-Language following the fallback chain
+// @ID find-public-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Param lang query string false "Locale of the texts, e.g. id"
+// @Param Accept-Language header string false "Preferred locales"
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content to get"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /public/contents/{type}/{id} [get]
+func FindPublicContents(c echo.Context) error {
+	record, err := contentType(c, true)
+	if err != nil {
+		return err
+	}
+
+	return findContent(c, record, true)
+}
+
+func findContent(c echo.Context, record *Types, public bool) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var content bson.M
+
+	if err = repo.FindOne(selector, &content); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, version.Of(content))
+
+	if public {
+		i18n.Localize(c, content, record.translatable()...)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(content, ""))
+}
+
+// CreateContents godoc
+// @Summary Create content
+// @Description Create a content of a custom content type, every field of the type is a form value, image fields take a file or the ID of a media item in {field}Media
+// @ID create-contents
+// @Tags Contents
+// @Accept  mpfd
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /contents/{type} [post]
+func CreateContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	// validate input before anything is uploaded
+	content, err := record.read(c, true)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	id := primitive.NewObjectID()
+	ref := media.Reference{Collection: record.Collection(), ID: id}
+
+	/* upload images, or pick them from the media library */
+	for _, field := range record.Fields {
+		if field.Type != FieldImage {
+			continue
+		}
+
+		image, err := media.ReadImageField(c, field.Name, field.Name+mediaSuffix, record.Collection())
+		if err != nil {
+			return err
+		}
+		if image == nil {
+			continue
+		}
+
+		if err = media.Use(image, ref); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		content[field.Name] = &Image{Filename: image.Filename, Variants: image.Variants, Media: image.Media}
+	}
+	/* end upload images */
+
+	/* store record to db */
+	content["_id"] = id
+	content["created_at"] = time.Now()
+	content["updated_at"] = time.Now()
+	content[version.Field] = int64(1)
+
+	err = repo.Insert(content)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionCreate, record.Collection(), id, nil)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(content, "Saved"))
+}
+
+// UpdateContents godoc
+// @Summary Update content
+// @Description Update a content of a custom content type, fields left empty keep their value
+// @ID update-contents
+// @Tags Contents
+// @Accept  mpfd
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /contents/{type}/{id} [put]
+func UpdateContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var current bson.M
+
+	if err = repo.FindOne(selector, &current); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	/* the change must be based on the current version, when it says which */
+	body, _ := strconv.ParseInt(c.FormValue(version.Field), 10, 64)
+	submitted, err := version.Submitted(c, body)
+	if err != nil {
+		return err
+	}
+
+	latest := version.Of(current)
+	if err = version.Check(submitted, latest, current); err != nil {
+		return err
+	}
+
+	changes, err := record.read(c, false)
+	if err != nil {
+		return err
+	}
+
+	ref := media.Reference{Collection: record.Collection(), ID: id}
+
+	/* check images exist first, or pick them from the media library */
+	for _, field := range record.Fields {
+		if field.Type != FieldImage {
+			continue
+		}
+
+		image, err := media.ReadImageField(c, field.Name, field.Name+mediaSuffix, record.Collection())
+		if err != nil {
+			return err
+		}
+		// no image in the request keeps the current one
+		if image == nil {
+			continue
+		}
+
+		/* release the current image, contents keep no revisions */
+		if current[field.Name] != nil {
+			if err = media.Release(imageOf(current, field.Name), record.Collection(), ref); err != nil {
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+		}
+
+		if err = media.Use(image, ref); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		changes[field.Name] = &Image{Filename: image.Filename, Variants: image.Variants, Media: image.Media}
+	}
+
+	changes["updated_at"] = time.Now()
+	update := version.Bump(bson.M{"$set": changes})
+
+	before := audits.Snapshot(record.Collection(), id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, &bson.M{})
+	}
+
+	audits.Record(c, audits.ActionUpdate, record.Collection(), id, before)
+	revisions.Save(c, record.Collection(), id, before)
+
+	version.SetETag(c, latest+1)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Updated"))
+}
+
+// PatchContents godoc
+// @Summary Change some fields of a content
+// @Description Change the fields of a content of a custom content type given by a JSON merge patch (RFC 7396), null clears a field and image fields are changed with PUT. An If-Match header, or the version of the patch, makes the change fail with 409 Conflict when the content changed since that version.
+// @ID patch-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Param If-Match header string false "ETag, the version of the content the change is based on"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /contents/{type}/{id} [patch]
+func PatchContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	doc, based, err := patch.Read(c)
+	if err != nil {
+		return err
+	}
+
+	submitted, err := version.Submitted(c, based)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var current bson.M
+
+	if err = repo.FindOne(selector, &current); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	latest := version.Of(current)
+	if err = version.Check(submitted, latest, current); err != nil {
+		return err
+	}
+
+	set, unset, err := record.merge(doc)
+	if err != nil {
+		return err
+	}
+
+	set["updated_at"] = time.Now()
+	update := version.Bump(bson.M{"$set": set})
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	before := audits.Snapshot(record.Collection(), id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, &bson.M{})
+	}
+
+	audits.Record(c, audits.ActionUpdate, record.Collection(), id, before)
+	revisions.Save(c, record.Collection(), id, before)
+
+	for name := range unset {
+		delete(current, name)
+	}
+	for name, value := range set {
+		current[name] = value
+	}
+	current[version.Field] = latest + 1
+
+	version.SetETag(c, latest+1)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(current, "Updated"))
+}
+
+// DestroyContents godoc
+// @Summary Delete content
+// @Description Move a content of a custom content type to the trash
+// @ID delete-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type}/{id} [delete]
+func DestroyContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	before := audits.Snapshot(record.Collection(), id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionDelete, record.Collection(), id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// TrashContents godoc
+// @Summary Get deleted contents of a type
+// @Description Get the contents of a custom content type in the trash, they are purged for good after the retention period
+// @ID trash-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type}/trash [get]
+func TrashContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return trash.List(c, record.Collection(), &[]bson.M{})
+}
+
+// RestoreContents godoc
+// @Summary Restore deleted content
+// @Description Take a content of a custom content type out of the trash
+// @ID restore-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the deleted content"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type}/{id}/restore [post]
+func RestoreContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return trash.Restore(c, record.Collection())
+}
+
+// TranslationsContents godoc
+// @Summary Get translations of a content
+// @Description Get the text fields of a content in every locale but the default one, by locale then by field
+// @ID get-translations-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Success 200 {object} utils.HttpSuccess{data=i18n.Translations}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /contents/{type}/{id}/translations [get]
+func TranslationsContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return i18n.List(c, record.Collection())
+}
+
+// TranslateContents godoc
+// @Summary Translate a content
+// @Description Replace the text fields of a content in a locale with the JSON object of the body, by field. Text fields without options and rich text fields can be translated. An If-Match header, or the version of the body, makes the change fail with 409 Conflict when the content changed since that version.
+// @ID translate-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Param locale path string true "Locale of the texts, not the default one"
+// @Param If-Match header string false "ETag, the version of the content the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=i18n.Translations}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /contents/{type}/{id}/translations/{locale} [put]
+func TranslateContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return i18n.Update(c, record.Collection(), record.translatable())
+}
+
+// UntranslateContents godoc
+// @Summary Delete translations of a content
+// @Description Delete the text fields of a content in a locale, the public site falls back to the next locale of the chain
+// @ID untranslate-contents
+// @Tags Contents
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type path string true "Name of the content type"
+// @Param id path string true "ID of the content"
+// @Param locale path string true "Locale of the texts, not the default one"
+// @Param If-Match header string false "ETag, the version of the content the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=i18n.Translations}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /contents/{type}/{id}/translations/{locale} [delete]
+func UntranslateContents(c echo.Context) error {
+	record, err := contentType(c, false)
+	if err != nil {
+		return err
+	}
+
+	return i18n.Remove(c, record.Collection())
+}
+
+// contentType returns the type of the request, the public site only
+// gets the public ones
+func contentType(c echo.Context, public bool) (*Types, error) {
+	record, err := Load(c.Param("type"))
+	if err != nil || (public && !record.Public) {
+		return nil, echo.NewHTTPError(http.StatusNotFound, "Content type not found")
+	}
+
+	return record, nil
+}
+
+// listFields are the text fields, they can be used to filter and sort the list
+func (t *Types) listFields() utils.Fields {
+	fields := utils.Fields{}
+
+	for _, field := range t.Fields {
+		if field.Type == FieldText {
+			fields[field.Name] = field.Name
+		}
+	}
+
+	return fields
+}
+
+// translatable are the text fields that are not picked from options and
+// the rich text fields, they are translated per locale
+func (t *Types) translatable() []string {
+	fields := make([]string, 0)
+
+	for _, field := range t.Fields {
+		if (field.Type == FieldText && len(field.Options) == 0) || field.Type == FieldRichText {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	return fields
+}
+
+// read reads the values of the fields from the form and checks them
+// against the rules of the type, images are read apart once the values
+// are valid. Empty values are left out, they are only missing when the
+// content is created.
+func (t *Types) read(c echo.Context, create bool) (bson.M, error) {
+	values := bson.M{}
+	invalid := make([]utils.FieldError, 0)
+
+	for _, field := range t.Fields {
+		if field.Type == FieldImage {
+			if create && field.Required && !hasImage(c, field.Name) {
+				invalid = append(invalid, fieldError(field.Name, "required", "%s is required", field.Name))
+			}
+			continue
+		}
+
+		raw := c.FormValue(field.Name)
+		if raw == "" {
+			if create && field.Required {
+				invalid = append(invalid, fieldError(field.Name, "required", "%s is required", field.Name))
+			}
+			continue
+		}
+
+		value, fieldErr := field.parse(raw)
+		if fieldErr != nil {
+			invalid = append(invalid, *fieldErr)
+			continue
+		}
+		values[field.Name] = value
+	}
+
+	if len(invalid) > 0 {
+		return nil, utils.NewValidationError(invalid...)
+	}
+
+	return values, nil
+}
+
+// merge checks the values of the merge patch against the fields and
+// returns the ones to set and to unset, image fields can not be patched
+func (t *Types) merge(doc patch.Document) (bson.M, bson.M, error) {
+	set := bson.M{}
+	unset := bson.M{}
+	invalid := make([]utils.FieldError, 0)
+
+	for _, name := range doc.Keys() {
+		change := doc[name]
+		field := t.field(name)
+		if field == nil || field.Type == FieldImage {
+			invalid = append(invalid, fieldError(name, "readonly", "%s can not be patched", name))
+			continue
+		}
+
+		var raw string
+		switch value := change.(type) {
+		case nil:
+		case string:
+			raw = value
+		case float64:
+			raw = strconv.FormatFloat(value, 'f', -1, 64)
+		case bool:
+			raw = strconv.FormatBool(value)
+		default:
+			invalid = append(invalid, fieldError(name, "type", "%s must be a %s", name, field.Type))
+			continue
+		}
+
+		if raw == "" {
+			if field.Required {
+				invalid = append(invalid, fieldError(name, "required", "%s is required", name))
+			} else {
+				unset[name] = ""
+			}
+			continue
+		}
+
+		value, fieldErr := field.parse(raw)
+		if fieldErr != nil {
+			invalid = append(invalid, *fieldErr)
+			continue
+		}
+		set[name] = value
+	}
+
+	if len(invalid) > 0 {
+		return nil, nil, utils.NewValidationError(invalid...)
+	}
+
+	return set, unset, nil
+}
+
+// field returns the field of the type with the name, nil when there is none
+func (t *Types) field(name string) *Field {
+	for i := range t.Fields {
+		if t.Fields[i].Name == name {
+			return &t.Fields[i]
+		}
+	}
+
+	return nil
+}
+
+// parse converts the form value to the type of the field
+func (f *Field) parse(raw string) (interface{}, *utils.FieldError) {
+	switch f.Type {
+	case FieldNumber:
+		value, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return nil, f.invalid("number", "%s must be a number", f.Name)
+		}
+		if f.Min != nil && value < *f.Min {
+			return nil, f.invalid("min", "%s must be at least %v", f.Name, *f.Min)
+		}
+		if f.Max != nil && value > *f.Max {
+			return nil, f.invalid("max", "%s must be at most %v", f.Name, *f.Max)
+		}
+		return value, nil
+
+	case FieldBoolean:
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			return nil, f.invalid("boolean", "%s must be true or false", f.Name)
+		}
+		return value, nil
+
+	case FieldDate:
+		if value, err := time.Parse(time.RFC3339, raw); err == nil {
+			return value, nil
+		}
+		value, err := time.Parse("2006-01-02", raw)
+		if err != nil {
+			return nil, f.invalid("date", "%s must be a date (YYYY-MM-DD) or a RFC3339 time", f.Name)
+		}
+		return value, nil
+
+	case FieldReference:
+		id, err := primitive.ObjectIDFromHex(raw)
+		if err != nil {
+			return nil, f.invalid("reference", "%s must be an ID", f.Name)
+		}
+		if !exists(f.Reference, id) {
+			return nil, f.invalid("reference", "%s must reference an existing %s", f.Name, f.Reference)
+		}
+		return id, nil
+	}
+
+	// text and rich text
+	length := float64(utf8.RuneCountInString(raw))
+	if f.Min != nil && length < *f.Min {
+		return nil, f.invalid("min", "%s must be at least %v characters long", f.Name, *f.Min)
+	}
+	if f.Max != nil && length > *f.Max {
+		return nil, f.invalid("max", "%s must be at most %v characters long", f.Name, *f.Max)
+	}
+	if f.Pattern != "" {
+		if matched, err := regexp.MatchString(f.Pattern, raw); err != nil || !matched {
+			return nil, f.invalid("pattern", "%s does not match the pattern %s", f.Name, f.Pattern)
+		}
+	}
+	if len(f.Options) > 0 && !contains(f.Options, raw) {
+		return nil, f.invalid("oneof", "%s must be one of %s", f.Name, strings.Join(f.Options, ", "))
+	}
+
+	return raw, nil
+}
+
+func (f *Field) invalid(code string, format string, args ...interface{}) *utils.FieldError {
+	fieldErr := fieldError(f.Name, code, format, args...)
+
+	return &fieldErr
+}
+
+// exists reports whether the document is in the collection, or in the
+// contents of the type, of the name
+func exists(name string, id primitive.ObjectID) bool {
+	collection := name
+	if record, err := Load(name); err == nil {
+		collection = record.Collection()
+	}
+
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return false
+	}
+
+	count, err := repo.Count(trash.Active(bson.M{"_id": id}))
+
+	return err == nil && count > 0
+}
+
+// hasImage reports whether the request has a file or a media item for the field
+func hasImage(c echo.Context, field string) bool {
+	if c.FormValue(field+mediaSuffix) != "" {
+		return true
+	}
+
+	_, err := c.FormFile(field)
+
+	return err == nil
+}
+
+// imageOf returns the image stored in the field of a content
+func imageOf(content bson.M, field string) *media.Image {
+	image := &media.Image{}
+
+	var fields struct {
+		Image Image `bson:"image"`
+	}
+	if raw, err := bson.Marshal(bson.M{"image": content[field]}); err == nil {
+		if err = bson.Unmarshal(raw, &fields); err == nil {
+			image.Filename = fields.Image.Filename
+			image.Variants = fields.Image.Variants
+			image.Media = fields.Image.Media
+		}
+	}
+
+	return image
+}
+
+// releaseImages releases the images of a content deleted for good
+func releaseImages(record *Types, content bson.M) error {
+	id, _ := content["_id"].(primitive.ObjectID)
+	ref := media.Reference{Collection: record.Collection(), ID: id}
+
+	for _, field := range record.Fields {
+		if field.Type != FieldImage || content[field.Name] == nil {
+			continue
+		}
+
+		if err := media.Release(imageOf(content, field.Name), record.Collection(), ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// register adds the contents of the type to the purge of the trash and
+// to the collections keeping revisions
+func register(record *Types) {
+	trash.Register(record.Collection(), purgeContent(record.Name))
+
+	fields := make([]string, 0)
+	for _, field := range record.Fields {
+		if field.Type == FieldImage {
+			fields = append(fields, field.Name)
+		}
+	}
+
+	revisions.Register(record.Collection(), revisions.Source{
+		UploadDir: record.Collection(),
+		Images: func(document bson.M) map[string]*media.Image {
+			result := map[string]*media.Image{}
+			for _, field := range fields {
+				result[field] = imageOf(document, field)
+			}
+
+			return result
+		},
+	})
+}
+
+// purgeContent releases the images of the contents of a type before they
+// are deleted for good
+func purgeContent(name string) trash.PurgeFunc {
+	return func(id primitive.ObjectID) error {
+		// the type may be in the trash as well
+		record, err := load(bson.M{"name": name})
+		if err != nil {
+			return err
+		}
+
+		repo, err := repository.Open(record.Collection())
+		if err != nil {
+			return err
+		}
+
+		var content bson.M
+
+		if err = repo.FindByID(id, &content); err != nil {
+			return err
+		}
+
+		return releaseImages(record, content)
+	}
+}
+
+func fieldError(field string, code string, format string, args ...interface{}) utils.FieldError {
+	return utils.FieldError{Field: field, Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,542 @@
+package types
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/cache"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/patch"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const colName = "types"
+
+// contents of a type are stored in the collection of its name with this
+// prefix, so they never clash with the built-in collections
+const collectionPrefix = "contents_"
+
+// names of the types and of their fields
+var (
+	typeName  = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+	fieldName = regexp.MustCompile(`^[a-zA-Z][a-zA-Z0-9_]{0,49}$`)
+)
+
+// fields every content has, they can't be defined by a type
+var reservedFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"version":    true,
+	trash.Field:  true,
+	i18n.Field:   true,
+}
+
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"name":  "name",
+	"label": "label",
+}
+
+// Get Types godoc
+// @Summary Get content types
+// @Description Get the custom content types
+// @ID get-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param name query string false "Filter by name"
+// @Param label query string false "Filter by label"
+// @Success 200 {object} utils.HttpSuccess{data=[]Types}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /types [get]
+func Get(c echo.Context) error {
+	query, err := utils.NewListQuery(c, listFields, Types{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Where(trash.Active(bson.M{}))
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Types, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Find Types godoc
+// @Summary Find content type by ID
+// @Description Find content type by ID
+// @ID find-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the content type to get"
+// @Success 200 {object} utils.HttpSuccess{data=Types}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /types/{id} [get]
+func Find(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Types
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
+}
+
+// Create Types godoc
+// @Summary Create content type
+// @Description Define a content type, its contents are served at /contents/{name}
+// @ID create-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param type body Types true "Name, fields and validation rules of the type"
+// @Success 200 {object} Types
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /types [post]
+func Create(c echo.Context) error {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	record := new(Types)
+	if err := c.Bind(record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	if err := c.Validate(record); err != nil {
+		return err
+	}
+
+	if !typeName.MatchString(record.Name) {
+		return utils.NewValidationError(fieldError("name", "name", "name must start with a lowercase letter followed by lowercase letters, digits or _"))
+	}
+
+	if err := checkFields(record.Fields); err != nil {
+		return err
+	}
+
+	// the name is taken as long as its contents exist, even in the trash
+	if count, err := repo.Count(bson.M{"name": record.Name}); err == nil && count > 0 {
+		return echo.NewHTTPError(http.StatusConflict, "Content type already exists")
+	}
+
+	record.ID = primitive.NewObjectID()
+	record.CreatedAt = time.Now()
+	record.UpdatedAt = time.Now()
+	record.Version = 1
+
+	err = repo.Insert(record)
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	register(record)
+	audits.Record(c, audits.ActionCreate, colName, record.ID, nil)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Saved"))
+}
+
+// Update Types godoc
+// @Summary Update content type
+// @Description Update the label, fields and visibility of a content type, its name can't be changed
+// @ID update-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the content type"
+// @Param type body Types true "Fields and validation rules of the type"
+// @Success 200 {object} Types
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /types/{id} [put]
+func Update(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Types
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	changes := new(Types)
+	if err := c.Bind(changes); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, changes.Version)
+	if err != nil {
+		return err
+	}
+
+	if err = version.Check(submitted, record.Version, &record); err != nil {
+		return err
+	}
+
+	// the name keeps pointing at the collection of the contents
+	changes.Name = record.Name
+
+	if err := c.Validate(changes); err != nil {
+		return err
+	}
+
+	if err := checkFields(changes.Fields); err != nil {
+		return err
+	}
+
+	update := version.Bump(bson.M{"$set": bson.M{
+		"label":      changes.Label,
+		"public":     changes.Public,
+		"fields":     changes.Fields,
+		"updated_at": time.Now(),
+	}})
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, record.Version), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, new(Types))
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+	invalidateContents(c)
+
+	version.SetETag(c, record.Version+1)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Updated"))
+}
+
+// Patch Types godoc
+// @Summary Change some fields of a content type
+// @Description Change the label, fields or visibility given by a JSON merge patch (RFC 7396), the fields of the type are replaced as a whole. An If-Match header, or the version of the patch, makes the change fail with 409 Conflict when the type changed since that version.
+// @ID patch-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the content type"
+// @Param If-Match header string false "ETag, the version of the type the change is based on"
+// @Param type body Types true "Fields to change"
+// @Success 200 {object} utils.HttpSuccess{data=Types}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /types/{id} [patch]
+func Patch(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	doc, based, err := patch.Read(c)
+	if err != nil {
+		return err
+	}
+
+	submitted, err := version.Submitted(c, based)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Types
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	latest := record.Version
+	if err = version.Check(submitted, latest, &record); err != nil {
+		return err
+	}
+
+	// the name keeps pointing at the collection of the contents
+	update, err := doc.Apply(&record, "label", "public", "fields")
+	if err != nil {
+		return err
+	}
+
+	if err = c.Validate(record); err != nil {
+		return err
+	}
+
+	if err = checkFields(record.Fields); err != nil {
+		return err
+	}
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, new(Types))
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+	invalidateContents(c)
+
+	version.SetETag(c, record.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Updated"))
+}
+
+// Delete Types godoc
+// @Summary Delete content type
+// @Description Move a content type to the trash, its contents are deleted with it once purged
+// @ID delete-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the content type"
+// @Success 200 {object} Types
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /types/{id} [delete]
+func Destroy(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	before := audits.Snapshot(colName, id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionDelete, colName, id, before)
+	invalidateContents(c)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// Trash Types godoc
+// @Summary Get deleted content types
+// @Description Get the content types in the trash, they are purged for good with their contents after the retention period
+// @ID trash-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]Types}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /types/trash [get]
+func Trash(c echo.Context) error {
+	return trash.List(c, colName, &[]Types{})
+}
+
+// Restore Types godoc
+// @Summary Restore deleted content type
+// @Description Take a content type out of the trash
+// @ID restore-types
+// @Tags Types
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the deleted record"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /types/{id}/restore [post]
+func Restore(c echo.Context) error {
+	if err := trash.Restore(c, colName); err != nil {
+		return err
+	}
+
+	invalidateContents(c)
+
+	return nil
+}
+
+// Load returns the content type of the name unless it is in the trash
+func Load(name string) (*Types, error) {
+	record, err := load(trash.Active(bson.M{"name": name}))
+	if err != nil {
+		return nil, err
+	}
+
+	// contents may be in the trash of a type defined before the last restart
+	register(record)
+
+	return record, nil
+}
+
+func load(filter bson.M) (*Types, error) {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return nil, err
+	}
+
+	var record Types
+
+	if err = repo.FindOne(filter, &record); err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// checkFields checks the names and the validation rules of the fields
+func checkFields(fields []Field) error {
+	invalid := make([]utils.FieldError, 0)
+	names := map[string]bool{}
+
+	for i, field := range fields {
+		path := fmt.Sprintf("fields[%d]", i)
+
+		if !fieldName.MatchString(field.Name) || reservedFields[field.Name] {
+			invalid = append(invalid, fieldError(path+".name", "name", "%s is not a valid field name", field.Name))
+		} else if names[field.Name] {
+			invalid = append(invalid, fieldError(path+".name", "unique", "%s is defined twice", field.Name))
+		}
+		names[field.Name] = true
+
+		if field.Min != nil && field.Max != nil && *field.Min > *field.Max {
+			invalid = append(invalid, fieldError(path+".min", "range", "%s min must not be greater than max", field.Name))
+		}
+
+		if field.Pattern != "" {
+			if _, err := regexp.Compile(field.Pattern); err != nil {
+				invalid = append(invalid, fieldError(path+".pattern", "pattern", "%s pattern is not valid: %s", field.Name, err.Error()))
+			}
+		}
+
+		if field.Type == FieldReference && field.Reference == "" {
+			invalid = append(invalid, fieldError(path+".reference", "required", "%s reference is required", field.Name))
+		}
+	}
+
+	if len(invalid) > 0 {
+		return utils.NewValidationError(invalid...)
+	}
+
+	return nil
+}
+
+// invalidateContents drops the cached contents, they are served by the
+// definition of their type
+func invalidateContents(c echo.Context) {
+	if err := cache.Invalidate(contentsPath); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// purge deletes the contents of a type deleted for good
+func purge(id primitive.ObjectID) error {
+	record, err := load(bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(record.Collection())
+	if err != nil {
+		return err
+	}
+
+	contents := make([]bson.M, 0)
+	if err = repo.FindAll(bson.M{}, nil, &contents); err != nil {
+		return err
+	}
+
+	for _, content := range contents {
+		if err = releaseImages(record, content); err != nil {
+			return err
+		}
+	}
+
+	_, err = repo.DeleteAll(bson.M{})
+
+	return err
+}
@@ -0,0 +1,62 @@
+package types
+
+import (
+	"time"
+
+	"github.com/muhammadardie/echo-cms/images"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// kinds of the fields of a content type
+const (
+	FieldText      = "text"
+	FieldRichText  = "richtext"
+	FieldNumber    = "number"
+	FieldBoolean   = "boolean"
+	FieldDate      = "date"
+	FieldImage     = "image"
+	FieldReference = "reference"
+)
+
+// Types is a content type defined by the admins, its contents are stored
+// in a collection of their own
+type Types struct {
+	ID        primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Name      string             `json:"name" bson:"name" validate:"required"`
+	Label     string             `json:"label" bson:"label,omitempty"`
+	Public    bool               `json:"public" bson:"public"`
+	Fields    []Field            `json:"fields" bson:"fields" validate:"required,min=1,dive"`
+	Version   int64              `json:"version" bson:"version" form:"version"`
+	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
+
+// Field is a field of the contents of a type with its validation rules
+type Field struct {
+	Name     string `json:"name" bson:"name" validate:"required"`
+	Label    string `json:"label" bson:"label,omitempty"`
+	Type     string `json:"type" bson:"type" validate:"required,oneof=text richtext number boolean date image reference"`
+	Required bool   `json:"required" bson:"required"`
+	// Min and Max bound a number, or the length of a text
+	Min *float64 `json:"min,omitempty" bson:"min,omitempty"`
+	Max *float64 `json:"max,omitempty" bson:"max,omitempty"`
+	// Pattern is a regular expression a text must match
+	Pattern string `json:"pattern,omitempty" bson:"pattern,omitempty"`
+	// Options are the only values allowed for a text
+	Options []string `json:"options,omitempty" bson:"options,omitempty"`
+	// Reference is the content type, or the collection, a reference points to
+	Reference string `json:"reference,omitempty" bson:"reference,omitempty"`
+}
+
+// Image is the value of an image field
+type Image struct {
+	Filename string             `json:"filename,omitempty" bson:"filename,omitempty"`
+	Variants *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+	Media    primitive.ObjectID `json:"media,omitempty" bson:"media,omitempty"`
+}
+
+// Collection returns the collection storing the contents of the type
+func (t *Types) Collection() string {
+	return collectionPrefix + t.Name
+}
@@ -0,0 +1,56 @@
+package types
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+func TypesRegister(g *echo.Group) {
+	trash.Register(colName, purge)
+	registerContents()
+
+	types := g.Group("/types")
+	types.GET("", Get, rbac.Allow(rbac.ReadContent))
+	types.GET("/trash", Trash, rbac.Allow(rbac.ManageTypes))
+	types.POST("", Create, rbac.Allow(rbac.ManageTypes))
+	types.GET("/:id", Find, rbac.Allow(rbac.ReadContent))
+	types.PUT("/:id", Update, rbac.Allow(rbac.ManageTypes))
+	types.PATCH("/:id", Patch, rbac.Allow(rbac.ManageTypes))
+	types.DELETE("/:id", Destroy, rbac.Allow(rbac.ManageTypes))
+	types.POST("/:id/restore", Restore, rbac.Allow(rbac.ManageTypes))
+
+	contents := g.Group("/" + contentsPath + "/:type")
+	contents.GET("", GetContents, rbac.Allow(rbac.ReadContent))
+	contents.GET("/trash", TrashContents, rbac.Allow(rbac.ReadContent))
+	contents.POST("", CreateContents, rbac.Allow(rbac.WriteContent))
+	contents.GET("/:id", FindContents, rbac.Allow(rbac.ReadContent))
+	contents.PUT("/:id", UpdateContents, rbac.Allow(rbac.WriteContent))
+	contents.PATCH("/:id", PatchContents, rbac.Allow(rbac.WriteContent))
+	contents.DELETE("/:id", DestroyContents, rbac.Allow(rbac.WriteContent))
+	contents.POST("/:id/restore", RestoreContents, rbac.Allow(rbac.WriteContent))
+	contents.GET("/:id/translations", TranslationsContents, rbac.Allow(rbac.ReadContent))
+	contents.PUT("/:id/translations/:locale", TranslateContents, rbac.Allow(rbac.WriteContent))
+	contents.DELETE("/:id/translations/:locale", UntranslateContents, rbac.Allow(rbac.WriteContent))
+}
+
+// registerContents adds the contents of the types defined so far to the
+// purge of the trash and to the revisions, types defined later are added
+// when they are used
+func registerContents() {
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return
+	}
+
+	records := make([]Types, 0)
+	if err = repo.FindAll(bson.M{}, nil, &records); err != nil {
+		return
+	}
+
+	for _, record := range records {
+		register(&record)
+	}
+}
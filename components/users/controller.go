@@ -1,22 +1,35 @@
 package users
 
 import (
-	"context"
+	"fmt"
 	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/labstack/echo/v4"
-	DB "github.com/muhammadardie/echo-cms/db"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/patch"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/trash"
 	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"golang.org/x/crypto/bcrypt"
 )
 
-var ctx = context.Background()
-
 const colName = "users"
 
+// fields that can be used to filter and sort the list
+var listFields = utils.Fields{
+	"username": "username",
+	"email":    "email",
+	"role":     "role",
+}
+
 // Get Users godoc
 // @Summary Get recent user
 // @Description Get most recent user
@@ -25,29 +38,48 @@ const colName = "users"
 // @Accept  json
 // @Produce  json
 // @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param q query string false "Search username and email"
+// @Param username query string false "Filter by username"
+// @Param email query string false "Filter by email"
+// @Param role query string false "Filter by role"
 // @Success 200 {object} utils.HttpSuccess{data=[]Users}
 // @Failure 400 {object} utils.HttpError
 // @Failure 401 {object} utils.HttpError
 // @Router /users [get]
 func Get(c echo.Context) error {
-	db, err := DB.Connect()
+	query, err := utils.NewListQuery(c, listFields, PublicUsers{})
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	query.Where(trash.Active(bson.M{}))
+
+	if q := strings.TrimSpace(c.QueryParam("q")); q != "" {
+		pattern := primitive.Regex{Pattern: regexp.QuoteMeta(q), Options: "i"}
+		query.Where(bson.M{"$or": bson.A{
+			bson.M{"username": pattern},
+			bson.M{"email": pattern},
+		}})
+	}
 
-	csr, err := db.Collection(colName).Find(ctx, bson.M{})
+	repo, err := repository.Open(colName)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	defer csr.Close(ctx)
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
 
 	result := make([]PublicUsers, 0)
-	if err = csr.All(ctx, &result); err != nil {
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
 
-	return c.JSON(http.StatusOK, utils.NewSuccess(result, ""))
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
 }
 
 // Find Users godoc
@@ -70,25 +102,27 @@ func Find(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
 	}
 
-	db, err := DB.Connect()
+	repo, err := repository.Open(colName)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
 	}
 
-	selector := bson.M{"_id": id}
+	selector := trash.Active(bson.M{"_id": id})
 
 	var record Users
 
-	if err = db.Collection(colName).FindOne(ctx, selector).Decode(&record); err != nil {
+	if err = repo.FindOne(selector, &record); err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
+	version.SetETag(c, record.Version)
+
 	return c.JSON(http.StatusOK, utils.NewSuccess(record, ""))
 }
 
 // Create Users godoc
 // @Summary Create an info for page user
-// @Description Create an info for page user
+// @Description Create a user, a link to verify the email is mailed to them and they can log in once it is followed
 // @ID create-users
 // @Tags Users
 // @Accept  json
@@ -97,6 +131,7 @@ func Find(c echo.Context) error {
 // @Param username body string true "Users username"
 // @Param password body string true "Users password"
 // @Param email body string true "Users email"
+// @Param role body string false "Users role (admin, editor, author, viewer)"
 // @Success 200 {object} Users
 // @Failure 400 {object} utils.HttpError
 // @Failure 401 {object} utils.HttpError
@@ -105,7 +140,7 @@ func Find(c echo.Context) error {
 func Create(c echo.Context) error {
 
 	// Connect to the database
-	db, err := DB.Connect()
+	repo, err := repository.Open(colName)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to connect to DB")
 	}
@@ -118,7 +153,7 @@ func Create(c echo.Context) error {
 
 	// Validate required fields
 	if err := c.Validate(users); err != nil {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		return err
 	}
 
 	// Check for unique email
@@ -126,8 +161,7 @@ func Create(c echo.Context) error {
 		emailFilter := bson.M{
 			"email": users.Email,
 		}
-		existingUser := db.Collection(colName).FindOne(ctx, emailFilter)
-		if existingUser.Err() == nil { // Email already exists
+		if count, err := repo.Count(emailFilter); err == nil && count > 0 { // Email already exists
 			return echo.NewHTTPError(http.StatusConflict, "Email already exists")
 		}
 	}
@@ -144,17 +178,34 @@ func Create(c echo.Context) error {
 	}
 	users.Password = string(hashedPassword)
 
+	// New accounts get the least privileged role unless told otherwise
+	if users.Role == "" {
+		users.Role = rbac.RoleViewer
+	}
+
 	// Set additional fields
 	users.ID = primitive.NewObjectID()
 	users.CreatedAt = time.Now()
 	users.UpdatedAt = time.Now()
+	users.Version = 1
+
+	// The account can be used once the owner of the email confirmed it
+	unverified := false
+	users.EmailVerified = &unverified
 
 	// Insert the user into the database
-	_, err = db.Collection(colName).InsertOne(ctx, users)
+	err = repo.Insert(users)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to save user")
 	}
 
+	audits.Record(c, audits.ActionCreate, colName, users.ID, nil)
+
+	// The user can be sent another link when this one is lost
+	if err = sendVerification(users); err != nil {
+		c.Logger().Error(err)
+	}
+
 	// Respond with the created user (excluding sensitive data like password)
 	users.Password = "" // Avoid returning the password in the response
 	return c.JSON(http.StatusOK, utils.NewSuccess(users, "Saved"))
@@ -162,7 +213,7 @@ func Create(c echo.Context) error {
 
 // Update Users godoc
 // @Summary Update an info for page user
-// @Description Update an info for page user
+// @Description Update an info for page user, a new email is verified again with a link mailed to it
 // @ID update-user
 // @Tags Users
 // @Accept  json
@@ -172,6 +223,7 @@ func Create(c echo.Context) error {
 // @Param username body string true "Users username"
 // @Param password body string true "Users password"
 // @Param email body string true "Users email"
+// @Param role body string false "Users role (admin, editor, author, viewer)"
 // @Success 200 {object} Users
 // @Failure 400 {object} utils.HttpError
 // @Failure 401 {object} utils.HttpError
@@ -184,7 +236,7 @@ func Update(c echo.Context) error {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
 	}
 
-	db, err := DB.Connect()
+	repo, err := repository.Open(colName)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
 	}
@@ -196,7 +248,13 @@ func Update(c echo.Context) error {
 	}
 
 	if err := c.Validate(changes); err != nil {
-		return echo.NewHTTPError(http.StatusUnprocessableEntity, err.Error())
+		return err
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, changes.Version)
+	if err != nil {
+		return err
 	}
 
 	// Check for unique email
@@ -205,15 +263,38 @@ func Update(c echo.Context) error {
 			"email": changes.Email,
 			"_id":   bson.M{"$ne": id}, // Exclude the current user
 		}
-		existingUser := db.Collection(colName).FindOne(ctx, emailFilter)
-		if existingUser.Err() == nil { // Email already exists
+		if count, err := repo.Count(emailFilter); err == nil && count > 0 { // Email already exists
 			return echo.NewHTTPError(http.StatusConflict, "Email already exists")
 		}
 	}
 
+	var current Users
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &current); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+	emailChanged := changes.Email != "" && changes.Email != current.Email
+	roleChanged := changes.Role != "" && changes.Role != current.Role
+
 	updateFields := bson.M{
-		"username": changes.Username,
-		"email":    changes.Email,
+		"updated_at": time.Now(),
+	}
+
+	// empty values keep the current ones
+	if changes.Username != "" {
+		updateFields["username"] = changes.Username
+	}
+	if changes.DisplayName != "" {
+		updateFields["display_name"] = changes.DisplayName
+	}
+
+	// A new email is verified again before the user can log in
+	if emailChanged {
+		updateFields["email"] = changes.Email
+		updateFields["email_verified"] = false
+	}
+
+	if changes.Role != "" {
+		updateFields["role"] = changes.Role
 	}
 
 	// Check if password is provided
@@ -225,20 +306,184 @@ func Update(c echo.Context) error {
 		updateFields["password"] = string(hashedPassword)
 	}
 
-	selector := bson.M{"_id": id}
-	update := bson.M{"$set": updateFields}
+	selector := trash.Active(bson.M{"_id": id})
+	if submitted != 0 {
+		selector = version.Selector(selector, submitted)
+	}
+	update := version.Bump(bson.M{"$set": updateFields})
 
-	result, err := db.Collection(colName).UpdateOne(ctx, selector, update)
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(selector, update)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user")
 	}
+	if result.MatchedCount == 0 && submitted != 0 {
+		latest := new(Users)
+		err = version.Reload(repo, id, latest)
+		latest.Password = "" // Avoid returning the password in the response
+		return err
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	// A new password or role logs the user out everywhere, the tokens carry the role
+	if changes.Password != "" || (roleChanged && result.MatchedCount > 0) {
+		if err = tokens.Revoke(id.Hex()); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	if emailChanged && result.MatchedCount > 0 {
+		current.Email = changes.Email
+		if changes.Username != "" {
+			current.Username = changes.Username
+		}
+		if err = sendVerification(&current); err != nil {
+			c.Logger().Error(err)
+		}
+	}
 
 	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Updated successfully"))
 }
 
+// Patch Users godoc
+// @Summary Change some fields of an user
+// @Description Change the fields of the JSON merge patch (RFC 7396), the others are left as they are. A new email is verified again with a link mailed to it. An If-Match header, or the version of the patch, makes the change fail with 409 Conflict when the user changed since that version.
+// @ID patch-user
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of user to change"
+// @Param If-Match header string false "ETag, the version of the user the change is based on"
+// @Param patch body UpdateUser true "Fields to change"
+// @Success 200 {object} utils.HttpSuccess{data=Users}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /users/{id} [patch]
+func Patch(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	doc, based, err := patch.Read(c)
+	if err != nil {
+		return err
+	}
+
+	submitted, err := version.Submitted(c, based)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var record Users
+
+	if err = repo.FindOne(selector, &record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	latest, email, role := record.Version, record.Email, record.Role
+	if err = version.Check(submitted, latest, &record); err != nil {
+		record.Password = "" // Avoid returning the password in the response
+		return err
+	}
+
+	/* the account can not lose the fields it logs in and is authorized with */
+	invalid := make([]utils.FieldError, 0)
+	for _, field := range []string{"username", "email", "password", "role"} {
+		if value, ok := doc[field]; ok && (value == nil || value == "") {
+			invalid = append(invalid, utils.FieldError{Field: field, Code: "required", Message: fmt.Sprintf("%s can not be empty", field)})
+		}
+	}
+	if len(invalid) > 0 {
+		return utils.NewValidationError(invalid...)
+	}
+
+	update, err := doc.Apply(&record, "username", "displayName", "email", "password", "role")
+	if err != nil {
+		return err
+	}
+
+	if err = c.Validate(record); err != nil {
+		return err
+	}
+
+	// Check for unique email
+	if _, ok := doc["email"]; ok {
+		emailFilter := bson.M{
+			"email": record.Email,
+			"_id":   bson.M{"$ne": id}, // Exclude the current user
+		}
+		if count, err := repo.Count(emailFilter); err == nil && count > 0 { // Email already exists
+			return echo.NewHTTPError(http.StatusConflict, "Email already exists")
+		}
+	}
+
+	// A new email is verified again before the user can log in
+	emailChanged := record.Email != email
+	if emailChanged {
+		unverified := false
+		record.EmailVerified = &unverified
+		update["$set"].(bson.M)["email_verified"] = false
+	}
+
+	// Hash the new password
+	if _, ok := doc["password"]; ok {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(record.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, "Failed to hash password")
+		}
+		update["$set"].(bson.M)["password"] = string(hashedPassword)
+	}
+
+	before := audits.Snapshot(colName, id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user")
+	}
+	if result.MatchedCount == 0 {
+		latest := new(Users)
+		err = version.Reload(repo, id, latest)
+		latest.Password = "" // Avoid returning the password in the response
+		return err
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	// A new password or role logs the user out everywhere, the tokens carry the role
+	if _, ok := doc["password"]; ok || record.Role != role {
+		if err = tokens.Revoke(id.Hex()); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	if emailChanged {
+		if err = sendVerification(&record); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	version.SetETag(c, record.Version)
+
+	record.Password = "" // Avoid returning the password in the response
+	return c.JSON(http.StatusOK, utils.NewSuccess(record, "Updated successfully"))
+}
+
 // Delete Users godoc
 // @Summary Delete an user info
-// @Description Delete an user info
+// @Description Move an user to the trash and revoke every session of the user
 // @ID delete-user
 // @Tags Users
 // @Accept  json
@@ -249,26 +494,73 @@ func Update(c echo.Context) error {
 // @Failure 401 {object} utils.HttpError
 // @Router /users/{id} [delete]
 func Destroy(c echo.Context) error {
-	ctx := context.Background()
 	id, err := primitive.ObjectIDFromHex(c.Param("id"))
 
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
 	}
 
-	db, err := DB.Connect()
+	repo, err := repository.Open(colName)
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
 	}
 
-	selector := bson.M{"_id": id}
+	selector := trash.Active(bson.M{"_id": id})
 
-	/* delete record */
-	result, err := db.Collection(colName).DeleteOne(ctx, selector)
+	before := audits.Snapshot(colName, id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
 
 	if err != nil {
 		return echo.NewHTTPError(http.StatusBadRequest, err)
 	}
 
+	audits.Record(c, audits.ActionDelete, colName, id, before)
+
+	// A deleted user is logged out everywhere
+	if result.MatchedCount > 0 {
+		if err = tokens.Revoke(id.Hex()); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
 	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
 }
+
+// Trash Users godoc
+// @Summary Get deleted users
+// @Description Get the users in the trash, they are purged for good after the retention period
+// @ID trash-users
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -deletedAt)"
+// @Success 200 {object} utils.HttpSuccess{data=[]PublicUsers}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /users/trash [get]
+func Trash(c echo.Context) error {
+	return trash.List(c, colName, &[]PublicUsers{})
+}
+
+// Restore Users godoc
+// @Summary Restore deleted users
+// @Description Take a record of users out of the trash
+// @ID restore-users
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the deleted record"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /users/{id}/restore [post]
+func Restore(c echo.Context) error {
+	return trash.Restore(c, colName)
+}
@@ -0,0 +1,58 @@
+package users
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// collection recording the migrations that ran, by name
+const migrationsCollection = "migrations"
+
+// name of the migration giving the accounts a role
+const migrationRoles = "users-roles"
+
+// MigrateRoles gives the accounts created before roles were introduced
+// the admin role, the full access they had until then. It runs once and
+// is recorded, a user left without a role afterwards is granted nothing.
+func MigrateRoles() (int, error) {
+	migrations, err := repository.Open(migrationsCollection)
+	if err != nil {
+		return 0, err
+	}
+
+	if done, err := migrations.Count(bson.M{"_id": migrationRoles}); err != nil || done > 0 {
+		return 0, err
+	}
+
+	repo, err := repository.Open("users")
+	if err != nil {
+		return 0, err
+	}
+
+	filter := bson.M{"$or": bson.A{
+		bson.M{"role": bson.M{"$exists": false}},
+		bson.M{"role": ""},
+		bson.M{"role": nil},
+	}}
+
+	result := make([]PublicUsers, 0)
+	if err = repo.FindAll(filter, nil, &result); err != nil {
+		return 0, err
+	}
+
+	for _, user := range result {
+		if _, err = repo.Update(bson.M{"_id": user.ID}, bson.M{"$set": bson.M{"role": rbac.RoleAdmin}}); err != nil {
+			return 0, fmt.Errorf("users: give %s a role: %w", user.ID.Hex(), err)
+		}
+	}
+
+	if err = migrations.Insert(bson.M{"_id": migrationRoles, "count": len(result), "ran_at": time.Now()}); err != nil {
+		return len(result), fmt.Errorf("users: record the %s migration: %w", migrationRoles, err)
+	}
+
+	return len(result), nil
+}
@@ -12,24 +12,43 @@ type UserLogin struct {
 }
 
 type PublicUsers struct {
-	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty" swaggerignore:"true"`
-	Username  string             `json:"username"`
-	Email     string             `json:"email"`
-	CreatedAt time.Time          `json:"createdAt,omitempty"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty"`
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty" swaggerignore:"true"`
+	Username    string             `json:"username"`
+	DisplayName string             `json:"displayName,omitempty" bson:"display_name,omitempty"`
+	Email       string             `json:"email"`
+	Role        string             `json:"role"`
+	// nil for the accounts created before emails were verified
+	EmailVerified *bool      `json:"emailVerified,omitempty" bson:"email_verified,omitempty"`
+	Version       int64      `json:"version" bson:"version" form:"version"`
+	CreatedAt     time.Time  `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time  `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
 }
 
 type Users struct {
-	ID        primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty" swaggerignore:"true"`
-	Username  string             `json:"username" bson:"username" form:"username" query:"username" swaggerignore:"true"`
-	Email     string             `json:"email" bson:"email,omitempty" form:"email" query:"email" validate:"required,email"`
-	Password  string             `json:"password,omitempty" bson:"password,omitempty" form:"password" query:"password" validate:"required"`
-	CreatedAt time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty" swaggerignore:"true"`
-	UpdatedAt time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty" swaggerignore:"true"`
+	ID          primitive.ObjectID `json:"_id,omitempty" bson:"_id,omitempty" swaggerignore:"true"`
+	Username    string             `json:"username" bson:"username" form:"username" query:"username" swaggerignore:"true"`
+	DisplayName string             `json:"displayName,omitempty" bson:"display_name,omitempty" form:"displayName" query:"displayName"`
+	Email       string             `json:"email" bson:"email,omitempty" form:"email" query:"email" validate:"required,email"`
+	Password    string             `json:"password,omitempty" bson:"password,omitempty" form:"password" query:"password" validate:"required"`
+	Role        string             `json:"role" bson:"role,omitempty" form:"role" query:"role" validate:"omitempty,oneof=admin editor author viewer"`
+	// nil for the accounts created before emails were verified
+	EmailVerified *bool      `json:"emailVerified,omitempty" bson:"email_verified,omitempty" swaggerignore:"true"`
+	Version       int64      `json:"version" bson:"version" form:"version"`
+	CreatedAt     time.Time  `json:"createdAt,omitempty" bson:"created_at,omitempty" swaggerignore:"true"`
+	UpdatedAt     time.Time  `json:"updatedAt,omitempty" bson:"updated_at,omitempty" swaggerignore:"true"`
+	DeletedAt     *time.Time `json:"deletedAt,omitempty" bson:"deleted_at,omitempty" swaggerignore:"true"`
 }
 
 type UpdateUser struct {
-	Username string `json:"username,omitempty" bson:"username,omitempty" form:"username" query:"username"`
-	Email    string `json:"email,omitempty" bson:"email,omitempty" form:"email" query:"email" validate:"omitempty,email"`
-	Password string `json:"password,omitempty" bson:"password,omitempty" form:"password" query:"password"` // No "required" validation
+	Username    string `json:"username,omitempty" bson:"username,omitempty" form:"username" query:"username"`
+	DisplayName string `json:"displayName,omitempty" bson:"display_name,omitempty" form:"displayName" query:"displayName"`
+	Email       string `json:"email,omitempty" bson:"email,omitempty" form:"email" query:"email" validate:"omitempty,email"`
+	Password    string `json:"password,omitempty" bson:"password,omitempty" form:"password" query:"password"` // No "required" validation
+	Role        string `json:"role,omitempty" bson:"role,omitempty" form:"role" query:"role" validate:"omitempty,oneof=admin editor author viewer"`
+	Version     int64  `json:"version,omitempty" bson:"-" form:"version"` // version the change is based on
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" validate:"required"`
 }
@@ -2,13 +2,23 @@ package users
 
 import (
 	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/trash"
 )
 
 func UsersRegister(g *echo.Group) {
+	trash.Register(colName, nil)
+
 	users := g.Group("/users")
-	users.GET("", Get)
-	users.POST("", Create)
-	users.GET("/:id", Find)
-	users.PUT("/:id", Update)
-	users.DELETE("/:id", Destroy)
+	users.GET("", Get, rbac.Allow(rbac.ManageUsers))
+	users.GET("/trash", Trash, rbac.Allow(rbac.ManageUsers))
+	users.POST("", Create, rbac.Allow(rbac.ManageUsers))
+	users.GET("/:id", Find, rbac.Allow(rbac.ManageUsers))
+	users.PUT("/:id", Update, rbac.Allow(rbac.ManageUsers))
+	users.PATCH("/:id", Patch, rbac.Allow(rbac.ManageUsers))
+	users.DELETE("/:id", Destroy, rbac.Allow(rbac.ManageUsers))
+	users.POST("/:id/restore", Restore, rbac.Allow(rbac.ManageUsers))
+	users.POST("/:id/verification", ResendVerification, rbac.Allow(rbac.ManageUsers))
+	users.GET("/:id/sessions", GetSessions, rbac.Allow(rbac.ManageUsers))
+	users.DELETE("/:id/sessions", Logout, rbac.Allow(rbac.ManageUsers))
 }
@@ -0,0 +1,87 @@
+package users
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GetSessions godoc
+// @Summary Get sessions of an user
+// @Description Get the sessions the user is logged in with, the newest first
+// @ID get-sessions-user
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the user"
+// @Success 200 {object} utils.HttpSuccess{data=[]tokens.Session}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /users/{id}/sessions [get]
+func GetSessions(c echo.Context) error {
+	id, err := existing(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	sessions, err := tokens.Sessions(id.Hex())
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(sessions, ""))
+}
+
+// Logout godoc
+// @Summary Log an user out
+// @Description Revoke every session of the user, e.g. when the account is compromised. The user must log in again.
+// @ID logout-user
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the user"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /users/{id}/sessions [delete]
+func Logout(c echo.Context) error {
+	id, err := existing(c.Param("id"))
+	if err != nil {
+		return err
+	}
+
+	if err = tokens.Revoke(id.Hex()); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(nil, "Every session revoked"))
+}
+
+// existing returns the ID of the param when a user has it, the users in
+// the trash included so their sessions can still be ended
+func existing(param string) (primitive.ObjectID, error) {
+	id, err := primitive.ObjectIDFromHex(param)
+	if err != nil {
+		return id, echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return id, echo.NewHTTPError(http.StatusInternalServerError, "Failed to connect to DB")
+	}
+
+	if count, err := repo.Count(bson.M{"_id": id}); err != nil || count == 0 {
+		return id, echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	return id, nil
+}
@@ -0,0 +1,176 @@
+package users_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/users"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// newServer serves the users from a memory driver holding the user, the
+// requests are made by an admin
+func newServer(t *testing.T, user users.Users) *echo.Echo {
+	t.Helper()
+
+	repository.Use(repository.NewMemory())
+	repo, _ := repository.Open("users")
+	if err := repo.Insert(user); err != nil {
+		t.Fatal(err)
+	}
+
+	// the sessions and verification tokens are kept in Redis, none runs here
+	os.Setenv("REDIS_URL", "redis://127.0.0.1:1")
+
+	e := echo.New()
+	e.Validator = middleware.NewValidator()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+
+	admin := func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			rbac.SetActor(c, &rbac.Actor{UserId: primitive.NewObjectID().Hex(), Role: rbac.RoleAdmin})
+
+			return next(c)
+		}
+	}
+	e.PUT("/users/:id", users.Update, admin)
+	e.PATCH("/users/:id", users.Patch, admin)
+
+	return e
+}
+
+func patchUser(e *echo.Echo, id primitive.ObjectID, doc string) *httptest.ResponseRecorder {
+	return sendUser(e, http.MethodPatch, id, doc)
+}
+
+func sendUser(e *echo.Echo, method string, id primitive.ObjectID, doc string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/users/"+id.Hex(), strings.NewReader(doc))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPatchKeepsCredentials(t *testing.T) {
+	verified := true
+	user := users.Users{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@example.com", Password: "hash", Role: rbac.RoleEditor, EmailVerified: &verified, Version: 1}
+	e := newServer(t, user)
+
+	for _, doc := range []string{
+		`{"role":null}`,
+		`{"role":""}`,
+		`{"username":null}`,
+		`{"username":""}`,
+		`{"email":null}`,
+		`{"password":""}`,
+	} {
+		if rec := patchUser(e, user.ID, doc); rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("patch %s = %d, want 422", doc, rec.Code)
+		}
+	}
+
+	repo, _ := repository.Open("users")
+	var stored users.Users
+	if err := repo.FindByID(user.ID, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Role != rbac.RoleEditor || stored.Username != "jane" || stored.Version != 1 {
+		t.Fatalf("stored = %+v", stored)
+	}
+
+	if rec := patchUser(e, user.ID, `{"role":"viewer"}`); rec.Code != http.StatusOK {
+		t.Fatalf("patch the role = %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	user := users.Users{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@example.com", Password: "hash", Role: rbac.RoleAuthor, Version: 1}
+	e := newServer(t, user)
+	repo, _ := repository.Open("users")
+
+	if rec := sendUser(e, http.MethodPut, user.ID, `{"displayName":"Jane Doe"}`); rec.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", rec.Code, rec.Body.String())
+	}
+	var stored users.Users
+	if repo.FindByID(user.ID, &stored); stored.DisplayName != "Jane Doe" {
+		t.Fatalf("display name after the update = %q", stored.DisplayName)
+	}
+
+	/* unlike the username, the display name may be removed */
+	if rec := patchUser(e, user.ID, `{"displayName":null}`); rec.Code != http.StatusOK {
+		t.Fatalf("patch = %d %s", rec.Code, rec.Body.String())
+	}
+	var patched users.Users
+	if repo.FindByID(user.ID, &patched); patched.DisplayName != "" || patched.Username != "jane" {
+		t.Fatalf("patched = %+v", patched)
+	}
+}
+
+func TestNewEmailIsVerified(t *testing.T) {
+	for _, method := range []string{http.MethodPut, http.MethodPatch} {
+		verified := true
+		user := users.Users{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@example.com", Password: "hash", Role: rbac.RoleEditor, EmailVerified: &verified, Version: 1}
+		e := newServer(t, user)
+		repo, _ := repository.Open("users")
+
+		if rec := sendUser(e, method, user.ID, `{"email":"jane@example.com","username":"janet"}`); rec.Code != http.StatusOK {
+			t.Fatalf("%s the same email = %d %s", method, rec.Code, rec.Body.String())
+		}
+		var stored users.Users
+		if err := repo.FindByID(user.ID, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.EmailVerified == nil || !*stored.EmailVerified {
+			t.Fatalf("%s the same email: verified = %v", method, stored.EmailVerified)
+		}
+
+		if rec := sendUser(e, method, user.ID, `{"email":"janet@example.com"}`); rec.Code != http.StatusOK {
+			t.Fatalf("%s a new email = %d %s", method, rec.Code, rec.Body.String())
+		}
+		if err := repo.FindByID(user.ID, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if stored.Email != "janet@example.com" || stored.EmailVerified == nil || *stored.EmailVerified {
+			t.Fatalf("%s a new email: stored = %+v", method, stored)
+		}
+	}
+}
+
+func TestMigrateRolesRunsOnce(t *testing.T) {
+	legacy := users.Users{ID: primitive.NewObjectID(), Username: "jane", Email: "jane@example.com", Password: "hash", Version: 1}
+	newServer(t, legacy)
+	repo, _ := repository.Open("users")
+
+	if migrated, err := users.MigrateRoles(); err != nil || migrated != 1 {
+		t.Fatalf("migrated = %d %v", migrated, err)
+	}
+
+	/* a user left without a role afterwards is not made an admin */
+	later := users.Users{ID: primitive.NewObjectID(), Username: "bob", Email: "bob@example.com", Password: "hash", Version: 1}
+	if err := repo.Insert(later); err != nil {
+		t.Fatal(err)
+	}
+	if migrated, err := users.MigrateRoles(); err != nil || migrated != 0 {
+		t.Fatalf("migrated again = %d %v", migrated, err)
+	}
+
+	var stored users.Users
+	if err := repo.FindByID(legacy.ID, &stored); err != nil || stored.Role != rbac.RoleAdmin {
+		t.Fatalf("legacy user = %+v %v", stored, err)
+	}
+	var unmigrated users.Users
+	if err := repo.FindByID(later.ID, &unmigrated); err != nil || unmigrated.Role != "" {
+		t.Fatalf("later user = %+v %v", unmigrated, err)
+	}
+}
@@ -0,0 +1,159 @@
+package users
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/mail"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/tokens"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// mail with the link to verify the email of a new user
+const templateVerification = "email-verification"
+
+// kind of the tokens of the verification links
+const verificationKind = "email-verification"
+
+// how long the link of a verification mail can be used
+const verificationTTL = 48 * time.Hour
+
+func init() {
+	mail.Register(templateVerification, "Sent to a new user, or a user whose email changed, to verify the email, the account can be used once the link is followed within two days",
+		`Verify your email`,
+		"Hello {{.Username}},\n\nFollow the link within two days to verify your email and log in:\n\n{{.Link}}\n",
+		`<p>Hello {{.Username}},</p><p>Follow the link within two days to verify your email and log in:</p><p><a href="{{.Link}}">{{.Link}}</a></p>`,
+		mail.Data{"Username": "jane", "Link": "https://example.com/verify-email?token=0123456789abcdef"})
+}
+
+// sendVerification mails the user a link to verify the email, the link
+// opens EMAIL_VERIFICATION_URL with the token. The token holds the email,
+// the link of an email the user had before does not verify the new one.
+func sendVerification(user *Users) error {
+	token, err := tokens.Issue(verificationKind, user.ID.Hex()+" "+user.Email, verificationTTL)
+	if err != nil {
+		return err
+	}
+
+	data := mail.Data{"Username": user.Username, "Link": mail.Secret(os.Getenv("EMAIL_VERIFICATION_URL") + "?token=" + token)}
+
+	return mail.Send(templateVerification, []string{user.Email}, "", data)
+}
+
+// Verify godoc
+// @Summary Verify the email of a user
+// @Description Verify the email with the token of a verification mail, the user can log in afterwards. The token can't be used again.
+// @ID verify-email
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Param token body VerifyEmail true "Token of the mail"
+// @Success 200 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /email/verify [post]
+func Verify(c echo.Context) error {
+	request := new(VerifyEmail)
+	if err := c.Bind(request); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+
+	if err := utils.Validate(c, request); err != nil {
+		return err
+	}
+
+	invalid := echo.NewHTTPError(http.StatusBadRequest, "Invalid or expired token")
+
+	value, err := tokens.Redeem(verificationKind, request.Token)
+	if err == tokens.ErrInvalid {
+		return invalid
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	/* the tokens mailed before they held the email are the ID alone */
+	parts := strings.SplitN(value, " ", 2)
+	id, err := primitive.ObjectIDFromHex(parts[0])
+	if err != nil {
+		return invalid
+	}
+	selector := bson.M{"_id": id}
+	if len(parts) == 2 {
+		selector["email"] = parts[1]
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to connect to DB")
+	}
+
+	before := audits.Snapshot(colName, id)
+	update := version.Bump(bson.M{"$set": bson.M{"email_verified": true, "updated_at": time.Now()}})
+	result, err := repo.Update(trash.Active(selector), update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to update user")
+	}
+	if result.MatchedCount == 0 {
+		return invalid
+	}
+
+	audits.Record(c, audits.ActionUpdate, colName, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(nil, "Email verified"))
+}
+
+// ResendVerification godoc
+// @Summary Send the verification mail again
+// @Description Mail a new link to verify the email to a user who hasn't followed the first one
+// @ID resend-verification-user
+// @Tags Users
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the user"
+// @Success 202 {object} utils.HttpSuccess
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /users/{id}/verification [post]
+func ResendVerification(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(colName)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, "Failed to connect to DB")
+	}
+
+	var user Users
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &user); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "User not found")
+	}
+
+	if user.EmailVerified == nil || *user.EmailVerified {
+		return echo.NewHTTPError(http.StatusConflict, "Email is verified already")
+	}
+
+	if err = sendVerification(&user); err != nil {
+		return echo.NewHTTPError(http.StatusBadGateway, "Failed to send the mail")
+	}
+
+	accepted := utils.NewSuccess(nil, "Verification mail sent")
+	accepted.Code = http.StatusAccepted
+
+	return c.JSON(http.StatusAccepted, accepted)
+}
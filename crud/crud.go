@@ -0,0 +1,156 @@
+package crud
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Options describes a content type served by a resource
+type Options struct {
+	// Collection holds the documents, it is also the path of the routes
+	Collection string
+
+	// Model is the struct of a document, its form tags bind the request
+	// and its validate tags check a new document
+	Model interface{}
+
+	// ListFields can be used to filter and sort the list
+	ListFields utils.Fields
+
+	// UploadDir is the directory of the uploaded images in the storage,
+	// documents have no image when it is empty
+	UploadDir string
+
+	// ImageField is the form and bson name of the image, defaults to image
+	ImageField string
+
+	// Slug is the bson name of the text the slug of a document is made
+	// from, the public site finds the documents by slug as well as by ID.
+	// Documents have no slug when it is empty.
+	Slug string
+
+	// Read and Write are the permissions of the routes, they default to
+	// rbac.ReadContent and rbac.WriteContent
+	Read  rbac.Permission
+	Write rbac.Permission
+
+	// Public serves the list and the documents read-only on the public site
+	Public bool
+
+	// PublicScope narrows the documents served on the public site
+	PublicScope func() bson.M
+
+	// Translatable are the json names of the text fields translated per
+	// locale, the public site serves them in the locale of the request
+	Translatable []string
+
+	// Prepare fills the fields the form does not bind, current is nil when
+	// the document is created
+	Prepare func(c echo.Context, record interface{}, current interface{}) error
+
+	// Authorize tells whether the document may be changed by the current
+	// user, action is one of the audits actions
+	Authorize func(c echo.Context, record interface{}, action string) error
+}
+
+// Resource serves the list, find, create, update, patch, delete, trash,
+// restore and translations routes of a content type
+type Resource struct {
+	Options
+
+	model *model
+}
+
+/* Used to serve the public routes of every resource. */
+var resources []*Resource
+var resourcesMutex sync.Mutex
+
+// New describes a resource from the options, it panics when the model
+// is not a struct since the resources are declared on start up
+func New(opts Options) *Resource {
+	if opts.ImageField == "" {
+		opts.ImageField = "image"
+	}
+	if opts.Read == "" {
+		opts.Read = rbac.ReadContent
+	}
+	if opts.Write == "" {
+		opts.Write = rbac.WriteContent
+	}
+
+	r := &Resource{Options: opts, model: newModel(opts.Model, opts.ImageField)}
+
+	src := revisions.Source{UploadDir: opts.UploadDir}
+	if opts.UploadDir != "" {
+		src.Images = revisions.ImageField(opts.ImageField)
+	}
+	revisions.Register(opts.Collection, src)
+
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+
+	resources = append(resources, r)
+
+	return r
+}
+
+// Register wires the routes of the resource to the group
+func (r *Resource) Register(g *echo.Group) {
+	trash.Register(r.Collection, r.purge)
+
+	group := g.Group("/" + r.Collection)
+	group.GET("", r.Get, rbac.Allow(r.Read))
+	group.GET("/trash", r.Trash, rbac.Allow(r.Read))
+	group.POST("", r.Create, rbac.Allow(r.Write))
+	group.GET("/:id", r.Find, rbac.Allow(r.Read))
+	group.PUT("/:id", r.Update, rbac.Allow(r.Write))
+	group.PATCH("/:id", r.Patch, rbac.Allow(r.Write))
+	group.DELETE("/:id", r.Destroy, rbac.Allow(r.Write))
+	group.POST("/:id/restore", r.Restore, rbac.Allow(r.Write))
+
+	if len(r.Translatable) > 0 {
+		group.GET("/:id/translations", r.Translations, rbac.Allow(r.Read))
+		group.PUT("/:id/translations/:locale", r.Translate, rbac.Allow(r.Write))
+		group.DELETE("/:id/translations/:locale", r.Untranslate, rbac.Allow(r.Write))
+	}
+}
+
+// EnsureIndexes creates the indexes of every resource, the slugs are
+// unique in their collection
+func EnsureIndexes() error {
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+
+	for _, r := range resources {
+		if r.Slug == "" {
+			continue
+		}
+
+		if err := slug.Index(r.Collection); err != nil {
+			return fmt.Errorf("crud: unique slugs of %s: %w", r.Collection, err)
+		}
+	}
+
+	return nil
+}
+
+// RegisterPublic wires the read-only routes of the public resources to the group
+func RegisterPublic(g *echo.Group) {
+	resourcesMutex.Lock()
+	defer resourcesMutex.Unlock()
+
+	for _, r := range resources {
+		if r.Public {
+			g.GET("/"+r.Collection, r.GetPublic)
+			g.GET("/"+r.Collection+"/:id", r.FindPublic)
+		}
+	}
+}
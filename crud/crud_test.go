@@ -0,0 +1,478 @@
+package crud
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/images"
+	"github.com/muhammadardie/echo-cms/middleware"
+	"github.com/muhammadardie/echo-cms/rbac"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type note struct {
+	ID            primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Title         string             `json:"title" bson:"title" form:"title" validate:"required"`
+	Slug          string             `json:"slug" bson:"slug,omitempty" form:"slug"`
+	PreviousSlugs []string           `json:"previousSlugs,omitempty" bson:"previous_slugs,omitempty"`
+	Body          string             `json:"body" bson:"body,omitempty" form:"body"`
+	Version       int64              `json:"version" bson:"version" form:"version"`
+	CreatedAt     time.Time          `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt     time.Time          `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+	DeletedAt     *time.Time         `json:"deletedAt,omitempty" bson:"deleted_at,omitempty"`
+}
+
+var notes = New(Options{
+	Collection: "notes",
+	Model:      note{},
+	ListFields: map[string]string{"title": "title"},
+	Slug:       "title",
+	Public:     true,
+})
+
+type picture struct {
+	ID       primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	Title    string             `json:"title" bson:"title" form:"title" validate:"required"`
+	Image    string             `json:"image,omitempty" bson:"image,omitempty" form:"image" validate:"required"`
+	Variants *images.Variants   `json:"variants,omitempty" bson:"variants,omitempty"`
+	Media    primitive.ObjectID `json:"media,omitempty" bson:"media,omitempty" form:"media"`
+	Version  int64              `json:"version" bson:"version" form:"version"`
+}
+
+// pictures changes the document behind the back of the request when
+// the form says so, like a concurrent request would
+var pictures = New(Options{
+	Collection: "pictures",
+	Model:      picture{},
+	UploadDir:  "picture",
+	Prepare: func(c echo.Context, record interface{}, current interface{}) error {
+		if current != nil && c.FormValue("concurrent") != "" {
+			repo, _ := repository.Open("pictures")
+			repo.Update(bson.M{"_id": current.(*picture).ID}, bson.M{"$inc": bson.M{"version": 1}})
+		}
+
+		return nil
+	},
+})
+
+// response is the body of every response
+type response struct {
+	Data    json.RawMessage        `json:"data"`
+	Meta    map[string]interface{} `json:"meta"`
+	Message string                 `json:"message"`
+}
+
+// newServer serves the notes from an empty memory driver, the requests
+// are made by a user with the role of the X-Role header
+func newServer() *echo.Echo {
+	repository.Use(repository.NewMemory())
+
+	e := echo.New()
+	e.Validator = middleware.NewValidator()
+	e.HTTPErrorHandler = middleware.ErrorHandler
+	e.Logger.SetOutput(ioutil.Discard)
+
+	RegisterPublic(e.Group("/public"))
+
+	g := e.Group("/api", func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			role := c.Request().Header.Get("X-Role")
+			if role == "" {
+				role = rbac.RoleAdmin
+			}
+			rbac.SetActor(c, &rbac.Actor{UserId: primitive.NewObjectID().Hex(), Role: role})
+
+			return next(c)
+		}
+	})
+	notes.Register(g)
+	pictures.Register(g)
+	revisions.RevisionsRegister(g)
+
+	return e
+}
+
+// request serves the request and decodes the response, a url.Values body
+// is sent as a form and any other as JSON
+func request(t *testing.T, e *echo.Echo, method string, target string, body interface{}, headers ...string) (*httptest.ResponseRecorder, *response) {
+	t.Helper()
+
+	var req *http.Request
+	switch value := body.(type) {
+	case nil:
+		req = httptest.NewRequest(method, target, nil)
+	case url.Values:
+		req = httptest.NewRequest(method, target, strings.NewReader(value.Encode()))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	default:
+		raw, _ := json.Marshal(value)
+		req = httptest.NewRequest(method, target, strings.NewReader(string(raw)))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	res := &response{}
+	if err := json.Unmarshal(rec.Body.Bytes(), res); err != nil {
+		t.Fatalf("%s %s: invalid response %q", method, target, rec.Body.String())
+	}
+
+	return rec, res
+}
+
+// create stores a note with the title and returns it
+func create(t *testing.T, e *echo.Echo, title string) note {
+	t.Helper()
+
+	rec, res := request(t, e, http.MethodPost, "/api/notes", url.Values{"title": {title}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("create %q = %d %s", title, rec.Code, res.Message)
+	}
+
+	var created note
+	if err := json.Unmarshal(res.Data, &created); err != nil {
+		t.Fatal(err)
+	}
+
+	return created
+}
+
+func list(t *testing.T, e *echo.Echo, target string) ([]note, map[string]interface{}) {
+	t.Helper()
+
+	rec, res := request(t, e, http.MethodGet, target, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET %s = %d %s", target, rec.Code, res.Message)
+	}
+
+	var result []note
+	if err := json.Unmarshal(res.Data, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result, res.Meta
+}
+
+func TestCreate(t *testing.T) {
+	e := newServer()
+
+	created := create(t, e, "Hello World")
+	if created.ID.IsZero() || created.Slug != "hello-world" || created.Version != 1 {
+		t.Fatalf("created = %+v", created)
+	}
+
+	/* a second document with the same title gets the next slug */
+	if second := create(t, e, "Hello World"); second.Slug != "hello-world-2" {
+		t.Fatalf("slug of the second document = %s", second.Slug)
+	}
+
+	/* a JSON object is bound like a form */
+	rec, res := request(t, e, http.MethodPost, "/api/notes", map[string]interface{}{"title": "From JSON", "body": "text"})
+	var fromJSON note
+	json.Unmarshal(res.Data, &fromJSON)
+	if rec.Code != http.StatusOK || fromJSON.Body != "text" || fromJSON.Slug != "from-json" {
+		t.Fatalf("create from JSON = %d %+v", rec.Code, fromJSON)
+	}
+
+	rec, _ = request(t, e, http.MethodPost, "/api/notes", url.Values{"body": {"no title"}})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("create without a title = %d, want 422", rec.Code)
+	}
+
+	rec, _ = request(t, e, http.MethodPost, "/api/notes", url.Values{"title": {"x"}}, "X-Role", rbac.RoleViewer)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("create as a viewer = %d, want 403", rec.Code)
+	}
+}
+
+func TestFind(t *testing.T) {
+	e := newServer()
+	created := create(t, e, "Hello World")
+
+	rec, _ := request(t, e, http.MethodGet, "/api/notes/"+created.ID.Hex(), nil)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"1"` {
+		t.Fatalf("find = %d ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	/* the public site finds the documents by slug as well */
+	rec, res := request(t, e, http.MethodGet, "/public/notes/hello-world", nil)
+	if rec.Code != http.StatusOK || !strings.Contains(string(res.Data), created.ID.Hex()) {
+		t.Fatalf("find by slug = %d %s", rec.Code, res.Data)
+	}
+}
+
+func TestList(t *testing.T) {
+	e := newServer()
+	for _, title := range []string{"c", "a", "e", "b", "d"} {
+		create(t, e, title)
+	}
+
+	result, meta := list(t, e, "/api/notes?title=b")
+	if len(result) != 1 || result[0].Title != "b" || meta["total"] != float64(1) {
+		t.Fatalf("filtered list = %+v %v", result, meta)
+	}
+
+	/* the cursors page through the whole list, forward and back */
+	result, meta = list(t, e, "/api/notes?sort=title&limit=2")
+	titles := []string{}
+	for {
+		for _, record := range result {
+			titles = append(titles, record.Title)
+		}
+		next, _ := meta["nextCursor"].(string)
+		if next == "" {
+			break
+		}
+		result, meta = list(t, e, "/api/notes?sort=title&limit=2&cursor="+next)
+	}
+	if strings.Join(titles, "") != "abcde" {
+		t.Fatalf("titles = %v", titles)
+	}
+
+	result, _ = list(t, e, "/api/notes?sort=title&limit=2&cursor="+meta["prevCursor"].(string))
+	if len(result) != 2 || result[0].Title != "c" || result[1].Title != "d" {
+		t.Fatalf("previous page = %+v", result)
+	}
+
+	rec, _ := request(t, e, http.MethodGet, "/api/notes?sort=body", nil)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("sort by a field not listed = %d, want 400", rec.Code)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	e := newServer()
+	created := create(t, e, "Hello World")
+	target := "/api/notes/" + created.ID.Hex()
+
+	rec, _ := request(t, e, http.MethodPut, target, url.Values{"title": {"Bye"}, "version": {"1"}})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"2"` {
+		t.Fatalf("update = %d ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	rec, _ = request(t, e, http.MethodPut, target, map[string]interface{}{"body": "json", "version": 2})
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != `"3"` {
+		t.Fatalf("update from JSON = %d ETag %s", rec.Code, rec.Header().Get("ETag"))
+	}
+
+	/* a change based on the version before is a conflict */
+	rec, res := request(t, e, http.MethodPut, target, url.Values{"body": {"late"}}, "If-Match", `"1"`)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("outdated update = %d %s, want 409", rec.Code, res.Message)
+	}
+
+	rec, res = request(t, e, http.MethodGet, target, nil)
+	var current note
+	json.Unmarshal(res.Data, &current)
+	if current.Title != "Bye" || current.Body != "json" || current.Slug != "bye" || current.Version != 3 {
+		t.Fatalf("current = %+v", current)
+	}
+	if len(current.PreviousSlugs) != 1 || current.PreviousSlugs[0] != "hello-world" {
+		t.Fatalf("previous slugs = %v", current.PreviousSlugs)
+	}
+
+	/* the resource keeps the versions before each update */
+	saved, _ := repository.Open("revisions")
+	if count, err := saved.Count(bson.M{"collection": "notes", "document_id": created.ID}); err != nil || count != 2 {
+		t.Fatalf("revisions = %d %v, want 2", count, err)
+	}
+
+	/* the old slug redirects to the new one */
+	rec, _ = request(t, e, http.MethodGet, "/public/notes/hello-world", nil)
+	if rec.Code != http.StatusMovedPermanently || !strings.HasSuffix(rec.Header().Get("Location"), "/bye") {
+		t.Fatalf("old slug = %d %s", rec.Code, rec.Header().Get("Location"))
+	}
+}
+
+func TestPatch(t *testing.T) {
+	e := newServer()
+	created := create(t, e, "Hello World")
+	target := "/api/notes/" + created.ID.Hex()
+
+	rec, res := request(t, e, http.MethodPatch, target, map[string]interface{}{"body": "text"}, "If-Match", `"1"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch = %d %s", rec.Code, res.Message)
+	}
+
+	var patched note
+	json.Unmarshal(res.Data, &patched)
+	if patched.Title != "Hello World" || patched.Body != "text" || patched.Version != 2 {
+		t.Fatalf("patched = %+v", patched)
+	}
+
+	/* a required field can not be removed */
+	rec, _ = request(t, e, http.MethodPatch, target, map[string]interface{}{"title": nil})
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("patch without a title = %d, want 422", rec.Code)
+	}
+}
+
+func TestDestroyAndRestore(t *testing.T) {
+	e := newServer()
+	created := create(t, e, "Hello World")
+	target := "/api/notes/" + created.ID.Hex()
+
+	if rec, _ := request(t, e, http.MethodDelete, target, nil); rec.Code != http.StatusOK {
+		t.Fatalf("delete = %d", rec.Code)
+	}
+	if result, _ := list(t, e, "/api/notes"); len(result) != 0 {
+		t.Fatalf("list after delete = %+v", result)
+	}
+	if rec, _ := request(t, e, http.MethodGet, "/public/notes/hello-world", nil); rec.Code == http.StatusOK {
+		t.Fatal("a deleted document is on the public site")
+	}
+	if result, _ := list(t, e, "/api/notes/trash"); len(result) != 1 {
+		t.Fatalf("trash = %+v", result)
+	}
+
+	if rec, res := request(t, e, http.MethodPost, target+"/restore", nil); rec.Code != http.StatusOK {
+		t.Fatalf("restore = %d %s", rec.Code, res.Message)
+	}
+	if result, _ := list(t, e, "/api/notes"); len(result) != 1 {
+		t.Fatalf("list after restore = %+v", result)
+	}
+}
+
+func TestUpdateImage(t *testing.T) {
+	e := newServer()
+
+	mediaRepo, _ := repository.Open("media")
+	first := media.Media{ID: primitive.NewObjectID(), Variants: &images.Variants{}}
+	second := media.Media{ID: primitive.NewObjectID(), Variants: &images.Variants{}}
+	record := picture{ID: primitive.NewObjectID(), Title: "Picture", Media: first.ID, Version: 1}
+	first.References = []media.Reference{{Collection: "pictures", ID: record.ID}}
+	mediaRepo.Insert(first)
+	mediaRepo.Insert(second)
+	repo, _ := repository.Open("pictures")
+	repo.Insert(record)
+
+	references := func(id primitive.ObjectID) int {
+		var item media.Media
+		if err := mediaRepo.FindByID(id, &item); err != nil {
+			t.Fatal(err)
+		}
+		return len(item.References)
+	}
+	target := "/api/pictures/" + record.ID.Hex()
+
+	/* a change lost to a concurrent one leaves the media as they were */
+	rec, _ := request(t, e, http.MethodPut, target, url.Values{"media": {second.ID.Hex()}, "concurrent": {"1"}, "version": {"1"}})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("concurrent update = %d, want 409", rec.Code)
+	}
+	if references(first.ID) != 1 || references(second.ID) != 0 {
+		t.Fatalf("references after the conflict = %d %d, want 1 0", references(first.ID), references(second.ID))
+	}
+
+	rec, res := request(t, e, http.MethodPut, target, url.Values{"media": {second.ID.Hex()}}, "If-Match", `"2"`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", rec.Code, res.Message)
+	}
+	if references(first.ID) != 0 || references(second.ID) != 1 {
+		t.Fatalf("references after the update = %d %d, want 0 1", references(first.ID), references(second.ID))
+	}
+
+	/* picking the current media again keeps its reference */
+	rec, res = request(t, e, http.MethodPut, target, url.Values{"media": {second.ID.Hex()}}, "If-Match", `"3"`)
+	if rec.Code != http.StatusOK || references(second.ID) != 1 {
+		t.Fatalf("update with the same media = %d %s, references %d", rec.Code, res.Message, references(second.ID))
+	}
+}
+
+func TestUniqueSlugs(t *testing.T) {
+	e := newServer()
+	if err := EnsureIndexes(); err != nil {
+		t.Fatal(err)
+	}
+
+	create(t, e, "Hello World")
+
+	/* a document written with a used slug, like one created at the same time */
+	repo, _ := repository.Open("notes")
+	err := repo.Insert(note{ID: primitive.NewObjectID(), Title: "Other", Slug: "hello-world"})
+	if !repository.IsDuplicate(err) {
+		t.Fatalf("insert with a used slug = %v", err)
+	}
+	if used := slug.Conflict(err, "hello-world"); used == nil {
+		t.Fatal("Conflict of a duplicate slug is nil")
+	}
+}
+
+func TestRestoreImage(t *testing.T) {
+	// the cache is cleared after a restore, the calls fail fast and are logged
+	os.Setenv("REDIS_URL", "redis://127.0.0.1:1")
+	e := newServer()
+
+	mediaRepo, _ := repository.Open("media")
+	first := media.Media{ID: primitive.NewObjectID(), Variants: &images.Variants{}}
+	second := media.Media{ID: primitive.NewObjectID(), Variants: &images.Variants{}}
+	record := picture{ID: primitive.NewObjectID(), Title: "Picture", Media: first.ID, Version: 1}
+	first.References = []media.Reference{{Collection: "pictures", ID: record.ID}}
+	mediaRepo.Insert(first)
+	mediaRepo.Insert(second)
+	repo, _ := repository.Open("pictures")
+	repo.Insert(record)
+
+	references := func(id primitive.ObjectID) int {
+		var item media.Media
+		if err := mediaRepo.FindByID(id, &item); err != nil {
+			t.Fatal(err)
+		}
+		return len(item.References)
+	}
+
+	rec, res := request(t, e, http.MethodPut, "/api/pictures/"+record.ID.Hex(), url.Values{"media": {second.ID.Hex()}, "version": {"1"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("update = %d %s", rec.Code, res.Message)
+	}
+
+	var saved []revisions.Revisions
+	_, res = request(t, e, http.MethodGet, "/api/revisions?collection=pictures", nil)
+	json.Unmarshal(res.Data, &saved)
+	if len(saved) != 1 {
+		t.Fatalf("revisions = %+v", saved)
+	}
+	target := "/api/revisions/" + saved[0].ID.Hex() + "/restore"
+
+	/* a media item gone to the trash can not be restored, nothing changes */
+	mediaRepo.Update(bson.M{"_id": first.ID}, bson.M{"$set": bson.M{"deleted_at": time.Now()}})
+	rec, _ = request(t, e, http.MethodPost, target, nil)
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("restore of a deleted media = %d, want 409", rec.Code)
+	}
+	if references(first.ID) != 0 || references(second.ID) != 1 {
+		t.Fatalf("references after the conflict = %d %d, want 0 1", references(first.ID), references(second.ID))
+	}
+
+	mediaRepo.Update(bson.M{"_id": first.ID}, bson.M{"$unset": bson.M{"deleted_at": ""}})
+	rec, res = request(t, e, http.MethodPost, target, nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("restore = %d %s", rec.Code, res.Message)
+	}
+	if references(first.ID) != 1 || references(second.ID) != 0 {
+		t.Fatalf("references after the restore = %d %d, want 1 0", references(first.ID), references(second.ID))
+	}
+
+	var current picture
+	repo.FindByID(record.ID, &current)
+	if current.Media != first.ID || current.Version != 3 {
+		t.Fatalf("current = %+v", current)
+	}
+}
@@ -0,0 +1,721 @@
+package crud
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/patch"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/slug"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Get responds with the list of the documents
+func (r *Resource) Get(c echo.Context) error {
+	return r.list(c, false, nil)
+}
+
+// GetPublic responds with the list of the documents on the public site
+func (r *Resource) GetPublic(c echo.Context) error {
+	return r.list(c, true, nil)
+}
+
+// GetPublicWhere responds with the list of the documents on the public
+// site matching the filter
+func (r *Resource) GetPublicWhere(c echo.Context, filter bson.M) error {
+	return r.list(c, true, filter)
+}
+
+func (r *Resource) list(c echo.Context, public bool, filter bson.M) error {
+	query, err := utils.NewListQuery(c, r.ListFields, r.Model)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	query.Where(trash.Active(bson.M{}))
+	if public {
+		query.Where(r.publicScope())
+	}
+	if filter != nil {
+		query.Where(filter)
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := r.model.NewSlice()
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), result.Interface()); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if public {
+		i18n.Localize(c, result.Interface(), r.Translatable...)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result.Elem().Interface(), query.Paginate(total, result.Interface())))
+}
+
+// Find responds with the document of the ID
+func (r *Resource) Find(c echo.Context) error {
+	return r.find(c, false)
+}
+
+// FindPublic responds with the document of the ID on the public site
+func (r *Resource) FindPublic(c echo.Context) error {
+	return r.find(c, true)
+}
+
+func (r *Resource) find(c echo.Context, public bool) error {
+	param := c.Param("id")
+	bySlug := public && r.Slug != ""
+
+	id, err := primitive.ObjectIDFromHex(param)
+
+	if err != nil && !bySlug {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+	if public {
+		if bySlug {
+			selector = trash.Active(slug.Selector(param))
+		}
+		selector = bson.M{"$and": bson.A{selector, r.publicScope()}}
+	}
+
+	record := r.model.New()
+
+	if err = repo.FindOne(selector, record.Interface()); err != nil {
+		/* a slug the document had before redirects to the current one */
+		if bySlug {
+			if moved := slug.Moved(c, r.Collection, param, r.publicScope()); moved != repository.ErrNotFound {
+				return moved
+			}
+		}
+
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	version.SetETag(c, r.model.Version(record))
+
+	if public {
+		i18n.Localize(c, record.Interface(), r.Translatable...)
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record.Interface(), ""))
+}
+
+// Create stores a new document from the form or the JSON object
+func (r *Resource) Create(c echo.Context) error {
+	value, err := Values(c)
+	if err != nil {
+		return err
+	}
+
+	record := r.model.New()
+	r.model.Bind(record, value)
+
+	if r.Prepare != nil {
+		if err := r.Prepare(c, record.Interface(), nil); err != nil {
+			return err
+		}
+	}
+
+	// validate input before anything is uploaded
+	if err := utils.Validate(c, record.Interface(), r.ImageField); err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	id := primitive.NewObjectID()
+
+	if r.UploadDir != "" {
+		/* upload image first, or pick it from the media library */
+		image, err := media.ReadImage(c, r.ImageField, r.UploadDir)
+		if err != nil {
+			return err
+		}
+		if image == nil {
+			return utils.NewValidationError(utils.FieldError{
+				Field:   r.ImageField,
+				Code:    "required",
+				Message: fmt.Sprintf("%s is required", r.ImageField),
+			})
+		}
+
+		if err = media.Use(image, media.Reference{Collection: r.Collection, ID: id}); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		/* end upload image */
+
+		r.model.SetImage(record, r.ImageField, image)
+	}
+
+	if err = r.slug(c, id, record, nil, value(slug.Field)); err != nil {
+		return err
+	}
+
+	/* store record to db */
+	r.model.Set(record, "_id", id)
+	r.model.Timestamps(record)
+
+	err = repo.Insert(record.Interface())
+
+	if err != nil {
+		if used := slug.Conflict(err, r.model.Get(record, slug.Field)); used != nil {
+			return used
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionCreate, r.Collection, id, nil)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(record.Interface(), "Saved"))
+}
+
+// Update changes the document of the ID with the form, empty values
+// keep the current ones and the result must still be valid. The version
+// sent in If-Match or in the form must be the current one.
+func (r *Resource) Update(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	current := r.model.New()
+
+	if err = repo.FindOne(selector, current.Interface()); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if r.Authorize != nil {
+		if err = r.Authorize(c, current.Interface(), audits.ActionUpdate); err != nil {
+			return err
+		}
+	}
+
+	value, err := Values(c)
+	if err != nil {
+		return err
+	}
+
+	/* the change must be based on the current version, when it says which */
+	body, _ := strconv.ParseInt(value(version.Field), 10, 64)
+	submitted, err := version.Submitted(c, body)
+	if err != nil {
+		return err
+	}
+
+	latest := r.model.Version(current)
+	if err = version.Check(submitted, latest, current.Interface()); err != nil {
+		return err
+	}
+
+	/* the changes are validated along with the current values they keep */
+	changes := r.model.Copy(current)
+	r.model.Bind(changes, value)
+
+	if r.Prepare != nil {
+		if err = r.Prepare(c, changes.Interface(), current.Interface()); err != nil {
+			return err
+		}
+	}
+
+	if err = utils.Validate(c, changes.Interface(), r.ImageField); err != nil {
+		return err
+	}
+
+	if err = r.slug(c, id, changes, &current, value(slug.Field)); err != nil {
+		return err
+	}
+
+	r.model.Set(changes, "_id", primitive.NilObjectID)
+	r.model.Set(changes, "updated_at", time.Now())
+	r.model.Set(changes, version.Field, latest+1)
+	update := bson.M{"$set": changes.Interface()}
+	if cleared := r.model.Cleared(current, changes); len(cleared) > 0 {
+		update["$unset"] = cleared
+	}
+
+	var image *media.Image
+	ref := media.Reference{Collection: r.Collection, ID: id}
+	previous := r.model.Image(current, r.ImageField)
+
+	if r.UploadDir != "" {
+		/* check image exist first, or pick it from the media library */
+		image, err = media.ReadImage(c, r.ImageField, r.UploadDir)
+		if err != nil {
+			return err
+		}
+		// no image in the request keeps the current one
+		if image != nil {
+			/* use the new image, the current one is detached once the change is saved */
+			if err = media.Use(image, ref); err != nil {
+				r.release(c, image, previous, ref)
+				return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+			}
+
+			r.model.SetImage(changes, r.ImageField, image)
+			unset, _ := update["$unset"].(bson.M)
+			if unset == nil {
+				unset = bson.M{}
+			}
+			for name := range media.Unset(image, r.ImageField) {
+				unset[name] = ""
+			}
+			update["$unset"] = unset
+		}
+	}
+
+	before := audits.Snapshot(r.Collection, id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		r.release(c, image, previous, ref)
+		if used := slug.Conflict(err, r.model.Get(changes, slug.Field)); used != nil {
+			return used
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		r.release(c, image, previous, ref)
+		return version.Reload(repo, id, r.model.New().Interface())
+	}
+
+	/* the replaced image is detached, its files stay with the revision */
+	if image != nil && !sameMedia(image, previous) {
+		if err = media.Detach(previous, ref); err != nil {
+			c.Logger().Error(err)
+		}
+	}
+
+	audits.Record(c, audits.ActionUpdate, r.Collection, id, before)
+	revisions.Save(c, r.Collection, id, before)
+
+	version.SetETag(c, latest+1)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Updated"))
+}
+
+// Patch changes the fields of the document of the ID given by a JSON
+// merge patch, the other fields are left as they are. The version sent
+// in If-Match or in the patch must be the current one.
+func (r *Resource) Patch(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	doc, body, err := patch.Read(c)
+	if err != nil {
+		return err
+	}
+	c.Set(valuesKey, map[string]interface{}(doc))
+
+	submitted, err := version.Submitted(c, body)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	current := r.model.New()
+
+	if err = repo.FindOne(selector, current.Interface()); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	if r.Authorize != nil {
+		if err = r.Authorize(c, current.Interface(), audits.ActionUpdate); err != nil {
+			return err
+		}
+	}
+
+	latest := r.model.Version(current)
+	if err = version.Check(submitted, latest, current.Interface()); err != nil {
+		return err
+	}
+
+	changes := r.model.Copy(current)
+	update, err := doc.Apply(changes.Interface(), r.model.patchable...)
+	if err != nil {
+		return err
+	}
+
+	if r.Prepare != nil {
+		patched := r.model.Copy(changes)
+		if err = r.Prepare(c, changes.Interface(), current.Interface()); err != nil {
+			return err
+		}
+
+		// the fields prepare fills are written along with the patch
+		for name, value := range r.model.Diff(patched, changes) {
+			update["$set"].(bson.M)[name] = value
+		}
+	}
+
+	if err = utils.Validate(c, changes.Interface(), r.ImageField); err != nil {
+		return err
+	}
+
+	if r.Slug != "" {
+		requested, _ := doc[slug.Field].(string)
+		if err = r.slug(c, id, changes, &current, requested); err != nil {
+			return err
+		}
+
+		set := update["$set"].(bson.M)
+		set[slug.Field] = r.model.Get(changes, slug.Field)
+		set[slug.PreviousField] = r.model.Get(changes, slug.PreviousField)
+		if unset, ok := update["$unset"].(bson.M); ok {
+			delete(unset, slug.Field)
+		}
+	}
+
+	before := audits.Snapshot(r.Collection, id)
+	result, err := repo.Update(version.Selector(selector, latest), update)
+	if err != nil {
+		if used := slug.Conflict(err, r.model.Get(changes, slug.Field)); used != nil {
+			return used
+		}
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, r.model.New().Interface())
+	}
+
+	audits.Record(c, audits.ActionUpdate, r.Collection, id, before)
+	revisions.Save(c, r.Collection, id, before)
+
+	version.SetETag(c, r.model.Version(changes))
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(changes.Interface(), "Updated"))
+}
+
+// Destroy moves the document of the ID to the trash
+func (r *Resource) Destroy(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	if r.Authorize != nil {
+		record := r.model.New()
+
+		if err = repo.FindOne(selector, record.Interface()); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err)
+		}
+
+		if err = r.Authorize(c, record.Interface(), audits.ActionDelete); err != nil {
+			return err
+		}
+	}
+
+	before := audits.Snapshot(r.Collection, id)
+
+	/* move record to the trash, it is purged after the retention period */
+	result, err := repo.Update(selector, trash.Mark())
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	audits.Record(c, audits.ActionDelete, r.Collection, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Deleted"))
+}
+
+// Trash responds with the documents in the trash
+func (r *Resource) Trash(c echo.Context) error {
+	return trash.List(c, r.Collection, r.model.NewSlice().Interface())
+}
+
+// Restore takes the document of the ID out of the trash
+func (r *Resource) Restore(c echo.Context) error {
+	if r.Authorize != nil {
+		id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+		}
+
+		repo, err := repository.Open(r.Collection)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+		}
+
+		record := r.model.New()
+
+		if err = repo.FindOne(trash.Deleted(bson.M{"_id": id}), record.Interface()); err != nil {
+			return echo.NewHTTPError(http.StatusNotFound, "Record is not in the trash")
+		}
+
+		if err = r.Authorize(c, record.Interface(), audits.ActionRestore); err != nil {
+			return err
+		}
+	}
+
+	return trash.Restore(c, r.Collection)
+}
+
+// Translations responds with the translations of the document of the ID
+func (r *Resource) Translations(c echo.Context) error {
+	return i18n.List(c, r.Collection)
+}
+
+// Translate replaces the translations of the document of the ID in the
+// locale of the request, see i18n.Update
+func (r *Resource) Translate(c echo.Context) error {
+	if err := r.authorize(c, audits.ActionUpdate); err != nil {
+		return err
+	}
+
+	return i18n.Update(c, r.Collection, r.Translatable)
+}
+
+// Untranslate drops the translations of the document of the ID in the
+// locale of the request
+func (r *Resource) Untranslate(c echo.Context) error {
+	if err := r.authorize(c, audits.ActionUpdate); err != nil {
+		return err
+	}
+
+	return i18n.Remove(c, r.Collection)
+}
+
+// authorize checks the action on the active document of the ID with the
+// Authorize option, when there is one
+func (r *Resource) authorize(c echo.Context, action string) error {
+	if r.Authorize == nil {
+		return nil
+	}
+
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(r.Collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	record := r.model.New()
+
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), record.Interface()); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	return r.Authorize(c, record.Interface(), action)
+}
+
+// purge releases the image and the revisions of a document deleted for good
+func (r *Resource) purge(id primitive.ObjectID) error {
+	if r.UploadDir != "" {
+		repo, err := repository.Open(r.Collection)
+		if err != nil {
+			return err
+		}
+
+		record := r.model.New()
+
+		if err = repo.FindByID(id, record.Interface()); err != nil {
+			return err
+		}
+
+		ref := media.Reference{Collection: r.Collection, ID: id}
+		if err = media.Release(r.model.Image(record, r.ImageField), r.UploadDir, ref); err != nil {
+			return err
+		}
+	}
+
+	return revisions.Purge(r.Collection, id)
+}
+
+// release drops the new image of a change that was not saved, the
+// uploaded files are removed and the media item forgets the document
+// unless it is the current image
+func (r *Resource) release(c echo.Context, image *media.Image, current *media.Image, ref media.Reference) {
+	if image == nil || sameMedia(image, current) {
+		return
+	}
+
+	if err := media.Release(image, r.UploadDir, ref); err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// sameMedia reports whether both images are the same media item
+func sameMedia(image *media.Image, current *media.Image) bool {
+	return !image.Media.IsZero() && image.Media == current.Media
+}
+
+// slug sets the slug of the document from the requested one or from its
+// text, current is nil when the document is created, see slug.Next
+func (r *Resource) slug(c echo.Context, id primitive.ObjectID, record reflect.Value, current *reflect.Value, requested string) error {
+	if r.Slug == "" {
+		return nil
+	}
+
+	var previous []string
+	var currentSlug string
+	if current != nil {
+		currentSlug, _ = r.model.Get(*current, slug.Field).(string)
+		previous, _ = r.model.Get(*current, slug.PreviousField).([]string)
+	}
+
+	source, _ := r.model.Get(record, r.Slug).(string)
+	next, previous, err := slug.Next(r.Collection, id, requested, source, currentSlug, previous)
+	if err != nil {
+		return err
+	}
+
+	r.model.Set(record, slug.Field, next)
+	r.model.Set(record, slug.PreviousField, previous)
+
+	return nil
+}
+
+// publicScope narrows the documents to the ones served on the public site
+func (r *Resource) publicScope() bson.M {
+	if r.PublicScope == nil {
+		return bson.M{}
+	}
+
+	return r.PublicScope()
+}
+
+// valuesKey keeps the JSON object of the request in the context, the
+// body can only be read once
+const valuesKey = "crud.values"
+
+// Values returns the values of the form, or of the JSON object or the
+// patch sent instead of a form. Prepare reads the fields the form does
+// not bind with it.
+func Values(c echo.Context) (func(name string) string, error) {
+	body, err := object(c)
+	if err != nil || body == nil {
+		return c.FormValue, err
+	}
+
+	return func(name string) string {
+		switch v := body[name].(type) {
+		case string:
+			return v
+		case float64:
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			return strconv.FormatBool(v)
+		}
+
+		return ""
+	}, nil
+}
+
+// List returns the values of a list field, repeated or comma separated in
+// the form or an array in the JSON object, ok is false when the request
+// does not have the field
+func List(c echo.Context, name string) (list []string, ok bool, err error) {
+	body, err := object(c)
+	if err != nil {
+		return nil, false, err
+	}
+	if body == nil {
+		list, ok = utils.FormList(c, name)
+		return list, ok, nil
+	}
+
+	value, ok := body[name]
+	if !ok {
+		return nil, false, nil
+	}
+
+	list = make([]string, 0)
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			list = append(list, fmt.Sprint(item))
+		}
+	case string:
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+	case nil:
+	default:
+		list = append(list, fmt.Sprint(v))
+	}
+
+	return list, true, nil
+}
+
+// object returns the JSON object of the request, nil when a form is sent
+func object(c echo.Context) (map[string]interface{}, error) {
+	if body, ok := c.Get(valuesKey).(map[string]interface{}); ok {
+		return body, nil
+	}
+
+	if !strings.HasPrefix(c.Request().Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return nil, nil
+	}
+
+	body := map[string]interface{}{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil {
+		return nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+	c.Set(valuesKey, body)
+
+	return body, nil
+}
@@ -0,0 +1,199 @@
+package crud
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/images"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// model knows the fields of a document struct by their bson name
+type model struct {
+	typ reflect.Type
+
+	// index of the fields by bson name
+	fields map[string]int
+
+	// bson name of the string fields bound to the form, by form name
+	form map[string]string
+
+	// json name of the fields a merge patch may change
+	patchable []string
+
+	// bson name of the fields left out of the document when they are empty
+	omitempty map[string]bool
+}
+
+func newModel(value interface{}, imageField string) *model {
+	typ := reflect.TypeOf(value)
+	if typ != nil && typ.Kind() == reflect.Ptr {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		panic("crud: model must be a struct")
+	}
+
+	m := &model{typ: typ, fields: map[string]int{}, form: map[string]string{}, omitempty: map[string]bool{}}
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		name := strings.Split(field.Tag.Get("bson"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		m.fields[name] = i
+		if name != "_id" && strings.Contains(field.Tag.Get("bson"), ",omitempty") {
+			m.omitempty[name] = true
+		}
+
+		if name == imageField || name == "media" || name == version.Field {
+			continue
+		}
+
+		if key := strings.Split(field.Tag.Get("json"), ",")[0]; key != "" && key != "-" && field.Tag.Get("form") != "" {
+			m.patchable = append(m.patchable, key)
+		}
+
+		if form := field.Tag.Get("form"); form != "" && field.Type.Kind() == reflect.String {
+			m.form[form] = name
+		}
+	}
+
+	return m
+}
+
+// New returns a pointer to an empty document
+func (m *model) New() reflect.Value {
+	return reflect.New(m.typ)
+}
+
+// NewSlice returns a pointer to an empty slice of documents, it is
+// encoded as an empty list rather than null
+func (m *model) NewSlice() reflect.Value {
+	slice := reflect.New(reflect.SliceOf(m.typ))
+	slice.Elem().Set(reflect.MakeSlice(reflect.SliceOf(m.typ), 0, 0))
+
+	return slice
+}
+
+// Bind sets the string fields of the document from the form values,
+// empty values keep the current ones
+func (m *model) Bind(record reflect.Value, value func(name string) string) {
+	for form, name := range m.form {
+		if v := value(form); v != "" {
+			record.Elem().Field(m.fields[name]).SetString(v)
+		}
+	}
+}
+
+// Copy returns a pointer to a copy of the document
+func (m *model) Copy(record reflect.Value) reflect.Value {
+	copied := m.New()
+	copied.Elem().Set(record.Elem())
+
+	return copied
+}
+
+// Set sets the field of the document with the bson name, fields the
+// model does not have are ignored
+func (m *model) Set(record reflect.Value, name string, value interface{}) {
+	i, ok := m.fields[name]
+	if !ok {
+		return
+	}
+
+	field := record.Elem().Field(i)
+	if v := reflect.ValueOf(value); v.IsValid() && v.Type().AssignableTo(field.Type()) {
+		field.Set(v)
+	}
+}
+
+// Get returns the field of the document with the bson name, nil when
+// the model does not have it
+func (m *model) Get(record reflect.Value, name string) interface{} {
+	i, ok := m.fields[name]
+	if !ok {
+		return nil
+	}
+
+	return record.Elem().Field(i).Interface()
+}
+
+// Diff returns the fields of the document that differ between the two
+// records, by bson name
+func (m *model) Diff(from reflect.Value, to reflect.Value) map[string]interface{} {
+	changed := map[string]interface{}{}
+
+	for name, i := range m.fields {
+		value := to.Elem().Field(i).Interface()
+		if !reflect.DeepEqual(from.Elem().Field(i).Interface(), value) {
+			changed[name] = value
+		}
+	}
+
+	return changed
+}
+
+// Cleared returns the fields the second record empties, by bson name,
+// they are left out of the document and must be unset
+func (m *model) Cleared(from reflect.Value, to reflect.Value) bson.M {
+	cleared := bson.M{}
+
+	for name := range m.omitempty {
+		i := m.fields[name]
+		if to.Elem().Field(i).IsZero() && !from.Elem().Field(i).IsZero() {
+			cleared[name] = ""
+		}
+	}
+
+	return cleared
+}
+
+// Version returns the version of the document
+func (m *model) Version(record reflect.Value) int64 {
+	if i, ok := m.fields[version.Field]; ok {
+		return record.Elem().Field(i).Int()
+	}
+
+	return 0
+}
+
+// Image returns the image of the document held in the field with the bson name
+func (m *model) Image(record reflect.Value, name string) *media.Image {
+	image := &media.Image{}
+
+	if i, ok := m.fields[name]; ok {
+		image.Filename = record.Elem().Field(i).String()
+	}
+	if i, ok := m.fields["variants"]; ok {
+		image.Variants, _ = record.Elem().Field(i).Interface().(*images.Variants)
+	}
+	if i, ok := m.fields["media"]; ok {
+		image.Media, _ = record.Elem().Field(i).Interface().(primitive.ObjectID)
+	}
+
+	return image
+}
+
+// SetImage sets the image of the document to the field with the bson name
+func (m *model) SetImage(record reflect.Value, name string, image *media.Image) {
+	m.Set(record, name, image.Filename)
+	m.Set(record, "variants", image.Variants)
+	m.Set(record, "media", image.Media)
+}
+
+// Timestamps sets the time the document is created and updated, with
+// its first version
+func (m *model) Timestamps(record reflect.Value) {
+	now := time.Now()
+
+	m.Set(record, "created_at", now)
+	m.Set(record, "updated_at", now)
+	m.Set(record, version.Field, int64(1))
+}
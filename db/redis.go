@@ -2,19 +2,26 @@ package db
 
 import (
 	"os"
+	"sync"
 
 	"github.com/go-redis/redis/v8"
 )
 
+/* Used to create a singleton object of Redis client. */
 var client *redis.Client
 
+// Used to execute client creation procedure only once.
+var redisOnce sync.Once
+
 func InitRedis() *redis.Client {
 	//Initializing redis
-	opt, err := redis.ParseURL(os.Getenv("REDIS_URL"))
-	if err != nil {
-		panic(err)
-	}
-	rdb := redis.NewClient(opt)
+	redisOnce.Do(func() {
+		opt, err := redis.ParseURL(os.Getenv("REDIS_URL"))
+		if err != nil {
+			panic(err)
+		}
+		client = redis.NewClient(opt)
+	})
 
-	return rdb
+	return client
 }
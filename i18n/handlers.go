@@ -0,0 +1,195 @@
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Settings are the locales served, for the clients to offer them
+type Settings struct {
+	Locales  []string `json:"locales"`
+	Default  string   `json:"default"`
+	Fallback []string `json:"fallback"`
+}
+
+// GetLocales godoc
+// @Summary Get locales
+// @Description Get the locales served, the default one and the fallback chain. Public routes pick the locale from ?lang= or Accept-Language.
+// @ID get-locales
+// @Tags Locales
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} utils.HttpSuccess{data=Settings}
+// @Router /public/locales [get]
+func GetLocales(c echo.Context) error {
+	return c.JSON(http.StatusOK, utils.NewSuccess(&Settings{
+		Locales:  Locales(),
+		Default:  Default(),
+		Fallback: Fallback(),
+	}, ""))
+}
+
+// List responds with the translations of the document of the ID
+func List(c echo.Context, collection string) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var current bson.M
+
+	if err = repo.FindOne(trash.Active(bson.M{"_id": id}), &current); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	version.SetETag(c, version.Of(current))
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(Read(current[Field]), ""))
+}
+
+// Update replaces the translations of the document of the ID in the
+// locale of the request with the JSON object of the body, by json name
+// of the field. Only the given fields may be translated and empty texts
+// are left out. The version sent in If-Match or in the body must be the
+// current one.
+func Update(c echo.Context, collection string, fields []string) error {
+	var body map[string]interface{}
+	if err := json.NewDecoder(c.Request().Body).Decode(&body); err != nil || body == nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format, translations must be an object")
+	}
+
+	based, _ := body[version.Field].(float64)
+	delete(body, version.Field)
+
+	names := make([]string, 0, len(body))
+	for name := range body {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	texts := map[string]string{}
+	invalid := make([]utils.FieldError, 0)
+	for _, name := range names {
+		if !contains(fields, name) {
+			invalid = append(invalid, utils.FieldError{Field: name, Code: "readonly", Message: fmt.Sprintf("%s can not be translated", name)})
+			continue
+		}
+
+		switch value := body[name].(type) {
+		case nil:
+		case string:
+			if value != "" {
+				texts[name] = value
+			}
+		default:
+			invalid = append(invalid, utils.FieldError{Field: name, Code: "type", Message: fmt.Sprintf("%s must be a string", name)})
+		}
+	}
+	if len(invalid) > 0 {
+		return utils.NewValidationError(invalid...)
+	}
+
+	return change(c, collection, int64(based), texts)
+}
+
+// Remove drops the translations of the document of the ID in the locale
+// of the request, the version sent in If-Match must be the current one
+func Remove(c echo.Context, collection string) error {
+	return change(c, collection, 0, nil)
+}
+
+// change sets the texts of the locale of the request, no text removes them
+func change(c echo.Context, collection string, body int64, texts map[string]string) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	locale := normalize(c.Param("locale"))
+	if !Supported(locale) {
+		return echo.NewHTTPError(http.StatusNotFound, "Locale not found")
+	}
+	if locale == Default() {
+		return echo.NewHTTPError(http.StatusBadRequest, "Texts of the default locale are the fields of the record")
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, body)
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := trash.Active(bson.M{"_id": id})
+
+	var current bson.M
+
+	if err = repo.FindOne(selector, &current); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	latest := version.Of(current)
+	if err = version.Check(submitted, latest, current); err != nil {
+		return err
+	}
+
+	translations := Read(current[Field])
+	update := bson.M{"$set": bson.M{"updated_at": time.Now()}}
+	if len(texts) == 0 {
+		delete(translations, locale)
+		update["$unset"] = bson.M{Field + "." + locale: ""}
+	} else {
+		translations[locale] = texts
+		update["$set"].(bson.M)[Field+"."+locale] = texts
+	}
+
+	before := audits.Snapshot(collection, id)
+	result, err := repo.Update(version.Selector(selector, latest), version.Bump(update))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+	if result.MatchedCount == 0 {
+		return version.Reload(repo, id, &bson.M{})
+	}
+
+	audits.Record(c, audits.ActionUpdate, collection, id, before)
+	revisions.Save(c, collection, id, before)
+
+	version.SetETag(c, latest+1)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(translations, "Updated"))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
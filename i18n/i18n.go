@@ -0,0 +1,327 @@
+package i18n
+
+import (
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Field holds the translations of a document
+const Field = "translations"
+
+// locales served when LOCALES is not set, the first one is the default
+const defaultLocales = "en,id"
+
+const (
+	headerAcceptLanguage  = "Accept-Language"
+	headerContentLanguage = "Content-Language"
+	headerVary            = "Vary"
+)
+
+// Translations holds the translated text fields of a document by locale
+// then by json name of the field, the fields themselves hold the text in
+// the default locale
+type Translations map[string]map[string]string
+
+// Locales returns the locales served, configured by LOCALES as a comma
+// separated list, the first one is the default locale
+func Locales() []string {
+	locales := split(os.Getenv("LOCALES"))
+	if len(locales) == 0 {
+		locales = split(defaultLocales)
+	}
+
+	return locales
+}
+
+// Default returns the locale of the text held in the fields
+func Default() string {
+	return Locales()[0]
+}
+
+// Supported tells whether the locale is served
+func Supported(locale string) bool {
+	for _, l := range Locales() {
+		if l == locale {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fallback returns the locales tried, in order, when a text is missing
+// in the requested ones, configured by LOCALE_FALLBACK. It always ends
+// with the default locale since every document has its text.
+func Fallback() []string {
+	chain := make([]string, 0)
+	for _, locale := range split(os.Getenv("LOCALE_FALLBACK")) {
+		if Supported(locale) {
+			chain = append(chain, locale)
+		}
+	}
+
+	return append(chain, Default())
+}
+
+// Negotiate returns the locales to try for the request, the one of
+// ?lang= first, then the ones of Accept-Language by preference and
+// the fallback chain last
+func Negotiate(c echo.Context) []string {
+	requested := make([]string, 0)
+	if lang := c.QueryParam("lang"); lang != "" {
+		requested = append(requested, lang)
+	}
+	requested = append(requested, accepted(c.Request().Header.Get(headerAcceptLanguage))...)
+
+	chain := make([]string, 0)
+	for _, tag := range requested {
+		if locale := match(tag); locale != "" {
+			chain = append(chain, locale)
+		}
+	}
+
+	return unique(append(chain, Fallback()...))
+}
+
+// Localize replaces the translatable fields of the records with their
+// text in the locale negotiated for the request, and drops the other
+// translations. Records is a pointer to a model or to a slice of models,
+// a bson.M or a slice of them, fields are the json names.
+func Localize(c echo.Context, records interface{}, fields ...string) {
+	chain := Negotiate(c)
+
+	header := c.Response().Header()
+	header.Set(headerContentLanguage, chain[0])
+	header.Add(headerVary, headerAcceptLanguage)
+
+	switch value := records.(type) {
+	case bson.M:
+		localizeDocument(value, chain, fields)
+		return
+	case []bson.M:
+		for _, document := range value {
+			localizeDocument(document, chain, fields)
+		}
+		return
+	case *[]bson.M:
+		for _, document := range *value {
+			localizeDocument(document, chain, fields)
+		}
+		return
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(records))
+	switch value.Kind() {
+	case reflect.Struct:
+		localizeStruct(value, chain, fields)
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			localizeStruct(reflect.Indirect(value.Index(i)), chain, fields)
+		}
+	}
+}
+
+// text returns the text of the field in the first locale of the chain
+// that has one, ok is false when it is the one of the default locale
+func text(translations Translations, chain []string, field string) (string, bool) {
+	def := Default()
+
+	for _, locale := range chain {
+		if locale == def {
+			return "", false
+		}
+		if value := translations[locale][field]; value != "" {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+func localizeStruct(value reflect.Value, chain []string, fields []string) {
+	if value.Kind() != reflect.Struct || !value.CanSet() {
+		return
+	}
+
+	var translations Translations
+	names := map[string]int{}
+
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+
+		if strings.Split(field.Tag.Get("bson"), ",")[0] == Field {
+			translations, _ = value.Field(i).Interface().(Translations)
+			value.Field(i).Set(reflect.Zero(field.Type))
+			continue
+		}
+
+		if field.Type.Kind() == reflect.String {
+			names[strings.Split(field.Tag.Get("json"), ",")[0]] = i
+		}
+	}
+
+	for _, name := range fields {
+		i, ok := names[name]
+		if !ok {
+			continue
+		}
+
+		if translated, ok := text(translations, chain, name); ok {
+			value.Field(i).SetString(translated)
+		}
+	}
+}
+
+func localizeDocument(document bson.M, chain []string, fields []string) {
+	translations := Read(document[Field])
+	delete(document, Field)
+
+	for _, name := range fields {
+		if translated, ok := text(translations, chain, name); ok {
+			document[name] = translated
+		}
+	}
+}
+
+// Read returns the translations of a document decoded into a bson.M
+func Read(value interface{}) Translations {
+	translations := Translations{}
+
+	if t, ok := value.(Translations); ok {
+		return t
+	}
+
+	locales, ok := object(value)
+	if !ok {
+		return translations
+	}
+
+	for locale, raw := range locales {
+		texts, ok := object(raw)
+		if !ok {
+			continue
+		}
+
+		translations[locale] = map[string]string{}
+		for name, text := range texts {
+			if s, ok := text.(string); ok {
+				translations[locale][name] = s
+			}
+		}
+	}
+
+	return translations
+}
+
+// object returns the fields of an embedded document
+func object(value interface{}) (map[string]interface{}, bool) {
+	switch v := value.(type) {
+	case bson.M:
+		return v, true
+	case map[string]interface{}:
+		return v, true
+	case map[string]string:
+		fields := map[string]interface{}{}
+		for key, text := range v {
+			fields[key] = text
+		}
+		return fields, true
+	case bson.D:
+		return v.Map(), true
+	}
+
+	return nil, false
+}
+
+// accepted returns the language tags of an Accept-Language header by
+// preference, the ones with q=0 are left out
+func accepted(header string) []string {
+	type tag struct {
+		name    string
+		quality float64
+	}
+
+	tags := make([]tag, 0)
+	for _, part := range strings.Split(header, ",") {
+		params := strings.Split(strings.TrimSpace(part), ";")
+		name := strings.TrimSpace(params[0])
+		if name == "" || name == "*" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(strings.TrimPrefix(param, "q="), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+
+		if quality > 0 {
+			tags = append(tags, tag{name: name, quality: quality})
+		}
+	}
+
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].quality > tags[j].quality
+	})
+
+	names := make([]string, 0, len(tags))
+	for _, t := range tags {
+		names = append(names, t.name)
+	}
+
+	return names
+}
+
+// match returns the locale served for a language tag, e.g. "id" for
+// "id-ID" when only "id" is served, or empty when there is none
+func match(tag string) string {
+	tag = normalize(tag)
+	if Supported(tag) {
+		return tag
+	}
+
+	if base := strings.SplitN(tag, "-", 2)[0]; Supported(base) {
+		return base
+	}
+
+	return ""
+}
+
+func normalize(tag string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+}
+
+func split(value string) []string {
+	values := make([]string, 0)
+	for _, v := range strings.Split(value, ",") {
+		if v = normalize(v); v != "" {
+			values = append(values, v)
+		}
+	}
+
+	return unique(values)
+}
+
+func unique(values []string) []string {
+	seen := map[string]bool{}
+	result := make([]string, 0, len(values))
+
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			result = append(result, v)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,280 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register the gif decoder
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/chai2010/webp"
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/storage"
+	"github.com/rs/xid"
+	"golang.org/x/image/draw"
+)
+
+const (
+	defaultMaxSize      = 5 << 20 // 5 MB
+	defaultMaxDimension = 6000    // pixels, applies to width and height
+	jpegQuality         = 85
+	webpQuality         = 80
+)
+
+// extensions of the accepted content types, detected from the file content
+var extensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// widths of the generated variants, images are never upscaled
+var widths = []struct {
+	name  string
+	width int
+}{
+	{"thumbnail", 320},
+	{"medium", 768},
+	{"large", 1280},
+}
+
+// Variant is a resized copy of an uploaded image
+type Variant struct {
+	Name   string `json:"name" bson:"name"`
+	URL    string `json:"url" bson:"url"`
+	Width  int    `json:"width" bson:"width"`
+	Height int    `json:"height" bson:"height"`
+}
+
+// Variants are generated on upload and stored next to the original image
+type Variants struct {
+	Thumbnail *Variant `json:"thumbnail,omitempty" bson:"thumbnail,omitempty"`
+	Medium    *Variant `json:"medium,omitempty" bson:"medium,omitempty"`
+	Large     *Variant `json:"large,omitempty" bson:"large,omitempty"`
+	Webp      *Variant `json:"webp,omitempty" bson:"webp,omitempty"`
+	// Srcset lists the resized variants by width, ready for <img srcset>
+	Srcset string `json:"srcset" bson:"srcset"`
+}
+
+func (v *Variants) all() []*Variant {
+	return []*Variant{v.Thumbnail, v.Medium, v.Large, v.Webp}
+}
+
+// MaxSize returns the upload limit in bytes, configured by IMAGE_MAX_SIZE_MB
+func MaxSize() int64 {
+	if value, err := strconv.ParseInt(os.Getenv("IMAGE_MAX_SIZE_MB"), 10, 64); err == nil && value > 0 {
+		return value << 20
+	}
+
+	return defaultMaxSize
+}
+
+// MaxDimension returns the largest accepted width or height, configured by IMAGE_MAX_DIMENSION
+func MaxDimension() int {
+	if value, err := strconv.Atoi(os.Getenv("IMAGE_MAX_DIMENSION")); err == nil && value > 0 {
+		return value
+	}
+
+	return defaultMaxDimension
+}
+
+// Upload validates the uploaded image, stores it in dir under a generated
+// name together with its resized variants and returns the name of the original
+func Upload(file *multipart.FileHeader, dir string) (string, *Variants, error) {
+	if file.Size > MaxSize() {
+		return "", nil, tooLarge()
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", nil, err
+	}
+	defer src.Close()
+
+	// read one byte more than allowed to detect a lying header
+	data, err := ioutil.ReadAll(io.LimitReader(src, MaxSize()+1))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if int64(len(data)) > MaxSize() {
+		return "", nil, tooLarge()
+	}
+
+	contentType := http.DetectContentType(data)
+	extension, ok := extensions[contentType]
+	if !ok {
+		return "", nil, echo.NewHTTPError(http.StatusUnsupportedMediaType, "Only jpeg, png, gif and webp images are allowed")
+	}
+
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid image: "+err.Error())
+	}
+
+	if config.Width > MaxDimension() || config.Height > MaxDimension() {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest,
+			fmt.Sprintf("Image must not be larger than %dx%d pixels", MaxDimension(), MaxDimension()))
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Invalid image: "+err.Error())
+	}
+
+	store, err := storage.Default()
+	if err != nil {
+		return "", nil, err
+	}
+
+	id := xid.New().String()
+	filename := id + extension
+	if err = store.Put(storage.Key(dir, filename), bytes.NewReader(data), contentType); err != nil {
+		return "", nil, err
+	}
+
+	variants, err := resize(store, dir, id, contentType, img)
+	if err != nil {
+		// best effort, the upload failed anyway
+		Remove(dir, filename, variants)
+		return "", nil, err
+	}
+
+	return filename, variants, nil
+}
+
+// Remove deletes the original image and its variants from dir
+func Remove(dir string, name string, variants *Variants) error {
+	if err := storage.Remove(dir, name); err != nil {
+		return err
+	}
+
+	if variants == nil {
+		return nil
+	}
+
+	for _, variant := range variants.all() {
+		if variant == nil {
+			continue
+		}
+
+		if err := storage.Remove(dir, variant.Name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func resize(store storage.Storage, dir string, id string, contentType string, img image.Image) (*Variants, error) {
+	variants := &Variants{}
+	srcset := make([]string, 0, len(widths))
+	bounds := img.Bounds()
+
+	// keep transparency of png, gif and webp, everything else becomes jpeg
+	encode, extension, variantType := encodeJPEG, ".jpg", "image/jpeg"
+	if contentType != "image/jpeg" {
+		encode, extension, variantType = encodePNG, ".png", "image/png"
+	}
+
+	var large image.Image
+	for _, size := range widths {
+		scaled := scale(img, size.width)
+		variant, err := put(store, dir, id+"_"+size.name+extension, variantType, scaled, encode)
+		if err != nil {
+			return variants, err
+		}
+
+		switch size.name {
+		case "thumbnail":
+			variants.Thumbnail = variant
+		case "medium":
+			variants.Medium = variant
+		case "large":
+			variants.Large = variant
+			large = scaled
+		}
+
+		srcset = append(srcset, fmt.Sprintf("%s %dw", variant.URL, variant.Width))
+
+		// smaller images would only produce the same variant again
+		if bounds.Dx() <= size.width {
+			break
+		}
+	}
+
+	if large == nil {
+		large = img
+	}
+
+	variant, err := put(store, dir, id+"_large.webp", "image/webp", large, encodeWebp)
+	if err != nil {
+		return variants, err
+	}
+	variants.Webp = variant
+	variants.Srcset = strings.Join(srcset, ", ")
+
+	return variants, nil
+}
+
+// scale shrinks the image to the given width keeping its aspect ratio
+func scale(img image.Image, width int) image.Image {
+	bounds := img.Bounds()
+	if bounds.Dx() <= width {
+		return img
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, bounds, draw.Over, nil)
+
+	return dst
+}
+
+func put(store storage.Storage, dir string, name string, contentType string, img image.Image, encode func(io.Writer, image.Image) error) (*Variant, error) {
+	var buf bytes.Buffer
+	if err := encode(&buf, img); err != nil {
+		return nil, err
+	}
+
+	if err := store.Put(storage.Key(dir, name), &buf, contentType); err != nil {
+		return nil, err
+	}
+
+	return &Variant{
+		Name:   name,
+		URL:    storage.URL(dir, name),
+		Width:  img.Bounds().Dx(),
+		Height: img.Bounds().Dy(),
+	}, nil
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+}
+
+func encodePNG(w io.Writer, img image.Image) error {
+	return png.Encode(w, img)
+}
+
+func encodeWebp(w io.Writer, img image.Image) error {
+	return webp.Encode(w, img, &webp.Options{Quality: webpQuality})
+}
+
+func tooLarge() error {
+	return echo.NewHTTPError(http.StatusRequestEntityTooLarge,
+		fmt.Sprintf("Image must not be larger than %d MB", MaxSize()>>20))
+}
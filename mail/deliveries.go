@@ -0,0 +1,309 @@
+package mail
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const deliveriesCollection = "mail_deliveries"
+
+const (
+	StatusQueued = "queued"
+	StatusSent   = "sent"
+	StatusFailed = "failed"
+)
+
+const (
+	// attempts made to send a message when MAIL_MAX_ATTEMPTS is not set
+	defaultMaxAttempts = 5
+	// wait before the second attempt, it doubles after every failure
+	retryDelay = time.Minute
+	// how often the queue is looked at and how much is sent each time
+	processInterval = 5 * time.Second
+	processBatch    = 50
+	// queued deliveries this late are pushed to the queue again, they
+	// were taken by an instance that stopped before sending them
+	sweepDelay = 10 * time.Minute
+)
+
+// redacted replaces the secrets of the data in the delivery log
+const redacted = "[redacted]"
+
+// ErrRedacted is returned by Retry for the deliveries sent with secrets,
+// they are not kept once the message is sent or given up
+var ErrRedacted = errors.New("the secrets of the message are not kept, it can not be sent again")
+
+// Secret is a value of the data kept out of the delivery log, e.g. a
+// link with a token. The message is sent with it and logged without it.
+type Secret string
+
+// Delivery is the log of a message, from the time it is queued to the
+// time it is sent or given up
+type Delivery struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id"`
+	Template      string             `json:"template" bson:"template"`
+	To            []string           `json:"to" bson:"to"`
+	ReplyTo       string             `json:"replyTo,omitempty" bson:"reply_to,omitempty"`
+	Subject       string             `json:"subject" bson:"subject"`
+	Text          string             `json:"text" bson:"text"`
+	HTML          string             `json:"html,omitempty" bson:"html,omitempty"`
+	Redacted      bool               `json:"redacted,omitempty" bson:"redacted,omitempty"`
+	Status        string             `json:"status" bson:"status"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	Error         string             `json:"error,omitempty" bson:"error,omitempty"`
+	NextAttemptAt *time.Time         `json:"nextAttemptAt,omitempty" bson:"next_attempt_at,omitempty"`
+	SentAt        *time.Time         `json:"sentAt,omitempty" bson:"sent_at,omitempty"`
+	CreatedAt     time.Time          `json:"createdAt" bson:"created_at"`
+	UpdatedAt     time.Time          `json:"updatedAt" bson:"updated_at"`
+
+	// the message with its secrets, only kept until it is sent or given up
+	Pending *Message `json:"-" bson:"pending,omitempty"`
+}
+
+// MaxAttempts returns the attempts made to send a message before it is
+// given up, configured by MAIL_MAX_ATTEMPTS
+func MaxAttempts() int {
+	if value, err := strconv.Atoi(os.Getenv("MAIL_MAX_ATTEMPTS")); err == nil && value > 0 {
+		return value
+	}
+
+	return defaultMaxAttempts
+}
+
+// Send makes the message of the template with the data and queues it, the
+// delivery log tells when it is sent. Nothing is queued without recipient.
+// The Secret values of the data are redacted in the log.
+func Send(name string, to []string, replyTo string, data Data) error {
+	if len(to) == 0 {
+		return nil
+	}
+
+	t, err := Lookup(name)
+	if err != nil {
+		return err
+	}
+
+	message, err := t.Render(data)
+	if err != nil {
+		return err
+	}
+
+	logged, pending := message, (*Message)(nil)
+	if public, ok := redact(data); ok {
+		if logged, err = t.Render(public); err != nil {
+			return err
+		}
+		pending = message
+	}
+
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	delivery := &Delivery{
+		ID:            primitive.NewObjectID(),
+		Template:      name,
+		To:            to,
+		ReplyTo:       replyTo,
+		Subject:       logged.Subject,
+		Text:          logged.Text,
+		HTML:          logged.HTML,
+		Redacted:      pending != nil,
+		Status:        StatusQueued,
+		NextAttemptAt: &now,
+		Pending:       pending,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err = repo.Insert(delivery); err != nil {
+		return err
+	}
+
+	return currentQueue().Push(delivery.ID.Hex(), now)
+}
+
+// redact returns a copy of the data with its secrets replaced, ok is false
+// when it has none
+func redact(data Data) (Data, bool) {
+	public, ok := Data{}, false
+	for name, value := range data {
+		if _, secret := value.(Secret); secret {
+			value, ok = redacted, true
+		}
+		public[name] = value
+	}
+
+	return public, ok
+}
+
+// Process sends the deliveries that are due, a failed one is queued again
+// later until it ran out of attempts. A delivery that can't be sent nor
+// logged is left to the next ones, the sweep queues it again.
+func Process(logger echo.Logger) error {
+	transport, err := Default()
+	if err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return err
+	}
+
+	ids, err := currentQueue().Take(time.Now(), processBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, hex := range ids {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			continue
+		}
+
+		var delivery Delivery
+		if err = repo.FindOne(bson.M{"_id": id, "status": StatusQueued}, &delivery); err != nil {
+			if err != repository.ErrNotFound {
+				logger.Errorf("mail delivery %s: %s", hex, err)
+			}
+			continue
+		}
+
+		if err = deliver(repo, transport, &delivery); err != nil {
+			logger.Errorf("mail delivery %s: %s", hex, err)
+		}
+	}
+
+	return nil
+}
+
+// Sweep queues again the deliveries still queued long after they were
+// due, e.g. when the instance that took them stopped or the queue lost
+// them. It returns how many were queued.
+func Sweep() (int, error) {
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return 0, err
+	}
+
+	deliveries := make([]Delivery, 0)
+	filter := bson.M{"status": StatusQueued, "next_attempt_at": bson.M{"$lte": time.Now().Add(-sweepDelay)}}
+	if err = repo.FindAll(filter, options.Find().SetProjection(bson.M{"_id": 1, "next_attempt_at": 1}), &deliveries); err != nil {
+		return 0, err
+	}
+
+	for i, delivery := range deliveries {
+		if err = currentQueue().Push(delivery.ID.Hex(), *delivery.NextAttemptAt); err != nil {
+			return i, err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// deliver sends the message of the delivery and logs the outcome
+func deliver(repo repository.Repository, transport Transport, delivery *Delivery) error {
+	message := &Message{Subject: delivery.Subject, Text: delivery.Text, HTML: delivery.HTML}
+	if delivery.Pending != nil {
+		message = delivery.Pending
+	}
+	message.To, message.ReplyTo = delivery.To, delivery.ReplyTo
+	sendErr := transport.Send(message)
+
+	now := time.Now()
+	delivery.Attempts++
+	set := bson.M{"attempts": delivery.Attempts, "updated_at": now}
+	unset := bson.M{"next_attempt_at": ""}
+
+	switch {
+	case sendErr == nil:
+		set["status"] = StatusSent
+		set["sent_at"] = now
+		unset["error"] = ""
+		unset["pending"] = ""
+	case delivery.Attempts >= MaxAttempts():
+		set["status"] = StatusFailed
+		set["error"] = sendErr.Error()
+		unset["pending"] = ""
+	default:
+		next := now.Add(retryDelay << uint(delivery.Attempts-1))
+		set["error"] = sendErr.Error()
+		set["next_attempt_at"] = next
+		delete(unset, "next_attempt_at")
+
+		if err := currentQueue().Push(delivery.ID.Hex(), next); err != nil {
+			return err
+		}
+	}
+
+	_, err := repo.Update(bson.M{"_id": delivery.ID}, bson.M{"$set": set, "$unset": unset})
+
+	return err
+}
+
+// Retry queues a failed delivery again with every attempt left, the ones
+// sent with secrets are not, see ErrRedacted
+func Retry(id primitive.ObjectID) (*Delivery, error) {
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"status": StatusQueued, "attempts": 0, "next_attempt_at": now, "updated_at": now}}
+	result, err := repo.Update(bson.M{"_id": id, "status": StatusFailed, "redacted": bson.M{"$ne": true}}, update)
+	if err != nil {
+		return nil, err
+	}
+	if result.MatchedCount == 0 {
+		failed := new(Delivery)
+		if err = repo.FindOne(bson.M{"_id": id, "status": StatusFailed}, failed); err == nil && failed.Redacted {
+			return nil, ErrRedacted
+		}
+		return nil, repository.ErrNotFound
+	}
+
+	if err = currentQueue().Push(id.Hex(), now); err != nil {
+		return nil, err
+	}
+
+	delivery := new(Delivery)
+	err = repo.FindByID(id, delivery)
+
+	return delivery, err
+}
+
+// Schedule sends the queued messages in the background, and now and then
+// sweeps the ones the queue lost
+func Schedule(logger echo.Logger) {
+	go func() {
+		swept := time.Time{}
+
+		for {
+			if time.Since(swept) >= sweepDelay {
+				if queued, err := Sweep(); err != nil {
+					logger.Error(err)
+				} else if queued > 0 {
+					logger.Infof("queued again %d mail deliveries", queued)
+				}
+				swept = time.Now()
+			}
+
+			if err := Process(logger); err != nil {
+				logger.Error(err)
+			}
+
+			time.Sleep(processInterval)
+		}
+	}()
+}
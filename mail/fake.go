@@ -0,0 +1,31 @@
+package mail
+
+import "sync"
+
+// Fake keeps the messages in memory instead of delivering them, for local
+// development and tests
+type Fake struct {
+	mutex sync.Mutex
+	sent  []Message
+}
+
+func NewFake() *Fake {
+	return &Fake{sent: make([]Message, 0)}
+}
+
+func (f *Fake) Send(message *Message) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.sent = append(f.sent, *message)
+
+	return nil
+}
+
+// Sent returns the messages sent so far, the oldest first
+func (f *Fake) Sent() []Message {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	return append([]Message(nil), f.sent...)
+}
@@ -0,0 +1,357 @@
+package mail
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// fields of the delivery log that can be used to filter and sort the list
+var deliveryFields = utils.Fields{
+	"template": "template",
+	"status":   "status",
+	"to":       "to",
+}
+
+// GetTemplates godoc
+// @Summary Get mail templates
+// @Description Get the templates of every kind of mails, custom tells the ones edited in place of the defaults
+// @ID get-mail-templates
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Success 200 {object} utils.HttpSuccess{data=[]Template}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /mail/templates [get]
+func GetTemplates(c echo.Context) error {
+	templates, err := Templates()
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(templates, ""))
+}
+
+// FindTemplate godoc
+// @Summary Find mail template by name
+// @Description Find the template of a kind of mails, with the sample of the values it is made with
+// @ID find-mail-templates
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param name path string true "Name of the template"
+// @Success 200 {object} utils.HttpSuccess{data=Template}
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /mail/templates/{name} [get]
+func FindTemplate(c echo.Context) error {
+	t, err := Lookup(c.Param("name"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	version.SetETag(c, t.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(t, ""))
+}
+
+// UpdateTemplate godoc
+// @Summary Edit mail template
+// @Description Replace the template of a kind of mails, subject and text are Go text templates and html a Go HTML template. They must run with the sample values.
+// @ID update-mail-templates
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param name path string true "Name of the template"
+// @Param subject body string true "Subject template"
+// @Param text body string true "Plain text body template"
+// @Param html body string false "HTML body template"
+// @Param If-Match header string false "ETag of the template the change is based on"
+// @Success 200 {object} utils.HttpSuccess{data=Template}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /mail/templates/{name} [put]
+func UpdateTemplate(c echo.Context) error {
+	current, err := Lookup(c.Param("name"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	changes := new(Template)
+	if err = c.Bind(changes); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+	if err = utils.Validate(c, changes); err != nil {
+		return err
+	}
+
+	/* the change must be based on the current version, when it says which */
+	submitted, err := version.Submitted(c, changes.Version)
+	if err != nil {
+		return err
+	}
+	if err = version.Check(submitted, current.Version, current); err != nil {
+		return err
+	}
+
+	edited := *current
+	edited.Subject = changes.Subject
+	edited.Text = changes.Text
+	edited.HTML = changes.HTML
+	if err = edited.Check(); err != nil {
+		return err
+	}
+
+	repo, err := repository.Open(templatesCollection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	now := time.Now()
+	edited.UpdatedAt = now
+	edited.Custom = true
+
+	if !current.Custom {
+		edited.ID = primitive.NewObjectID()
+		edited.CreatedAt = now
+		edited.Version = 1
+
+		if err = repo.Insert(&edited); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		audits.Record(c, audits.ActionCreate, templatesCollection, edited.ID, nil)
+	} else {
+		update := bson.M{"$set": bson.M{"subject": edited.Subject, "text": edited.Text, "html": edited.HTML, "updated_at": now}}
+
+		before := audits.Snapshot(templatesCollection, edited.ID)
+		selector := bson.M{"_id": edited.ID}
+		result, err := repo.Update(version.Selector(selector, current.Version), version.Bump(update))
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if result.MatchedCount == 0 {
+			return version.Reload(repo, edited.ID, &Template{})
+		}
+		edited.Version++
+
+		audits.Record(c, audits.ActionUpdate, templatesCollection, edited.ID, before)
+	}
+
+	version.SetETag(c, edited.Version)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(edited, "Updated"))
+}
+
+// ResetTemplate godoc
+// @Summary Reset mail template
+// @Description Drop the edited template of a kind of mails, the default one is used again
+// @ID reset-mail-templates
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param name path string true "Name of the template"
+// @Success 200 {object} utils.HttpSuccess{data=Template}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /mail/templates/{name} [delete]
+func ResetTemplate(c echo.Context) error {
+	current, err := Lookup(c.Param("name"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	if current.Custom {
+		repo, err := repository.Open(templatesCollection)
+		if err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+		}
+
+		before := audits.Snapshot(templatesCollection, current.ID)
+		if _, err = repo.Delete(bson.M{"_id": current.ID}); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+
+		audits.Record(c, audits.ActionDelete, templatesCollection, current.ID, before)
+	}
+
+	t, err := Lookup(current.Name)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(t, "Reset to the default template"))
+}
+
+// PreviewTemplate godoc
+// @Summary Preview mail template
+// @Description Make the mail of a template with its sample values, the template of the body when it has a subject or else the current one
+// @ID preview-mail-templates
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param name path string true "Name of the template"
+// @Param subject body string false "Subject template to try"
+// @Param text body string false "Plain text body template to try"
+// @Param html body string false "HTML body template to try"
+// @Success 200 {object} utils.HttpSuccess{data=Message}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 422 {object} utils.HttpError
+// @Router /mail/templates/{name}/preview [post]
+func PreviewTemplate(c echo.Context) error {
+	t, err := Lookup(c.Param("name"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Template not found")
+	}
+
+	draft := new(Template)
+	if err = c.Bind(draft); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format")
+	}
+	if draft.Subject != "" {
+		t.Subject, t.Text, t.HTML = draft.Subject, draft.Text, draft.HTML
+	}
+
+	if err = t.Check(); err != nil {
+		return err
+	}
+
+	message, err := t.Render(t.Sample)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(message, ""))
+}
+
+// GetDeliveries godoc
+// @Summary Get mail deliveries
+// @Description Get the log of the mails queued, sent or given up
+// @ID get-mail-deliveries
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param page query int false "Page number, starts from 1"
+// @Param limit query int false "Records per page, max 100"
+// @Param sort query string false "Sort fields, prefix with - for descending (e.g. -createdAt)"
+// @Param template query string false "Filter by template"
+// @Param status query string false "Filter by status (queued, sent, failed)"
+// @Param to query string false "Filter by recipient"
+// @Success 200 {object} utils.HttpSuccess{data=[]Delivery}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Router /mail/deliveries [get]
+func GetDeliveries(c echo.Context) error {
+	query, err := utils.NewListQuery(c, deliveryFields, Delivery{})
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	result := make([]Delivery, 0)
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), &result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// FindDelivery godoc
+// @Summary Find mail delivery by ID
+// @Description Find mail delivery by ID
+// @ID find-mail-deliveries
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the delivery"
+// @Success 200 {object} utils.HttpSuccess{data=Delivery}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Router /mail/deliveries/{id} [get]
+func FindDelivery(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(deliveriesCollection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var delivery Delivery
+
+	if err = repo.FindByID(id, &delivery); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(delivery, ""))
+}
+
+// RetryDelivery godoc
+// @Summary Retry mail delivery
+// @Description Queue a mail that was given up again, except the ones sent with secrets such as tokens
+// @ID retry-mail-deliveries
+// @Tags Mail
+// @Accept  json
+// @Produce  json
+// @Security Bearer
+// @Param id path string true "ID of the failed delivery"
+// @Success 200 {object} utils.HttpSuccess{data=Delivery}
+// @Failure 400 {object} utils.HttpError
+// @Failure 401 {object} utils.HttpError
+// @Failure 404 {object} utils.HttpError
+// @Failure 409 {object} utils.HttpError
+// @Router /mail/deliveries/{id}/retry [post]
+func RetryDelivery(c echo.Context) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	delivery, err := Retry(id)
+	if err == repository.ErrNotFound {
+		return echo.NewHTTPError(http.StatusNotFound, "Failed delivery not found")
+	}
+	if err == ErrRedacted {
+		return echo.NewHTTPError(http.StatusConflict, "The message was sent with secrets that are not kept, send it again from where it was made")
+	}
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(delivery, "Queued"))
+}
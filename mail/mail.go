@@ -0,0 +1,94 @@
+package mail
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Message is a mail with a plain text body and, when it has one, an HTML
+// alternative of it
+type Message struct {
+	To      []string `json:"to,omitempty"`
+	ReplyTo string   `json:"replyTo,omitempty"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html,omitempty"`
+}
+
+// Transport delivers the messages
+type Transport interface {
+	Send(message *Message) error
+}
+
+/* Used to create a singleton object of the configured transport. */
+var defaultTransport Transport
+var defaultTransportError error
+
+// Used to execute transport creation procedure only once.
+var transportOnce sync.Once
+var transportMutex sync.RWMutex
+
+// Default returns the transport selected by MAIL_DRIVER, "smtp" or "fake"
+// which keeps the messages in memory. There is no default, a server left
+// unconfigured would drop the mails without anyone noticing.
+func Default() (Transport, error) {
+	transportOnce.Do(func() {
+		transportMutex.Lock()
+		defer transportMutex.Unlock()
+
+		defaultTransport, defaultTransportError = open(os.Getenv("MAIL_DRIVER"))
+	})
+
+	transportMutex.RLock()
+	defer transportMutex.RUnlock()
+
+	return defaultTransport, defaultTransportError
+}
+
+// open returns the transport of the driver
+func open(driver string) (Transport, error) {
+	switch driver {
+	case "":
+		return nil, fmt.Errorf("MAIL_DRIVER is required, smtp or fake")
+	case "fake":
+		return NewFake(), nil
+	case "smtp":
+		port, _ := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		return NewSMTP(SMTPConfig{
+			Host:     os.Getenv("SMTP_HOST"),
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("SMTP_FROM"),
+		})
+	}
+
+	return nil, fmt.Errorf("unknown mail driver %s", driver)
+}
+
+// Use makes the messages sent afterwards go through the transport, e.g. a
+// Fake in tests
+func Use(t Transport) {
+	transportOnce.Do(func() {})
+
+	transportMutex.Lock()
+	defer transportMutex.Unlock()
+
+	defaultTransport, defaultTransportError = t, nil
+}
+
+// Staff returns the addresses told of what the visitors send, configured
+// by MAIL_STAFF as a comma separated list
+func Staff() []string {
+	addresses := make([]string, 0)
+	for _, address := range strings.Split(os.Getenv("MAIL_STAFF"), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+
+	return addresses
+}
@@ -0,0 +1,220 @@
+package mail
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/mail/mailtest"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const templateTest = "test"
+
+func init() {
+	Register(templateTest, "Sent by the tests", "Hello {{.Name}}", "Follow {{.Link}}\n", `<a href="{{.Link}}">{{.Link}}</a>`,
+		Data{"Name": "jane", "Link": "https://example.com/?token=sample"})
+}
+
+// setup switches to a memory repository, a memory queue and the transport
+func setup(transport Transport) echo.Logger {
+	repository.Use(repository.NewMemory())
+	UseQueue(NewMemory())
+	Use(transport)
+
+	logger := echo.New().Logger
+	logger.SetOutput(ioutil.Discard)
+
+	return logger
+}
+
+// failing fails to send the messages to the address
+type failing struct {
+	*Fake
+	address string
+}
+
+func (f failing) Send(message *Message) error {
+	if message.To[0] == f.address {
+		return errors.New("mailbox unavailable")
+	}
+
+	return f.Fake.Send(message)
+}
+
+func deliveries(t *testing.T) []Delivery {
+	t.Helper()
+
+	repo, _ := repository.Open(deliveriesCollection)
+	result := make([]Delivery, 0)
+	if err := repo.FindAll(bson.M{}, nil, &result); err != nil {
+		t.Fatal(err)
+	}
+
+	return result
+}
+
+func TestOpenRequiresDriver(t *testing.T) {
+	if _, err := open(""); err == nil {
+		t.Fatal("no mail driver is accepted")
+	}
+	if _, err := open("sendmail"); err == nil {
+		t.Fatal("an unknown mail driver is accepted")
+	}
+	if transport, err := open("fake"); err != nil || transport == nil {
+		t.Fatalf("fake driver = %v %v", transport, err)
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	server, err := mailtest.NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+
+	transport, err := NewSMTP(SMTPConfig{Host: server.Host(), Port: server.Port(), From: "cms@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	message := &Message{To: []string{"jane@example.com"}, ReplyTo: "bob@example.com\r\nBcc: eve@example.com", Subject: "Hello", Text: "Hi", HTML: "<p>Hi</p>"}
+	if err = transport.Send(message); err != nil {
+		t.Fatal(err)
+	}
+
+	received := server.Received()
+	if len(received) != 1 || received[0].From != "cms@example.com" || len(received[0].To) != 1 || received[0].To[0] != "jane@example.com" {
+		t.Fatalf("received = %+v", received)
+	}
+	data := received[0].Data
+	if !strings.Contains(data, "Subject: Hello") || !strings.Contains(data, "multipart/alternative") || !strings.Contains(data, "<p>Hi</p>") {
+		t.Fatalf("data = %s", data)
+	}
+	if strings.Contains(data, "\r\nBcc:") {
+		t.Fatalf("a header is added from the reply-to: %s", data)
+	}
+}
+
+func TestSendRedactsSecrets(t *testing.T) {
+	fake := NewFake()
+	logger := setup(fake)
+
+	link := Secret("https://example.com/reset?token=abc123")
+	if err := Send(templateTest, []string{"jane@example.com"}, "", Data{"Name": "Jane", "Link": link}); err != nil {
+		t.Fatal(err)
+	}
+
+	logged := deliveries(t)
+	if len(logged) != 1 || strings.Contains(logged[0].Text+logged[0].HTML, "abc123") || !strings.Contains(logged[0].Text, redacted) {
+		t.Fatalf("logged = %+v", logged)
+	}
+
+	if err := Process(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := fake.Sent()
+	if len(sent) != 1 || !strings.Contains(sent[0].Text, string(link)) || !strings.Contains(sent[0].HTML, "abc123") {
+		t.Fatalf("sent = %+v", sent)
+	}
+
+	logged = deliveries(t)
+	if logged[0].Status != StatusSent || logged[0].Pending != nil {
+		t.Fatalf("logged after sending = %+v", logged[0])
+	}
+}
+
+func TestRetryKeepsSecrets(t *testing.T) {
+	logger := setup(failing{Fake: NewFake(), address: "gone@example.com"})
+	os.Setenv("MAIL_MAX_ATTEMPTS", "1")
+	defer os.Unsetenv("MAIL_MAX_ATTEMPTS")
+
+	link := Secret("https://example.com/reset?token=abc123")
+	if err := Send(templateTest, []string{"gone@example.com"}, "", Data{"Name": "Jane", "Link": link}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Process(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	/* the message with the token is not kept once given up */
+	logged := deliveries(t)
+	if len(logged) != 1 || logged[0].Status != StatusFailed || logged[0].Pending != nil || !logged[0].Redacted {
+		t.Fatalf("given up = %+v", logged)
+	}
+
+	// retrying would send the redacted link
+	if _, err := Retry(logged[0].ID); err != ErrRedacted {
+		t.Fatalf("retry = %v, want ErrRedacted", err)
+	}
+	if logged = deliveries(t); logged[0].Status != StatusFailed {
+		t.Fatalf("after the retry = %+v", logged[0])
+	}
+}
+
+func TestProcessContinues(t *testing.T) {
+	fake := NewFake()
+	logger := setup(failing{Fake: fake, address: "gone@example.com"})
+
+	for _, to := range []string{"gone@example.com", "jane@example.com"} {
+		if err := Send(templateTest, []string{to}, "", Data{"Name": "Jane", "Link": "https://example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Process(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := fake.Sent(); len(sent) != 1 || sent[0].To[0] != "jane@example.com" {
+		t.Fatalf("sent = %+v", sent)
+	}
+	for _, delivery := range deliveries(t) {
+		switch delivery.To[0] {
+		case "gone@example.com":
+			if delivery.Status != StatusQueued || delivery.Attempts != 1 || delivery.Error == "" || delivery.NextAttemptAt == nil {
+				t.Errorf("failed delivery = %+v", delivery)
+			}
+		default:
+			if delivery.Status != StatusSent {
+				t.Errorf("delivery = %+v", delivery)
+			}
+		}
+	}
+}
+
+func TestSweep(t *testing.T) {
+	fake := NewFake()
+	logger := setup(fake)
+
+	/* queued by an instance that stopped before it was sent */
+	due := time.Now().Add(-time.Hour)
+	repo, _ := repository.Open(deliveriesCollection)
+	lost := Delivery{ID: primitive.NewObjectID(), Template: templateTest, To: []string{"jane@example.com"}, Subject: "Lost", Text: "text", Status: StatusQueued, NextAttemptAt: &due}
+	if err := repo.Insert(lost); err != nil {
+		t.Fatal(err)
+	}
+	recent := time.Now()
+	waiting := Delivery{ID: primitive.NewObjectID(), Template: templateTest, To: []string{"bob@example.com"}, Subject: "Waiting", Text: "text", Status: StatusQueued, NextAttemptAt: &recent}
+	if err := repo.Insert(waiting); err != nil {
+		t.Fatal(err)
+	}
+
+	if queued, err := Sweep(); err != nil || queued != 1 {
+		t.Fatalf("swept = %d %v", queued, err)
+	}
+	if err := Process(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if sent := fake.Sent(); len(sent) != 1 || sent[0].Subject != "Lost" {
+		t.Fatalf("sent = %+v", sent)
+	}
+}
@@ -0,0 +1,156 @@
+// Package mailtest runs a local SMTP server that keeps the mails it
+// receives, to test the smtp mail driver without a real mail server
+package mailtest
+
+import (
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Received is a mail as the server got it
+type Received struct {
+	From string
+	To   []string
+	Data string
+}
+
+// Server is a local SMTP server, it accepts every mail without
+// authentication nor TLS
+type Server struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	received []Received
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a free port of the loopback interface
+func NewServer() (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener, received: make([]Received, 0)}
+
+	s.wg.Add(1)
+	go s.serve()
+
+	return s, nil
+}
+
+// Host returns the address the server listens on
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.listener.Addr().String())
+
+	return host
+}
+
+// Port returns the port the server listens on
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
+	value, _ := strconv.Atoi(port)
+
+	return value
+}
+
+// Received returns the mails received so far, the oldest first
+func (s *Server) Received() []Received {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return append([]Received(nil), s.received...)
+}
+
+// Close stops the server
+func (s *Server) Close() error {
+	err := s.listener.Close()
+	s.wg.Wait()
+
+	return err
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.handle(conn)
+		}()
+	}
+}
+
+// handle speaks enough SMTP for net/smtp to send a mail
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	text := textproto.NewConn(conn)
+	reply := func(line string) bool {
+		return text.PrintfLine("%s", line) == nil
+	}
+
+	var mail Received
+	if !reply("220 mailtest ready") {
+		return
+	}
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250 mailtest")
+		case "MAIL":
+			mail = Received{From: address(line)}
+			reply("250 OK")
+		case "RCPT":
+			mail.To = append(mail.To, address(line))
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+
+			s.mutex.Lock()
+			s.received = append(s.received, mail)
+			s.mutex.Unlock()
+
+			reply("250 OK")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// address returns the address of a MAIL FROM:<a> or RCPT TO:<a> command
+func address(line string) string {
+	start := strings.Index(line, "<")
+	end := strings.LastIndex(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+
+	return line[start+1 : end]
+}
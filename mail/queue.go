@@ -0,0 +1,122 @@
+package mail
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	DB "github.com/muhammadardie/echo-cms/db"
+)
+
+// Queue holds the IDs of the deliveries to send with the time they are due
+type Queue interface {
+	// Push adds the delivery, or moves it when it is queued already
+	Push(id string, at time.Time) error
+	// Take removes and returns the deliveries due at the time, a delivery
+	// is taken by one instance only
+	Take(now time.Time, limit int64) ([]string, error)
+}
+
+const queueKey = "mail:queue"
+
+var ctx = context.Background()
+
+/* Used to switch the queue to another one, e.g. in memory. */
+var queue Queue = Redis{}
+var queueMutex sync.RWMutex
+
+// UseQueue makes the deliveries go through the queue afterwards
+func UseQueue(q Queue) {
+	queueMutex.Lock()
+	defer queueMutex.Unlock()
+
+	queue = q
+}
+
+func currentQueue() Queue {
+	queueMutex.RLock()
+	defer queueMutex.RUnlock()
+
+	return queue
+}
+
+// Redis keeps the queue in a sorted set scored by due time, shared by
+// every instance
+type Redis struct{}
+
+func (Redis) Push(id string, at time.Time) error {
+	return DB.InitRedis().ZAdd(ctx, queueKey, &redis.Z{Score: float64(at.Unix()), Member: id}).Err()
+}
+
+func (Redis) Take(now time.Time, limit int64) ([]string, error) {
+	client := DB.InitRedis()
+
+	ids, err := client.ZRangeByScore(ctx, queueKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   strconv.FormatInt(now.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	/* the instance that removes a delivery sends it */
+	taken := make([]string, 0, len(ids))
+	for _, id := range ids {
+		removed, err := client.ZRem(ctx, queueKey, id).Result()
+		if err != nil {
+			return taken, err
+		}
+		if removed == 1 {
+			taken = append(taken, id)
+		}
+	}
+
+	return taken, nil
+}
+
+// Memory keeps the queue in the running instance, for tests
+type Memory struct {
+	mutex sync.Mutex
+	due   map[string]time.Time
+}
+
+func NewMemory() *Memory {
+	return &Memory{due: map[string]time.Time{}}
+}
+
+func (m *Memory) Push(id string, at time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.due[id] = at
+
+	return nil
+}
+
+func (m *Memory) Take(now time.Time, limit int64) ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ids := make([]string, 0)
+	for id, at := range m.due {
+		if !at.After(now) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return m.due[ids[i]].Before(m.due[ids[j]])
+	})
+	if int64(len(ids)) > limit {
+		ids = ids[:limit]
+	}
+
+	for _, id := range ids {
+		delete(m.due, id)
+	}
+
+	return ids, nil
+}
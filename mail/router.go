@@ -0,0 +1,18 @@
+package mail
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/rbac"
+)
+
+func MailRegister(g *echo.Group) {
+	mail := g.Group("/mail", rbac.Allow(rbac.ManageMail))
+	mail.GET("/templates", GetTemplates)
+	mail.GET("/templates/:name", FindTemplate)
+	mail.PUT("/templates/:name", UpdateTemplate)
+	mail.DELETE("/templates/:name", ResetTemplate)
+	mail.POST("/templates/:name/preview", PreviewTemplate)
+	mail.GET("/deliveries", GetDeliveries)
+	mail.GET("/deliveries/:id", FindDelivery)
+	mail.POST("/deliveries/:id/retry", RetryDelivery)
+}
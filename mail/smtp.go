@@ -0,0 +1,99 @@
+package mail
+
+import (
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rs/xid"
+)
+
+const defaultSMTPPort = 587
+
+type SMTPConfig struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMTP delivers the messages to a mail server, with PLAIN authentication
+// when a username is set and STARTTLS when the server offers it
+type SMTP struct {
+	address string
+	auth    smtp.Auth
+	from    string
+}
+
+func NewSMTP(config SMTPConfig) (*SMTP, error) {
+	if config.Host == "" || config.From == "" {
+		return nil, fmt.Errorf("SMTP_HOST and SMTP_FROM are required by the smtp mail driver")
+	}
+	if config.Port == 0 {
+		config.Port = defaultSMTPPort
+	}
+
+	s := &SMTP{
+		address: net.JoinHostPort(config.Host, strconv.Itoa(config.Port)),
+		from:    config.From,
+	}
+	if config.Username != "" {
+		s.auth = smtp.PlainAuth("", config.Username, config.Password, config.Host)
+	}
+
+	return s, nil
+}
+
+func (s *SMTP) Send(message *Message) error {
+	if len(message.To) == 0 {
+		return nil
+	}
+
+	return smtp.SendMail(s.address, s.auth, s.from, message.To, s.compose(message))
+}
+
+// compose writes the message in the format of RFC 5322, the HTML body is
+// an alternative of the text one
+func (s *SMTP) compose(message *Message) []byte {
+	var b strings.Builder
+	b.WriteString("From: " + header(s.from) + "\r\n")
+	b.WriteString("To: " + header(strings.Join(message.To, ", ")) + "\r\n")
+	if message.ReplyTo != "" {
+		b.WriteString("Reply-To: " + header(message.ReplyTo) + "\r\n")
+	}
+	b.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", header(message.Subject)) + "\r\n")
+	b.WriteString("Date: " + time.Now().Format(time.RFC1123Z) + "\r\n")
+	b.WriteString("MIME-Version: 1.0\r\n")
+
+	if message.HTML == "" {
+		b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+		b.WriteString(lines(message.Text))
+
+		return []byte(b.String())
+	}
+
+	boundary := "alt-" + xid.New().String()
+	b.WriteString("Content-Type: multipart/alternative; boundary=\"" + boundary + "\"\r\n\r\n")
+	b.WriteString("--" + boundary + "\r\nContent-Type: text/plain; charset=UTF-8\r\n\r\n")
+	b.WriteString(lines(message.Text) + "\r\n")
+	b.WriteString("--" + boundary + "\r\nContent-Type: text/html; charset=UTF-8\r\n\r\n")
+	b.WriteString(lines(message.HTML) + "\r\n")
+	b.WriteString("--" + boundary + "--\r\n")
+
+	return []byte(b.String())
+}
+
+// header keeps a value on its line, a visitor can't add headers of their own
+func header(value string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value)
+}
+
+// lines ends every line of a body with CRLF
+func lines(body string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(body, "\r\n", "\n"), "\n", "\r\n")
+}
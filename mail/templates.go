@@ -0,0 +1,175 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const templatesCollection = "mail_templates"
+
+// Template makes the messages of a kind, its subject and text are Go
+// text templates and its HTML is a Go HTML template. The ones edited by
+// the admins are stored, the others are the defaults of the code.
+type Template struct {
+	ID          primitive.ObjectID     `json:"-" bson:"_id,omitempty"`
+	Name        string                 `json:"name" bson:"name"`
+	Description string                 `json:"description" bson:"-"`
+	Subject     string                 `json:"subject" bson:"subject" form:"subject" validate:"required"`
+	Text        string                 `json:"text" bson:"text" form:"text" validate:"required"`
+	HTML        string                 `json:"html" bson:"html,omitempty" form:"html"`
+	Sample      map[string]interface{} `json:"sample" bson:"-"`
+	Custom      bool                   `json:"custom" bson:"-"`
+	Version     int64                  `json:"version" bson:"version" form:"version"`
+	CreatedAt   time.Time              `json:"createdAt,omitempty" bson:"created_at,omitempty"`
+	UpdatedAt   time.Time              `json:"updatedAt,omitempty" bson:"updated_at,omitempty"`
+}
+
+// Data holds the values a template is executed with, by name
+type Data map[string]interface{}
+
+var defaults = map[string]Template{}
+var defaultsMutex sync.RWMutex
+
+// Register adds the default template of a kind of messages, its sample
+// holds every value the messages are made with to check the edited ones
+func Register(name string, description string, subject string, text string, html string, sample Data) {
+	defaultsMutex.Lock()
+	defer defaultsMutex.Unlock()
+
+	defaults[name] = Template{
+		Name:        name,
+		Description: description,
+		Subject:     subject,
+		Text:        text,
+		HTML:        html,
+		Sample:      sample,
+	}
+}
+
+// Templates returns the templates of every kind of messages by name, the
+// edited ones in place of the defaults
+func Templates() ([]Template, error) {
+	defaultsMutex.RLock()
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	defaultsMutex.RUnlock()
+	sort.Strings(names)
+
+	templates := make([]Template, 0, len(names))
+	for _, name := range names {
+		t, err := Lookup(name)
+		if err != nil {
+			return nil, err
+		}
+		templates = append(templates, *t)
+	}
+
+	return templates, nil
+}
+
+// Lookup returns the template of the kind of messages, the edited one
+// when there is one. It returns repository.ErrNotFound for unknown kinds.
+func Lookup(name string) (*Template, error) {
+	defaultsMutex.RLock()
+	t, ok := defaults[name]
+	defaultsMutex.RUnlock()
+	if !ok {
+		return nil, repository.ErrNotFound
+	}
+
+	repo, err := repository.Open(templatesCollection)
+	if err != nil {
+		return nil, err
+	}
+
+	var stored Template
+	if err = repo.FindOne(bson.M{"name": name}, &stored); err == nil {
+		stored.Description = t.Description
+		stored.Sample = t.Sample
+		stored.Custom = true
+		return &stored, nil
+	}
+
+	return &t, nil
+}
+
+// Check executes the template with its sample, the errors are the ones of
+// the fields that don't parse or refer to values the messages don't have
+func (t *Template) Check() error {
+	invalid := make([]utils.FieldError, 0)
+	for _, part := range t.parts() {
+		if _, err := part.execute(t.Sample); err != nil {
+			invalid = append(invalid, utils.FieldError{Field: part.field, Code: "template", Message: err.Error()})
+		}
+	}
+	if len(invalid) > 0 {
+		return utils.NewValidationError(invalid...)
+	}
+
+	return nil
+}
+
+// Render makes the message of the template with the data
+func (t *Template) Render(data Data) (*Message, error) {
+	message := &Message{}
+	targets := map[string]*string{"subject": &message.Subject, "text": &message.Text, "html": &message.HTML}
+
+	for _, part := range t.parts() {
+		out, err := part.execute(data)
+		if err != nil {
+			return nil, fmt.Errorf("mail template %s: %s", t.Name, err)
+		}
+		*targets[part.field] = out
+	}
+
+	return message, nil
+}
+
+type part struct {
+	field  string
+	source string
+	html   bool
+}
+
+func (t *Template) parts() []part {
+	parts := []part{{field: "subject", source: t.Subject}, {field: "text", source: t.Text}}
+	if t.HTML != "" {
+		parts = append(parts, part{field: "html", source: t.HTML, html: true})
+	}
+
+	return parts
+}
+
+// execute runs the source, values missing from the data are errors
+func (p part) execute(data map[string]interface{}) (string, error) {
+	var out bytes.Buffer
+
+	if p.html {
+		tmpl, err := htmltemplate.New(p.field).Option("missingkey=error").Parse(p.source)
+		if err != nil {
+			return "", err
+		}
+		err = tmpl.Execute(&out, data)
+		return out.String(), err
+	}
+
+	tmpl, err := template.New(p.field).Option("missingkey=error").Parse(p.source)
+	if err != nil {
+		return "", err
+	}
+	err = tmpl.Execute(&out, data)
+
+	return out.String(), err
+}
@@ -4,10 +4,16 @@ import (
 	"os"
 
 	"github.com/muhammadardie/echo-cms/auth"
+	"github.com/muhammadardie/echo-cms/components/search"
+	"github.com/muhammadardie/echo-cms/components/users"
+	"github.com/muhammadardie/echo-cms/crud"
 	DB "github.com/muhammadardie/echo-cms/db"
 	_ "github.com/muhammadardie/echo-cms/docs" // docs generated by Swag CLI
+	"github.com/muhammadardie/echo-cms/mail"
 	"github.com/muhammadardie/echo-cms/middleware"
 	"github.com/muhammadardie/echo-cms/routes"
+	"github.com/muhammadardie/echo-cms/storage"
+	"github.com/muhammadardie/echo-cms/trash"
 	echoSwagger "github.com/swaggo/echo-swagger" // echo-swagger middleware
 )
 
@@ -37,8 +43,8 @@ func main() {
 	// swagger
 	r.GET("/swagger/*", echoSwagger.WrapHandler)
 
-	// Serve files from the 'uploaded_files' directory
-	r.Static("/uploaded_files", "./uploaded_files")
+	// Serve uploaded files from the configured storage
+	r.GET("/uploaded_files/*", storage.Handler)
 
 	// Public routes
 	routes.RegisterPublic(r)
@@ -47,9 +53,38 @@ func main() {
 	g.POST("/login", auth.Login)
 	g.POST("/logout", auth.Logout)
 	g.POST("/token/refresh", auth.Refresh)
+	g.POST("/password/forgot", auth.RequestReset, auth.ResetRateLimit())
+	g.POST("/password/reset", auth.Reset, auth.ResetRateLimit())
+	g.POST("/email/verify", users.Verify)
 	g.Use(middleware.TokenAuthMiddleware)
 
 	routes.Register(g)
 
+	// Two documents of a collection can not have the same slug
+	if err := crud.EnsureIndexes(); err != nil {
+		r.Logger.Fatal(err)
+	}
+
+	// The searchable collections are ranked by their text index
+	if err := search.EnsureIndexes(); err != nil {
+		r.Logger.Fatal(err)
+	}
+
+	// Accounts created before roles were introduced become admins, once
+	if migrated, err := users.MigrateRoles(); err != nil {
+		r.Logger.Fatal(err)
+	} else if migrated > 0 {
+		r.Logger.Infof("gave the admin role to %d users without a role", migrated)
+	}
+
+	// Purge the trash once the retention period is over
+	trash.Schedule(r.Logger)
+
+	// Send the queued mails and retry the failed ones
+	if _, err := mail.Default(); err != nil {
+		r.Logger.Fatal(err)
+	}
+	mail.Schedule(r.Logger)
+
 	r.Logger.Fatal(r.Start(":" + os.Getenv("APP_PORT")))
 }
@@ -2,15 +2,20 @@ package middleware
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"reflect"
+	"strings"
 
 	"github.com/go-playground/validator/v10"
 	"github.com/labstack/echo/v4"
 	"github.com/labstack/echo/v4/middleware"
 	"github.com/labstack/gommon/log"
 	"github.com/muhammadardie/echo-cms/auth"
+	"github.com/muhammadardie/echo-cms/rbac"
 	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
 )
 
 type CustomValidator struct {
@@ -19,7 +24,7 @@ type CustomValidator struct {
 
 func New() *echo.Echo {
 	e := echo.New()
-	e.Validator = &CustomValidator{validator: validator.New()}
+	e.Validator = NewValidator()
 	e.HTTPErrorHandler = ErrorHandler
 
 	logLevel := os.Getenv("LOG_LEVEL")
@@ -42,48 +47,141 @@ func New() *echo.Echo {
 	}
 
 	e.Logger.SetLevel(log.ERROR)
+
+	// c.RealIP() keys the rate limits and is stored with the sessions, comments and messages
+	extractor, err := ipExtractor(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		e.Logger.Fatal(err)
+	}
+	e.IPExtractor = extractor
+
 	e.Pre(middleware.RemoveTrailingSlash())
 	e.Use(middleware.Logger())
 	e.Use(middleware.CORSWithConfig(middleware.CORSConfig{
-		AllowOrigins: []string{"*"},
-		AllowHeaders: []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization},
-		AllowMethods: []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
+		AllowOrigins:  []string{"*"},
+		AllowHeaders:  []string{echo.HeaderOrigin, echo.HeaderContentType, echo.HeaderAccept, echo.HeaderAuthorization, "If-None-Match", "If-Match"},
+		ExposeHeaders: []string{"ETag", "X-Cache"},
+		AllowMethods:  []string{echo.GET, echo.HEAD, echo.PUT, echo.PATCH, echo.POST, echo.DELETE},
 	}))
 
 	return e
 }
 
+// ipExtractor reads the IP of the client from X-Forwarded-For when the
+// request comes through one of the comma separated proxies, IPs or CIDRs,
+// and otherwise from the connection so the header can not be spoofed
+func ipExtractor(proxies string) (echo.IPExtractor, error) {
+	if strings.TrimSpace(proxies) == "" {
+		return echo.ExtractIPDirect(), nil
+	}
+
+	options := []echo.TrustOption{echo.TrustLoopback(false), echo.TrustLinkLocal(false), echo.TrustPrivateNet(false)}
+	for _, proxy := range strings.Split(proxies, ",") {
+		proxy = strings.TrimSpace(proxy)
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+
+		_, ipRange, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES: %s is not an IP or a CIDR", proxy)
+		}
+		options = append(options, echo.TrustIPRange(ipRange))
+	}
+
+	return echo.ExtractIPFromXFFHeader(options...), nil
+}
+
 func ErrorHandler(err error, c echo.Context) {
+	if errs, ok := err.(validator.ValidationErrors); ok {
+		err = fieldErrors(errs)
+	}
+
+	// every failing field is listed for the client to highlight
+	var fields []utils.FieldError
+	if invalid, ok := err.(*utils.ValidationError); ok {
+		fields = invalid.Fields
+		err = echo.NewHTTPError(http.StatusUnprocessableEntity, invalid.Error())
+	}
+
+	// the current record is sent back for the client to merge its changes
+	conflict, isConflict := err.(*version.Conflict)
+	if isConflict {
+		err = echo.NewHTTPError(http.StatusConflict, conflict.Error())
+		version.SetETag(c, conflict.Version)
+	}
+
 	report, ok := err.(*echo.HTTPError)
 	if !ok {
 		report = echo.NewHTTPError(http.StatusInternalServerError, err.Error())
 	}
 
-	if castedObject, ok := err.(validator.ValidationErrors); ok {
-		for _, err := range castedObject {
-			switch err.Tag() {
-			case "required":
-				report.Message = fmt.Sprintf("%s is required",
-					err.Field())
-			case "email":
-				report.Message = fmt.Sprintf("%s is not valid email",
-					err.Field())
-			case "gte":
-				report.Message = fmt.Sprintf("%s value must be greater than %s",
-					err.Field(), err.Param())
-			case "lte":
-				report.Message = fmt.Sprintf("%s value must be lower than %s",
-					err.Field(), err.Param())
-			}
+	message := fmt.Sprintf("%v", report.Message)
+
+	c.Logger().Error(report)
 
-			break
+	response := utils.NewError(report.Code, message)
+	response.Errors = fields
+	if isConflict {
+		response.Version = conflict.Version
+		response.Current = conflict.Current
+	}
+	c.JSON(report.Code, response)
+}
+
+// fieldErrors describes the validation errors, the fields are named as in
+// the request and nested ones keep their path like fields[0].type
+func fieldErrors(errs validator.ValidationErrors) *utils.ValidationError {
+	fields := make([]utils.FieldError, 0, len(errs))
+
+	for _, err := range errs {
+		name := err.Namespace()
+		if i := strings.Index(name, "."); i >= 0 {
+			name = name[i+1:]
 		}
+
+		var message string
+		switch err.Tag() {
+		case "required":
+			message = fmt.Sprintf("%s is required", name)
+		case "email":
+			message = fmt.Sprintf("%s is not valid email", name)
+		case "url":
+			message = fmt.Sprintf("%s is not a valid url", name)
+		case "gte", "min":
+			message = fmt.Sprintf("%s value must be greater than %s", name, err.Param())
+		case "lte", "max":
+			message = fmt.Sprintf("%s value must be lower than %s", name, err.Param())
+		case "oneof":
+			message = fmt.Sprintf("%s must be one of %s", name, strings.Join(strings.Fields(err.Param()), ", "))
+		default:
+			message = fmt.Sprintf("%s is not valid", name)
+		}
+
+		fields = append(fields, utils.FieldError{Field: name, Code: err.Tag(), Message: message})
 	}
 
-	message := fmt.Sprintf("%v", report.Message)
+	return utils.NewValidationError(fields...)
+}
 
-	c.Logger().Error(report)
-	c.JSON(report.Code, utils.NewError(report.Code, message))
+// NewValidator returns the validator of the requests, errors name the
+// fields by their json name
+func NewValidator() *CustomValidator {
+	v := validator.New()
+	v.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+
+		return name
+	})
+
+	return &CustomValidator{validator: v}
 }
 
 func (cv *CustomValidator) Validate(i interface{}) error {
@@ -109,6 +207,12 @@ func TokenAuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
 			return c.JSON(http.StatusUnauthorized, unauthorizedMessage)
 		}
 
+		rbac.SetActor(c, &rbac.Actor{
+			UserId:    tokenAuth.UserId,
+			Role:      tokenAuth.Role,
+			SessionId: tokenAuth.SessionId,
+		})
+
 		return next(c)
 	}
 }
@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIPExtractor(t *testing.T) {
+	for _, test := range []struct {
+		proxies string
+		remote  string
+		want    string
+	}{
+		{"", "203.0.113.7:4000", "203.0.113.7"},
+		{"", "10.0.0.2:4000", "10.0.0.2"},
+		{"10.0.0.0/8", "10.0.0.2:4000", "198.51.100.1"},
+		{"10.0.0.2", "10.0.0.2:4000", "198.51.100.1"},
+		{"10.0.0.3", "10.0.0.2:4000", "10.0.0.2"},
+		{"10.0.0.0/8", "203.0.113.7:4000", "203.0.113.7"},
+	} {
+		extractor, err := ipExtractor(test.proxies)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remote
+		req.Header.Set(echo.HeaderXForwardedFor, "198.51.100.1")
+		if ip := extractor(req); ip != test.want {
+			t.Errorf("proxies %q from %s = %s, want %s", test.proxies, test.remote, ip, test.want)
+		}
+	}
+
+	if _, err := ipExtractor("10.0.0.0/8, proxy"); err == nil {
+		t.Fatal("an invalid proxy is accepted")
+	}
+}
@@ -0,0 +1,190 @@
+package patch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// MediaType is the content type of a merge patch, application/json is
+// accepted as well
+const MediaType = "application/merge-patch+json"
+
+// Document is a JSON merge patch (RFC 7396), the fields it holds replace
+// the ones of the document, null removes them and objects are merged
+type Document map[string]interface{}
+
+// Read decodes the merge patch of the request body, the version the
+// patch is based on, when it holds one, is taken out of it
+func Read(c echo.Context) (Document, int64, error) {
+	contentType := strings.TrimSpace(strings.SplitN(c.Request().Header.Get(echo.HeaderContentType), ";", 2)[0])
+	if contentType != MediaType && contentType != echo.MIMEApplicationJSON {
+		return nil, 0, echo.NewHTTPError(http.StatusUnsupportedMediaType, "Content-Type must be "+MediaType)
+	}
+
+	var doc Document
+	if err := json.NewDecoder(c.Request().Body).Decode(&doc); err != nil || doc == nil {
+		return nil, 0, echo.NewHTTPError(http.StatusBadRequest, "Invalid JSON format, a patch must be an object")
+	}
+
+	based, _ := doc[version.Field].(float64)
+	delete(doc, version.Field)
+
+	return doc, int64(based), nil
+}
+
+// Apply merges the patch into record, a pointer to a struct, and returns
+// the update of the fields it changes with the next version of the
+// document. Only the fields with the given json names may be patched.
+func (d Document) Apply(record interface{}, fields ...string) (bson.M, error) {
+	value := reflect.ValueOf(record).Elem()
+	names := bsonNames(value.Type())
+
+	invalid := make([]utils.FieldError, 0)
+	for _, key := range d.Keys() {
+		if !contains(fields, key) {
+			invalid = append(invalid, utils.FieldError{Field: key, Code: "readonly", Message: fmt.Sprintf("%s can not be patched", key)})
+		}
+	}
+	if len(invalid) > 0 {
+		return nil, utils.NewValidationError(invalid...)
+	}
+
+	/* merge the patch into the json of the record then read it back */
+	raw, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	target := map[string]interface{}{}
+	if err = json.Unmarshal(raw, &target); err != nil {
+		return nil, err
+	}
+
+	if raw, err = json.Marshal(Merge(target, map[string]interface{}(d))); err != nil {
+		return nil, err
+	}
+
+	patched := reflect.New(value.Type())
+	if err = json.Unmarshal(raw, patched.Interface()); err != nil {
+		if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+			return nil, utils.NewValidationError(utils.FieldError{
+				Field:   typeErr.Field,
+				Code:    "type",
+				Message: fmt.Sprintf("%s must be a %s", typeErr.Field, typeErr.Type.Kind()),
+			})
+		}
+		return nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	value.Set(patched.Elem())
+
+	set := bson.M{}
+	unset := bson.M{}
+	for key, change := range d {
+		field, ok := names[key]
+		if !ok {
+			continue
+		}
+
+		if change == nil {
+			unset[field.bson] = ""
+		} else {
+			set[field.bson] = value.Field(field.index).Interface()
+		}
+	}
+
+	/* every patch makes a new version */
+	now := time.Now()
+	set["updated_at"] = now
+	if field, ok := names["updatedAt"]; ok {
+		value.Field(field.index).Set(reflect.ValueOf(now))
+	}
+	if field, ok := names[version.Field]; ok {
+		current := value.Field(field.index)
+		current.SetInt(current.Int() + 1)
+	}
+
+	update := bson.M{"$set": set}
+	if len(unset) > 0 {
+		update["$unset"] = unset
+	}
+
+	return version.Bump(update), nil
+}
+
+// Keys returns the names of the fields of the patch in order
+func (d Document) Keys() []string {
+	keys := make([]string, 0, len(d))
+	for key := range d {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Merge applies the patch to the target following RFC 7396 and returns
+// the result, the target is changed in place when it is an object
+func Merge(target interface{}, patch interface{}) interface{} {
+	changes, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	object, ok := target.(map[string]interface{})
+	if !ok {
+		object = map[string]interface{}{}
+	}
+
+	for key, value := range changes {
+		if value == nil {
+			delete(object, key)
+			continue
+		}
+
+		object[key] = Merge(object[key], value)
+	}
+
+	return object
+}
+
+type field struct {
+	index int
+	bson  string
+}
+
+// bsonNames maps the json names of the struct fields to their bson names
+func bsonNames(typ reflect.Type) map[string]field {
+	names := map[string]field{}
+
+	for i := 0; i < typ.NumField(); i++ {
+		key := strings.Split(typ.Field(i).Tag.Get("json"), ",")[0]
+		name := strings.Split(typ.Field(i).Tag.Get("bson"), ",")[0]
+		if key == "" || key == "-" || name == "" || name == "-" {
+			continue
+		}
+
+		names[key] = field{index: i, bson: name}
+	}
+
+	return names
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,105 @@
+package rbac
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleAuthor = "author"
+	RoleViewer = "viewer"
+)
+
+type Permission string
+
+const (
+	// ReadContent allows reading every content record in the admin api
+	ReadContent Permission = "content:read"
+	// WriteContent allows creating, updating and deleting any content record
+	WriteContent Permission = "content:write"
+	// WriteBlogs allows creating blog posts and changing the ones you own
+	WriteBlogs Permission = "blogs:write"
+	// ManageUsers allows managing user accounts
+	ManageUsers Permission = "users:manage"
+	// ReadAudits allows reading the audit log of every change
+	ReadAudits Permission = "audits:read"
+	// ManageTypes allows defining the custom content types
+	ManageTypes Permission = "types:manage"
+	// ManageMessages allows reading and answering the messages of the visitors
+	ManageMessages Permission = "messages:manage"
+	// ManageMail allows editing the mail templates and reading the delivery log
+	ManageMail Permission = "mail:manage"
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleAdmin:  {ReadContent, WriteContent, WriteBlogs, ManageUsers, ReadAudits, ManageTypes, ManageMessages, ManageMail},
+	RoleEditor: {ReadContent, WriteContent, WriteBlogs, ManageMessages},
+	RoleAuthor: {ReadContent, WriteBlogs},
+	RoleViewer: {ReadContent},
+}
+
+const actorKey = "actor"
+
+const forbiddenMessage = "Error: You don't have permission to access this resource"
+
+// Actor is the authenticated user performing the request
+type Actor struct {
+	UserId    string
+	Role      string
+	SessionId string
+}
+
+// Roles returns every known role
+func Roles() []string {
+	return []string{RoleAdmin, RoleEditor, RoleAuthor, RoleViewer}
+}
+
+// Can reports whether the role has been granted the permission, an empty
+// or unknown role is granted nothing
+func Can(role string, permission Permission) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Can reports whether the actor has been granted the permission
+func (a *Actor) Can(permission Permission) bool {
+	if a == nil {
+		return false
+	}
+
+	return Can(a.Role, permission)
+}
+
+// SetActor stores the authenticated user on the request context
+func SetActor(c echo.Context, actor *Actor) {
+	c.Set(actorKey, actor)
+}
+
+// GetActor returns the authenticated user of the request, nil on public routes
+func GetActor(c echo.Context) *Actor {
+	actor, _ := c.Get(actorKey).(*Actor)
+
+	return actor
+}
+
+// Allow only lets the request through when the authenticated user has
+// been granted the permission, it must run after TokenAuthMiddleware
+func Allow(permission Permission) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if !GetActor(c).Can(permission) {
+				return echo.NewHTTPError(http.StatusForbidden, forbiddenMessage)
+			}
+
+			return next(c)
+		}
+	}
+}
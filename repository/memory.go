@@ -0,0 +1,1293 @@
+package repository
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Memory is the driver keeping the documents in memory, meant for tests
+// running without any service. It understands the part of the query
+// language used by the components: equality, comparisons, $in, $nin,
+// $exists, $regex, $size, $elemMatch, $not, $and, $or and $nor in filters,
+// $set, $unset, $inc, $addToSet and $pull in updates, sort, skip and
+// limit in find options, and $match, $unwind and $group with $sum in
+// pipelines. Projections are ignored, unique indexes are enforced. Text
+// searches match whole words and quoted phrases, without stemming nor
+// stop words, and rank by the weighted count of the words found.
+type Memory struct {
+	mutex       sync.Mutex
+	collections map[string][]bson.M
+
+	// fields with a unique index, by collection
+	unique map[string][]string
+	// weighted fields of the text index, by collection
+	text map[string]bson.D
+}
+
+// NewMemory returns an empty in memory driver,
+// switch to it with repository.Use(repository.NewMemory())
+func NewMemory() *Memory {
+	return &Memory{collections: map[string][]bson.M{}, unique: map[string][]string{}, text: map[string]bson.D{}}
+}
+
+// Open returns the repository of the collection, it never fails
+func (m *Memory) Open(collection string) (Repository, error) {
+	return &memoryRepository{store: m, name: collection}, nil
+}
+
+type memoryRepository struct {
+	store *Memory
+	name  string
+}
+
+func (r *memoryRepository) FindAll(filter bson.M, opts *options.FindOptions, result interface{}) error {
+	target := reflect.ValueOf(result)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice {
+		return errNotSlice
+	}
+
+	r.store.mutex.Lock()
+	documents, err := r.matching(filter)
+	r.store.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if opts != nil {
+		if documents, err = arrange(documents, opts); err != nil {
+			return err
+		}
+	}
+
+	return fill(documents, target)
+}
+
+func (r *memoryRepository) FindOne(filter bson.M, result interface{}) error {
+	r.store.mutex.Lock()
+	documents, err := r.matching(filter)
+	r.store.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if len(documents) == 0 {
+		return ErrNotFound
+	}
+
+	return decode(documents[0], result)
+}
+
+func (r *memoryRepository) FindByID(id primitive.ObjectID, result interface{}) error {
+	return r.FindOne(bson.M{"_id": id}, result)
+}
+
+func (r *memoryRepository) Count(filter bson.M) (int64, error) {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	documents, err := r.matching(filter)
+
+	return int64(len(documents)), err
+}
+
+func (r *memoryRepository) Insert(document interface{}) error {
+	stored, err := toDocument(document)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := stored["_id"]; !ok {
+		stored["_id"] = primitive.NewObjectID()
+	}
+
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	for _, existing := range r.store.collections[r.name] {
+		if equal(existing["_id"], stored["_id"]) {
+			return fmt.Errorf("repository: duplicate key _id %v in %s", stored["_id"], r.name)
+		}
+	}
+
+	if err = r.duplicate(stored, -1); err != nil {
+		return err
+	}
+
+	r.store.collections[r.name] = append(r.store.collections[r.name], stored)
+
+	return nil
+}
+
+func (r *memoryRepository) Update(filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	changes, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	index, err := r.first(filter)
+	if err != nil || index < 0 {
+		return &mongo.UpdateResult{}, err
+	}
+
+	document := r.store.collections[r.name][index]
+	updated, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = apply(updated, changes); err != nil {
+		return nil, err
+	}
+
+	if !equal(updated["_id"], document["_id"]) {
+		return nil, fmt.Errorf("repository: _id can not be updated")
+	}
+
+	if err = r.duplicate(updated, index); err != nil {
+		return nil, err
+	}
+
+	result := &mongo.UpdateResult{MatchedCount: 1}
+	if !reflect.DeepEqual(updated, document) {
+		r.store.collections[r.name][index] = updated
+		result.ModifiedCount = 1
+	}
+
+	return result, nil
+}
+
+func (r *memoryRepository) Replace(filter bson.M, document interface{}) (*mongo.UpdateResult, error) {
+	replacement, err := toDocument(document)
+	if err != nil {
+		return nil, err
+	}
+
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	index, err := r.first(filter)
+	if err != nil || index < 0 {
+		return &mongo.UpdateResult{}, err
+	}
+
+	current := r.store.collections[r.name][index]
+	if id, ok := replacement["_id"]; ok && !equal(id, current["_id"]) {
+		return nil, fmt.Errorf("repository: _id can not be replaced")
+	}
+	replacement["_id"] = current["_id"]
+
+	if err = r.duplicate(replacement, index); err != nil {
+		return nil, err
+	}
+
+	r.store.collections[r.name][index] = replacement
+
+	return &mongo.UpdateResult{MatchedCount: 1, ModifiedCount: 1}, nil
+}
+
+func (r *memoryRepository) Delete(filter bson.M) (*mongo.DeleteResult, error) {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	index, err := r.first(filter)
+	if err != nil || index < 0 {
+		return &mongo.DeleteResult{}, err
+	}
+
+	documents := r.store.collections[r.name]
+	r.store.collections[r.name] = append(documents[:index:index], documents[index+1:]...)
+
+	return &mongo.DeleteResult{DeletedCount: 1}, nil
+}
+
+func (r *memoryRepository) DeleteAll(filter bson.M) (*mongo.DeleteResult, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	kept := make([]bson.M, 0)
+	for _, document := range r.store.collections[r.name] {
+		ok, err := match(document, query)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			kept = append(kept, document)
+		}
+	}
+
+	result := &mongo.DeleteResult{DeletedCount: int64(len(r.store.collections[r.name]) - len(kept))}
+	r.store.collections[r.name] = kept
+
+	return result, nil
+}
+
+func (r *memoryRepository) EnsureUnique(field string) error {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	for _, existing := range r.store.unique[r.name] {
+		if existing == field {
+			return nil
+		}
+	}
+
+	/* like MongoDB, the index can not be made over duplicates */
+	documents := r.store.collections[r.name]
+	for i, document := range documents {
+		value, ok := lookup(document, field)
+		if !ok || value == nil {
+			continue
+		}
+		for _, other := range documents[i+1:] {
+			if v, ok := lookup(other, field); ok && equal(v, value) {
+				return duplicateError(r.name, field, value)
+			}
+		}
+	}
+
+	r.store.unique[r.name] = append(r.store.unique[r.name], field)
+
+	return nil
+}
+
+func (r *memoryRepository) Aggregate(pipeline []bson.M, result interface{}) error {
+	target := reflect.ValueOf(result)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice {
+		return errNotSlice
+	}
+
+	r.store.mutex.Lock()
+	documents := make([]bson.M, len(r.store.collections[r.name]))
+	copy(documents, r.store.collections[r.name])
+	r.store.mutex.Unlock()
+
+	for _, stage := range pipeline {
+		step, err := toDocument(stage)
+		if err != nil {
+			return err
+		}
+		if len(step) != 1 {
+			return fmt.Errorf("repository: a stage needs a single operator")
+		}
+
+		for operator, argument := range step {
+			switch operator {
+			case "$match":
+				documents, err = matchStage(documents, argument)
+			case "$unwind":
+				documents, err = unwindStage(documents, argument)
+			case "$group":
+				documents, err = groupStage(documents, argument)
+			default:
+				err = fmt.Errorf("repository: unsupported stage %s", operator)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return fill(documents, target)
+}
+
+// matchStage keeps the documents matching the query
+func matchStage(documents []bson.M, argument interface{}) ([]bson.M, error) {
+	query, ok := argument.(bson.M)
+	if !ok {
+		return nil, fmt.Errorf("repository: $match needs a query")
+	}
+
+	kept := make([]bson.M, 0, len(documents))
+	for _, document := range documents {
+		ok, err := match(document, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			kept = append(kept, document)
+		}
+	}
+
+	return kept, nil
+}
+
+// unwindStage outputs a document for each element of the array field,
+// the documents where it is missing, null or empty are left out
+func unwindStage(documents []bson.M, argument interface{}) ([]bson.M, error) {
+	path, ok := argument.(string)
+	if !ok || !strings.HasPrefix(path, "$") {
+		return nil, fmt.Errorf("repository: $unwind needs a field path")
+	}
+	path = path[1:]
+
+	unwound := make([]bson.M, 0, len(documents))
+	for _, document := range documents {
+		value, _ := lookup(document, path)
+		elements, ok := value.(primitive.A)
+		if !ok {
+			if value != nil {
+				unwound = append(unwound, document)
+			}
+			continue
+		}
+
+		for _, element := range elements {
+			copied, err := toDocument(document)
+			if err != nil {
+				return nil, err
+			}
+			if err = setPath(copied, path, element); err != nil {
+				return nil, err
+			}
+			unwound = append(unwound, copied)
+		}
+	}
+
+	return unwound, nil
+}
+
+// groupStage outputs a document for each distinct _id expression with
+// the $sum of the other fields over the documents having it
+func groupStage(documents []bson.M, argument interface{}) ([]bson.M, error) {
+	spec, ok := argument.(bson.M)
+	if !ok {
+		return nil, fmt.Errorf("repository: $group needs a document")
+	}
+	if _, ok := spec["_id"]; !ok {
+		return nil, fmt.Errorf("repository: $group needs an _id")
+	}
+
+	groups := make([]bson.M, 0)
+	for _, document := range documents {
+		key := evaluate(document, spec["_id"])
+
+		var group bson.M
+		for _, existing := range groups {
+			if equal(existing["_id"], key) {
+				group = existing
+				break
+			}
+		}
+		if group == nil {
+			group = bson.M{"_id": key}
+			groups = append(groups, group)
+		}
+
+		for field, accumulator := range spec {
+			if field == "_id" {
+				continue
+			}
+
+			operators, ok := accumulator.(bson.M)
+			sum, found := operators["$sum"]
+			if !ok || !found || len(operators) != 1 {
+				return nil, fmt.Errorf("repository: unsupported accumulator of %s", field)
+			}
+
+			value := evaluate(document, sum)
+			if _, ok := number(value); !ok {
+				value = int32(0)
+			}
+			group[field] = add(group[field], value)
+		}
+	}
+
+	return groups, nil
+}
+
+// evaluate returns the value of the expression for the document, strings
+// starting with $ are field paths and documents hold expressions
+func evaluate(document bson.M, expression interface{}) interface{} {
+	switch e := expression.(type) {
+	case string:
+		if strings.HasPrefix(e, "$") {
+			value, _ := lookup(document, e[1:])
+			return value
+		}
+	case bson.M:
+		result := bson.M{}
+		for field, value := range e {
+			result[field] = evaluate(document, value)
+		}
+		return result
+	}
+
+	return expression
+}
+
+func (r *memoryRepository) EnsureText(name string, weights bson.D) error {
+	r.store.mutex.Lock()
+	defer r.store.mutex.Unlock()
+
+	r.store.text[r.name] = weights
+
+	return nil
+}
+
+func (r *memoryRepository) Search(text string, filter bson.M, limit int64, result interface{}) (int64, error) {
+	target := reflect.ValueOf(result)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Slice {
+		return 0, errNotSlice
+	}
+
+	r.store.mutex.Lock()
+	weights, indexed := r.store.text[r.name]
+	documents, err := r.matching(filter)
+	r.store.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+	if !indexed {
+		return 0, fmt.Errorf("repository: text index required by the search of %s", r.name)
+	}
+
+	query := parseText(text)
+	found := make([]bson.M, 0)
+	for _, document := range documents {
+		if score, ok := query.score(document, weights); ok {
+			hit := bson.M{"score": score}
+			for key, value := range document {
+				hit[key] = value
+			}
+			found = append(found, hit)
+		}
+	}
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i]["score"].(float64) > found[j]["score"].(float64)
+	})
+
+	total := int64(len(found))
+	if limit > 0 && limit < total {
+		found = found[:limit]
+	}
+
+	return total, fill(found, target)
+}
+
+// textQuery is a text search split in words, quoted phrases and words
+// excluded with -, all lower case
+type textQuery struct {
+	words    []string
+	phrases  []string
+	excluded []string
+}
+
+func parseText(text string) textQuery {
+	var query textQuery
+
+	parts := strings.Split(strings.ToLower(text), `"`)
+	for i, part := range parts {
+		/* the odd parts are between quotes */
+		if i%2 == 1 {
+			if phrase := strings.TrimSpace(part); phrase != "" {
+				query.phrases = append(query.phrases, phrase)
+			}
+			continue
+		}
+
+		for _, word := range strings.Fields(part) {
+			if strings.HasPrefix(word, "-") {
+				query.excluded = append(query.excluded, words(word)...)
+			} else {
+				query.words = append(query.words, words(word)...)
+			}
+		}
+	}
+
+	return query
+}
+
+// score returns the weighted count of the words and phrases found in the
+// fields, ok is false when the document does not match
+func (q textQuery) score(document bson.M, weights bson.D) (score float64, ok bool) {
+	found := map[string]float64{}
+	text := make([]string, 0, len(weights))
+
+	for _, field := range weights {
+		value, _ := lookup(document, field.Key)
+		content, _ := value.(string)
+		content = strings.ToLower(content)
+		weight, _ := number(field.Value)
+		text = append(text, content)
+
+		for _, word := range words(content) {
+			found[word] += weight
+		}
+		for _, phrase := range q.phrases {
+			score += weight * float64(strings.Count(content, phrase))
+		}
+	}
+
+	all := strings.Join(text, " ")
+	for _, phrase := range q.phrases {
+		if !strings.Contains(all, phrase) {
+			return 0, false
+		}
+	}
+	for _, word := range q.excluded {
+		if found[word] > 0 {
+			return 0, false
+		}
+	}
+
+	matched := len(q.phrases) > 0
+	for _, word := range q.words {
+		if found[word] > 0 {
+			score += found[word]
+			matched = true
+		}
+	}
+
+	return score, matched
+}
+
+// words splits the text in its words, letters and digits
+func words(text string) []string {
+	return strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// duplicate returns a duplicate key error when the document has the value
+// of a unique field of another stored document, skip is the index of the
+// document itself, the store must be locked
+func (r *memoryRepository) duplicate(document bson.M, skip int) error {
+	for _, field := range r.store.unique[r.name] {
+		value, ok := lookup(document, field)
+		if !ok || value == nil {
+			continue
+		}
+
+		for i, other := range r.store.collections[r.name] {
+			if v, ok := lookup(other, field); i != skip && ok && equal(v, value) {
+				return duplicateError(r.name, field, value)
+			}
+		}
+	}
+
+	return nil
+}
+
+// duplicateError is the error MongoDB returns for a duplicate key
+func duplicateError(collection string, field string, value interface{}) error {
+	return mongo.WriteException{WriteErrors: mongo.WriteErrors{{
+		Code:    duplicateKey,
+		Message: fmt.Sprintf("repository: duplicate key %s %v in %s", field, value, collection),
+	}}}
+}
+
+// matching returns the stored documents matching the filter,
+// the store must be locked
+func (r *memoryRepository) matching(filter bson.M) ([]bson.M, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	documents := make([]bson.M, 0)
+	for _, document := range r.store.collections[r.name] {
+		ok, err := match(document, query)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			documents = append(documents, document)
+		}
+	}
+
+	return documents, nil
+}
+
+// first returns the index of the first document matching the filter,
+// -1 when there is none. The store must be locked.
+func (r *memoryRepository) first(filter bson.M) (int, error) {
+	query, err := toDocument(filter)
+	if err != nil {
+		return -1, err
+	}
+
+	for index, document := range r.store.collections[r.name] {
+		ok, err := match(document, query)
+		if err != nil {
+			return -1, err
+		}
+		if ok {
+			return index, nil
+		}
+	}
+
+	return -1, nil
+}
+
+// toDocument converts a value to a document the way MongoDB stores it,
+// e.g. time.Time becomes primitive.DateTime and structs use their bson tags
+func toDocument(value interface{}) (bson.M, error) {
+	if value == nil {
+		return bson.M{}, nil
+	}
+
+	raw, err := bson.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	document := bson.M{}
+	err = bson.Unmarshal(raw, &document)
+
+	return document, err
+}
+
+var errNotSlice = fmt.Errorf("repository: result must be a pointer to a slice")
+
+// fill decodes the documents into the slice target points to
+func fill(documents []bson.M, target reflect.Value) error {
+	slice := reflect.MakeSlice(target.Elem().Type(), 0, len(documents))
+	for _, document := range documents {
+		item := reflect.New(target.Elem().Type().Elem())
+		if err := decode(document, item.Interface()); err != nil {
+			return err
+		}
+		slice = reflect.Append(slice, item.Elem())
+	}
+	target.Elem().Set(slice)
+
+	return nil
+}
+
+func decode(document bson.M, result interface{}) error {
+	raw, err := bson.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	return bson.Unmarshal(raw, result)
+}
+
+// arrange sorts the documents and applies skip and limit
+func arrange(documents []bson.M, opts *options.FindOptions) ([]bson.M, error) {
+	if opts.Sort != nil {
+		keys, err := sortKeys(opts.Sort)
+		if err != nil {
+			return nil, err
+		}
+
+		sorted := make([]bson.M, len(documents))
+		copy(sorted, documents)
+		sort.SliceStable(sorted, func(i, j int) bool {
+			for _, key := range keys {
+				a, _ := lookup(sorted[i], key.Key)
+				b, _ := lookup(sorted[j], key.Key)
+				if order := sortCompare(a, b); order != 0 {
+					return order*key.Value.(int) < 0
+				}
+			}
+			return false
+		})
+		documents = sorted
+	}
+
+	if opts.Skip != nil && *opts.Skip > 0 {
+		if *opts.Skip >= int64(len(documents)) {
+			return []bson.M{}, nil
+		}
+		documents = documents[*opts.Skip:]
+	}
+
+	if opts.Limit != nil && *opts.Limit != 0 {
+		limit := *opts.Limit
+		if limit < 0 {
+			limit = -limit
+		}
+		if limit < int64(len(documents)) {
+			documents = documents[:limit]
+		}
+	}
+
+	return documents, nil
+}
+
+// sortKeys reads the sort option as fields with an order of 1 or -1
+func sortKeys(value interface{}) (bson.D, error) {
+	var keys bson.D
+
+	switch sort := value.(type) {
+	case bson.D:
+		keys = sort
+	case bson.M:
+		for key, order := range sort {
+			keys = append(keys, bson.E{Key: key, Value: order})
+		}
+	default:
+		return nil, fmt.Errorf("repository: sort %T is not supported in memory", value)
+	}
+
+	normalized := make(bson.D, 0, len(keys))
+	for _, key := range keys {
+		order, ok := number(key.Value)
+		if !ok {
+			return nil, fmt.Errorf("repository: sort by %v is not supported in memory", key.Value)
+		}
+
+		direction := 1
+		if order < 0 {
+			direction = -1
+		}
+		normalized = append(normalized, bson.E{Key: key.Key, Value: direction})
+	}
+
+	return normalized, nil
+}
+
+// match reports whether the document matches the query
+func match(document bson.M, query bson.M) (bool, error) {
+	for key, condition := range query {
+		var ok bool
+		var err error
+
+		switch key {
+		case "$and", "$or", "$nor":
+			ok, err = matchLogical(document, key, condition)
+		default:
+			if strings.HasPrefix(key, "$") {
+				return false, fmt.Errorf("repository: %s is not supported in memory", key)
+			}
+
+			value, exists := lookup(document, key)
+			ok, err = matchField(value, exists, condition)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchLogical(document bson.M, operator string, condition interface{}) (bool, error) {
+	queries, ok := condition.(primitive.A)
+	if !ok {
+		return false, fmt.Errorf("repository: %s needs an array", operator)
+	}
+
+	matched := 0
+	for _, item := range queries {
+		query, ok := item.(primitive.M)
+		if !ok {
+			return false, fmt.Errorf("repository: %s needs an array of documents", operator)
+		}
+
+		ok, err := match(document, query)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			matched++
+		}
+	}
+
+	switch operator {
+	case "$and":
+		return matched == len(queries), nil
+	case "$or":
+		return matched > 0, nil
+	default:
+		return matched == 0, nil
+	}
+}
+
+// matchField reports whether the value of a field matches the condition,
+// exists tells whether the document has the field at all
+func matchField(value interface{}, exists bool, condition interface{}) (bool, error) {
+	operators, ok := condition.(primitive.M)
+	if !ok || !isOperators(operators) {
+		return equalsAny(value, condition)
+	}
+
+	for operator, argument := range operators {
+		var ok bool
+		var err error
+
+		switch operator {
+		case "$eq":
+			ok, err = equalsAny(value, argument)
+		case "$ne":
+			ok, err = equalsAny(value, argument)
+			ok = !ok
+		case "$gt", "$gte", "$lt", "$lte":
+			ok = compareAny(value, argument, operator)
+		case "$in", "$nin":
+			values, isArray := argument.(primitive.A)
+			if !isArray {
+				return false, fmt.Errorf("repository: %s needs an array", operator)
+			}
+			for _, item := range values {
+				if ok, err = equalsAny(value, item); err != nil || ok {
+					break
+				}
+			}
+			if operator == "$nin" {
+				ok = !ok
+			}
+		case "$exists":
+			ok = truthy(argument) == exists
+		case "$regex":
+			options, _ := operators["$options"].(string)
+			ok, err = matchRegex(value, argument, options)
+		case "$options":
+			ok = true
+		case "$size":
+			items, isArray := value.(primitive.A)
+			size, _ := number(argument)
+			ok = isArray && float64(len(items)) == size
+		case "$elemMatch":
+			ok, err = matchElement(value, argument)
+		case "$not":
+			ok, err = matchField(value, exists, argument)
+			ok = !ok
+		default:
+			return false, fmt.Errorf("repository: %s is not supported in memory", operator)
+		}
+
+		if err != nil || !ok {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+func matchElement(value interface{}, condition interface{}) (bool, error) {
+	items, ok := value.(primitive.A)
+	if !ok {
+		return false, nil
+	}
+
+	query, ok := condition.(primitive.M)
+	if !ok {
+		return false, fmt.Errorf("repository: $elemMatch needs a document")
+	}
+
+	for _, item := range items {
+		var matched bool
+		var err error
+
+		if document, isDocument := item.(primitive.M); isDocument && !isOperators(query) {
+			matched, err = match(document, query)
+		} else {
+			matched, err = matchField(item, true, query)
+		}
+
+		if err != nil || matched {
+			return matched, err
+		}
+	}
+
+	return false, nil
+}
+
+func matchRegex(value interface{}, pattern interface{}, options string) (bool, error) {
+	var expression string
+
+	switch regex := pattern.(type) {
+	case primitive.Regex:
+		expression, options = regex.Pattern, regex.Options
+	case string:
+		expression = regex
+	default:
+		return false, fmt.Errorf("repository: $regex needs a string")
+	}
+
+	flags := ""
+	for _, option := range "ims" {
+		if strings.ContainsRune(options, option) {
+			flags += string(option)
+		}
+	}
+	if flags != "" {
+		expression = "(?" + flags + ")" + expression
+	}
+
+	re, err := regexp.Compile(expression)
+	if err != nil {
+		return false, err
+	}
+
+	candidates := []interface{}{value}
+	if items, ok := value.(primitive.A); ok {
+		candidates = items
+	}
+
+	for _, candidate := range candidates {
+		if text, ok := candidate.(string); ok && re.MatchString(text) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// isOperators reports whether the document holds query operators
+// rather than a value to compare with
+func isOperators(document primitive.M) bool {
+	for key := range document {
+		if strings.HasPrefix(key, "$") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// equalsAny reports whether the value, or one of its items when it is an
+// array, equals target. A missing field equals null like in MongoDB.
+func equalsAny(value interface{}, target interface{}) (bool, error) {
+	if regex, ok := target.(primitive.Regex); ok {
+		return matchRegex(value, regex, "")
+	}
+
+	if equal(value, target) {
+		return true, nil
+	}
+
+	if items, ok := value.(primitive.A); ok {
+		for _, item := range items {
+			if equal(item, target) {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+func compareAny(value interface{}, target interface{}, operator string) bool {
+	candidates := []interface{}{value}
+	if items, ok := value.(primitive.A); ok {
+		candidates = items
+	}
+
+	for _, candidate := range candidates {
+		order, ok := compare(candidate, target)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case operator == "$gt" && order > 0,
+			operator == "$gte" && order >= 0,
+			operator == "$lt" && order < 0,
+			operator == "$lte" && order <= 0:
+			return true
+		}
+	}
+
+	return false
+}
+
+func equal(a interface{}, b interface{}) bool {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		return ok && x == y
+	}
+
+	return reflect.DeepEqual(a, b)
+}
+
+// compare orders two values of the same kind, ok is false when they can not be compared
+func compare(a interface{}, b interface{}) (order int, ok bool) {
+	if x, ok := number(a); ok {
+		y, ok := number(b)
+		if !ok {
+			return 0, false
+		}
+		return compareFloat(x, y), true
+	}
+
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		return strings.Compare(x, y), ok
+	case primitive.DateTime:
+		y, ok := b.(primitive.DateTime)
+		return compareFloat(float64(x), float64(y)), ok
+	case primitive.ObjectID:
+		y, ok := b.(primitive.ObjectID)
+		return bytes.Compare(x[:], y[:]), ok
+	case bool:
+		y, ok := b.(bool)
+		if !ok || x == y {
+			return 0, ok
+		}
+		if y {
+			return -1, true
+		}
+		return 1, true
+	}
+
+	return 0, false
+}
+
+// sortCompare orders any two values, missing and null values first
+func sortCompare(a interface{}, b interface{}) int {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0
+		case a == nil:
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	order, _ := compare(a, b)
+
+	return order
+}
+
+func compareFloat(x float64, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func number(value interface{}) (float64, bool) {
+	switch n := value.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+
+	return 0, false
+}
+
+func truthy(value interface{}) bool {
+	if b, ok := value.(bool); ok {
+		return b
+	}
+
+	n, ok := number(value)
+
+	return ok && n != 0
+}
+
+// lookup returns the value of a field, dots reach into embedded documents
+func lookup(document bson.M, path string) (interface{}, bool) {
+	var current interface{} = document
+
+	for _, key := range strings.Split(path, ".") {
+		var fields map[string]interface{}
+
+		switch embedded := current.(type) {
+		case bson.M:
+			fields = embedded
+		default:
+			return nil, false
+		}
+
+		value, ok := fields[key]
+		if !ok {
+			return nil, false
+		}
+		current = value
+	}
+
+	return current, true
+}
+
+// apply runs the update operators on the document
+func apply(document bson.M, update bson.M) error {
+	for operator, argument := range update {
+		fields, ok := argument.(primitive.M)
+		if !ok {
+			return fmt.Errorf("repository: update needs operators, %s is not one", operator)
+		}
+
+		for path, value := range fields {
+			var err error
+
+			switch operator {
+			case "$set":
+				err = setPath(document, path, value)
+			case "$unset":
+				unsetPath(document, path)
+			case "$inc":
+				current, _ := lookup(document, path)
+				err = setPath(document, path, add(current, value))
+			case "$addToSet":
+				err = addToSet(document, path, value)
+			case "$pull":
+				err = pull(document, path, value)
+			default:
+				err = fmt.Errorf("repository: %s is not supported in memory", operator)
+			}
+
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func setPath(document bson.M, path string, value interface{}) error {
+	keys := strings.Split(path, ".")
+	current := document
+
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(bson.M)
+		if !ok {
+			if _, exists := current[key]; exists {
+				return fmt.Errorf("repository: can not set %s, %s is not a document", path, key)
+			}
+			next = bson.M{}
+			current[key] = next
+		}
+		current = next
+	}
+
+	current[keys[len(keys)-1]] = value
+
+	return nil
+}
+
+func unsetPath(document bson.M, path string) {
+	keys := strings.Split(path, ".")
+	current := document
+
+	for _, key := range keys[:len(keys)-1] {
+		next, ok := current[key].(bson.M)
+		if !ok {
+			return
+		}
+		current = next
+	}
+
+	delete(current, keys[len(keys)-1])
+}
+
+// add sums two numbers keeping integers as integers
+func add(current interface{}, value interface{}) interface{} {
+	if current == nil {
+		return value
+	}
+
+	switch x := current.(type) {
+	case int32:
+		if y, ok := value.(int32); ok {
+			return x + y
+		}
+		if y, ok := value.(int64); ok {
+			return int64(x) + y
+		}
+	case int64:
+		if y, ok := value.(int32); ok {
+			return x + int64(y)
+		}
+		if y, ok := value.(int64); ok {
+			return x + y
+		}
+	}
+
+	x, _ := number(current)
+	y, _ := number(value)
+
+	return x + y
+}
+
+func addToSet(document bson.M, path string, value interface{}) error {
+	current, _ := lookup(document, path)
+	items, ok := current.(primitive.A)
+	if current != nil && !ok {
+		return fmt.Errorf("repository: can not add to %s, it is not an array", path)
+	}
+
+	values := primitive.A{value}
+	if each, ok := value.(primitive.M); ok && each["$each"] != nil {
+		if values, ok = each["$each"].(primitive.A); !ok {
+			return fmt.Errorf("repository: $each needs an array")
+		}
+	}
+
+	for _, value := range values {
+		found := false
+		for _, item := range items {
+			if equal(item, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			items = append(items, value)
+		}
+	}
+
+	return setPath(document, path, items)
+}
+
+func pull(document bson.M, path string, condition interface{}) error {
+	current, _ := lookup(document, path)
+	items, ok := current.(primitive.A)
+	if !ok {
+		return nil
+	}
+
+	kept := primitive.A{}
+	for _, item := range items {
+		var matched bool
+		var err error
+
+		query, isQuery := condition.(primitive.M)
+		element, isDocument := item.(primitive.M)
+
+		switch {
+		case isQuery && isDocument && !isOperators(query):
+			// a document condition matches the items having its fields
+			matched, err = match(element, query)
+		case isQuery:
+			matched, err = matchField(item, true, condition)
+		default:
+			matched = equal(item, condition)
+		}
+
+		if err != nil {
+			return err
+		}
+		if !matched {
+			kept = append(kept, item)
+		}
+	}
+
+	return setPath(document, path, kept)
+}
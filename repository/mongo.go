@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+
+	DB "github.com/muhammadardie/echo-cms/db"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ctx = context.Background()
+
+// Mongo is the driver storing the documents in the MongoDB database
+// configured by MONGODB_URL and MONGODB_NAME
+type Mongo struct{}
+
+// Open connects to the database once and returns the repository of the collection
+func (Mongo) Open(collection string) (Repository, error) {
+	db, err := DB.Connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return &mongoRepository{collection: db.Collection(collection)}, nil
+}
+
+type mongoRepository struct {
+	collection *mongo.Collection
+}
+
+func (r *mongoRepository) FindAll(filter bson.M, opts *options.FindOptions, result interface{}) error {
+	if opts == nil {
+		opts = options.Find()
+	}
+
+	csr, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+
+	defer csr.Close(ctx)
+
+	return csr.All(ctx, result)
+}
+
+func (r *mongoRepository) FindOne(filter bson.M, result interface{}) error {
+	return r.collection.FindOne(ctx, filter).Decode(result)
+}
+
+func (r *mongoRepository) FindByID(id primitive.ObjectID, result interface{}) error {
+	return r.FindOne(bson.M{"_id": id}, result)
+}
+
+func (r *mongoRepository) Count(filter bson.M) (int64, error) {
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+func (r *mongoRepository) Insert(document interface{}) error {
+	_, err := r.collection.InsertOne(ctx, document)
+
+	return err
+}
+
+func (r *mongoRepository) Update(filter bson.M, update bson.M) (*mongo.UpdateResult, error) {
+	return r.collection.UpdateOne(ctx, filter, update)
+}
+
+func (r *mongoRepository) Replace(filter bson.M, document interface{}) (*mongo.UpdateResult, error) {
+	return r.collection.ReplaceOne(ctx, filter, document)
+}
+
+func (r *mongoRepository) Delete(filter bson.M) (*mongo.DeleteResult, error) {
+	return r.collection.DeleteOne(ctx, filter)
+}
+
+func (r *mongoRepository) DeleteAll(filter bson.M) (*mongo.DeleteResult, error) {
+	return r.collection.DeleteMany(ctx, filter)
+}
+
+func (r *mongoRepository) EnsureUnique(field string) error {
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetName(field + "_unique").SetUnique(true).SetSparse(true),
+	})
+
+	return err
+}
+
+func (r *mongoRepository) Aggregate(pipeline []bson.M, result interface{}) error {
+	csr, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+
+	defer csr.Close(ctx)
+
+	return csr.All(ctx, result)
+}
+
+func (r *mongoRepository) EnsureText(name string, weights bson.D) error {
+	keys := bson.D{}
+	fields := bson.M{}
+	for _, weight := range weights {
+		keys = append(keys, bson.E{Key: weight.Key, Value: "text"})
+		fields[weight.Key] = weight.Value
+	}
+
+	_, err := r.collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    keys,
+		Options: options.Index().SetName(name).SetWeights(fields),
+	})
+
+	return err
+}
+
+func (r *mongoRepository) Search(text string, filter bson.M, limit int64, result interface{}) (int64, error) {
+	selector := bson.M{"$text": bson.M{"$search": text}}
+	for key, value := range filter {
+		selector[key] = value
+	}
+
+	total, err := r.collection.CountDocuments(ctx, selector)
+	if err != nil {
+		return 0, err
+	}
+
+	score := bson.M{"$meta": "textScore"}
+	opts := options.Find().SetProjection(bson.M{"score": score}).SetSort(bson.M{"score": score}).SetLimit(limit)
+
+	return total, r.FindAll(selector, opts, result)
+}
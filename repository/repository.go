@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrNotFound is returned when no document matches the filter
+var ErrNotFound = mongo.ErrNoDocuments
+
+// Repository stores the documents of a collection. Filters and updates
+// use the MongoDB query language whatever the driver is, results are
+// decoded with the bson tags of the models.
+type Repository interface {
+	// FindAll decodes the documents matching the filter into result,
+	// a pointer to a slice
+	FindAll(filter bson.M, opts *options.FindOptions, result interface{}) error
+	// FindOne decodes the first document matching the filter into result
+	FindOne(filter bson.M, result interface{}) error
+	// FindByID decodes the document with the ID into result
+	FindByID(id primitive.ObjectID, result interface{}) error
+	// Count returns the number of documents matching the filter
+	Count(filter bson.M) (int64, error)
+	// Insert stores a new document
+	Insert(document interface{}) error
+	// Update applies the update to the first document matching the filter
+	Update(filter bson.M, update bson.M) (*mongo.UpdateResult, error)
+	// Replace swaps the first document matching the filter for document
+	Replace(filter bson.M, document interface{}) (*mongo.UpdateResult, error)
+	// Delete removes the first document matching the filter
+	Delete(filter bson.M) (*mongo.DeleteResult, error)
+	// DeleteAll removes every document matching the filter
+	DeleteAll(filter bson.M) (*mongo.DeleteResult, error)
+	// EnsureUnique creates a unique index on the field, the documents
+	// without the field are left out of it
+	EnsureUnique(field string) error
+	// Aggregate runs the pipeline over the collection and decodes the
+	// documents it outputs into result, a pointer to a slice
+	Aggregate(pipeline []bson.M, result interface{}) error
+	// EnsureText creates the text index Search uses, named name, over the
+	// fields of weights, a field weighs its value when ranking
+	EnsureText(name string, weights bson.D) error
+	// Search decodes the documents matching the filter and the text, the
+	// most relevant first and at most limit, into result, a pointer to a
+	// slice, with their relevance in score. It returns how many match.
+	Search(text string, filter bson.M, limit int64, result interface{}) (int64, error)
+}
+
+// duplicateKey is the code of the writes failing on a unique index
+const duplicateKey = 11000
+
+// IsDuplicate reports whether the write failed because a unique field
+// already has the value in another document
+func IsDuplicate(err error) bool {
+	var exception mongo.WriteException
+	if !errors.As(err, &exception) {
+		return false
+	}
+
+	for _, writeErr := range exception.WriteErrors {
+		if writeErr.Code == duplicateKey {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Driver opens the repository of a collection
+type Driver interface {
+	Open(collection string) (Repository, error)
+}
+
+/* Used to switch every repository to another driver, e.g. in memory. */
+var driver Driver = Mongo{}
+var driverMutex sync.RWMutex
+
+// Use makes every repository opened afterwards use the driver
+func Use(d Driver) {
+	driverMutex.Lock()
+	defer driverMutex.Unlock()
+
+	driver = d
+}
+
+// Open returns the repository of a collection with the current driver,
+// MongoDB unless Use says otherwise
+func Open(collection string) (Repository, error) {
+	driverMutex.RLock()
+	defer driverMutex.RUnlock()
+
+	return driver.Open(collection)
+}
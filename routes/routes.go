@@ -4,73 +4,90 @@ import (
 	"net/http"
 
 	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/auth"
+	"github.com/muhammadardie/echo-cms/cache"
 	"github.com/muhammadardie/echo-cms/components/abouts"
+	"github.com/muhammadardie/echo-cms/components/audits"
 	"github.com/muhammadardie/echo-cms/components/blogs"
 	"github.com/muhammadardie/echo-cms/components/carousels"
+	"github.com/muhammadardie/echo-cms/components/categories"
+	"github.com/muhammadardie/echo-cms/components/comments"
 	"github.com/muhammadardie/echo-cms/components/companies"
 	"github.com/muhammadardie/echo-cms/components/contacts"
 	"github.com/muhammadardie/echo-cms/components/galleries"
 	"github.com/muhammadardie/echo-cms/components/headers"
+	"github.com/muhammadardie/echo-cms/components/media"
+	"github.com/muhammadardie/echo-cms/components/messages"
+	"github.com/muhammadardie/echo-cms/components/revisions"
+	"github.com/muhammadardie/echo-cms/components/search"
 	"github.com/muhammadardie/echo-cms/components/services"
 	"github.com/muhammadardie/echo-cms/components/socmeds"
+	"github.com/muhammadardie/echo-cms/components/tags"
 	"github.com/muhammadardie/echo-cms/components/teams"
 	"github.com/muhammadardie/echo-cms/components/testimonies"
+	"github.com/muhammadardie/echo-cms/components/types"
 	"github.com/muhammadardie/echo-cms/components/users"
+	"github.com/muhammadardie/echo-cms/crud"
+	"github.com/muhammadardie/echo-cms/i18n"
+	"github.com/muhammadardie/echo-cms/mail"
 )
 
 func Register(g *echo.Group) {
+	// writes drop the cached public responses of the same collection
+	g.Use(cache.Invalidator)
+
 	g.GET("/csrf", func(c echo.Context) error {
 		return c.JSON(http.StatusOK, c.Get("csrf"))
 	})
 	abouts.AboutsRegister(g)
+	audits.AuditsRegister(g)
+	auth.SessionsRegister(g)
 	blogs.BlogsRegister(g)
 	carousels.CarouselsRegister(g)
+	categories.CategoriesRegister(g)
+	comments.CommentsRegister(g)
 	companies.CompaniesRegister(g)
 	contacts.ContactsRegister(g)
 	galleries.GalleriesRegister(g)
 	headers.HeadersRegister(g)
+	mail.MailRegister(g)
+	media.MediaRegister(g)
+	messages.MessagesRegister(g)
+	revisions.RevisionsRegister(g)
 	services.ServicesRegister(g)
 	socmeds.SocmedsRegister(g)
+	tags.TagsRegister(g)
 	teams.TeamsRegister(g)
 	testimonies.TestimoniesRegister(g)
+	types.TypesRegister(g)
 	users.UsersRegister(g)
 }
 
 func RegisterPublic(r *echo.Echo) {
-	publicGroup := r.Group("/api/public")
-
-	// Public read-only routes
-	publicGroup.GET("/abouts", abouts.Get)
-	publicGroup.GET("/abouts/:id", abouts.Find)
-
-	publicGroup.GET("/blogs", blogs.Get)
-	publicGroup.GET("/blogs/:id", blogs.Find)
-
-	publicGroup.GET("/carousels", carousels.Get)
-	publicGroup.GET("/carousels/:id", carousels.Find)
+	publicGroup := r.Group("/api/public", cache.Middleware)
 
-	publicGroup.GET("/companies", companies.Get)
-	publicGroup.GET("/companies/:id", companies.Find)
+	// Public routes, read-only but for the comments and messages sent by visitors
+	crud.RegisterPublic(publicGroup)
 
-	publicGroup.GET("/contacts", contacts.Get)
-	publicGroup.GET("/contacts/:id", contacts.Find)
+	publicGroup.GET("/blogs/authors", blogs.GetAuthors)
+	publicGroup.GET("/blogs/authors/:author", blogs.GetByAuthor)
+	publicGroup.GET("/blogs/categories", blogs.GetCategories)
+	publicGroup.GET("/blogs/categories/:category", blogs.GetByCategory)
+	publicGroup.GET("/blogs/tags", blogs.GetTags)
+	publicGroup.GET("/blogs/tags/:tag", blogs.GetByTag)
+	publicGroup.GET("/blogs/:id/comments", comments.GetPublic)
+	publicGroup.POST("/blogs/:id/comments", comments.CreatePublic, comments.RateLimit())
 
-	publicGroup.GET("/galleries", galleries.Get)
-	publicGroup.GET("/galleries/:id", galleries.Find)
+	publicGroup.GET("/contents/:type", types.GetPublicContents)
+	publicGroup.GET("/contents/:type/:id", types.FindPublicContents)
 
-	publicGroup.GET("/headers", headers.Get)
-	publicGroup.GET("/headers/:id", headers.Find)
-	publicGroup.GET("/headers/page/:pagename", headers.FindByPage)
+	publicGroup.POST("/contact-messages", messages.CreatePublic, messages.RateLimit())
 
-	publicGroup.GET("/services", services.Get)
-	publicGroup.GET("/services/:id", services.Find)
+	publicGroup.GET("/headers/page/:pagename", headers.FindPublicByPage)
 
-	publicGroup.GET("/socmeds", socmeds.Get)
-	publicGroup.GET("/socmeds/:id", socmeds.Find)
+	publicGroup.GET("/locales", i18n.GetLocales)
 
-	publicGroup.GET("/teams", teams.Get)
-	publicGroup.GET("/teams/:id", teams.Find)
+	publicGroup.GET("/media/:id", media.FindPublic)
 
-	publicGroup.GET("/testimonies", testimonies.Get)
-	publicGroup.GET("/testimonies/:id", testimonies.Find)
+	publicGroup.GET("/search", search.Search)
 }
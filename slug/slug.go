@@ -0,0 +1,222 @@
+package slug
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/trash"
+	"github.com/muhammadardie/echo-cms/utils"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	// Field holds the slug of a document, its name in the URLs of the public site
+	Field = "slug"
+
+	// PreviousField holds the slugs a document had before, they redirect to the current one
+	PreviousField = "previous_slugs"
+)
+
+// longest slug made from a text, longer texts are cut at a word
+const maxLength = 80
+
+// latin letters with diacritics and the ASCII letters they are written with
+var folds = map[rune]string{
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'æ': "ae",
+	'ç': "c", 'è': "e", 'é': "e", 'ê': "e", 'ë': "e",
+	'ì': "i", 'í': "i", 'î': "i", 'ï': "i", 'ñ': "n",
+	'ò': "o", 'ó': "o", 'ô': "o", 'õ': "o", 'ö': "o", 'ø': "o", 'œ': "oe",
+	'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ý': "y", 'ÿ': "y", 'ß': "ss",
+}
+
+// Make returns the slug of a text, lowercase ASCII letters and digits
+// separated by hyphens, e.g. "cafe-au-lait" for "Café au lait!"
+func Make(text string) string {
+	var b strings.Builder
+	hyphen := false
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)):
+			b.WriteRune(r)
+			hyphen = false
+		case folds[r] != "":
+			b.WriteString(folds[r])
+			hyphen = false
+		case b.Len() > 0 && !hyphen:
+			b.WriteRune('-')
+			hyphen = true
+		}
+	}
+
+	slug := strings.TrimSuffix(b.String(), "-")
+	if len(slug) > maxLength {
+		slug = slug[:maxLength]
+		if i := strings.LastIndex(slug, "-"); i > 0 {
+			slug = slug[:i]
+		}
+	}
+
+	return slug
+}
+
+// Next returns the slug of the document of the ID and the slugs it had
+// before. A requested slug is used as it is made, it must not be used by
+// another document. Otherwise the slug is made from the source text when
+// there is none yet or the text changed, with a number added when it is
+// used by another document. The slug left behind redirects to the new one.
+func Next(collection string, id primitive.ObjectID, requested string, source string, current string, previous []string) (string, []string, error) {
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return "", nil, echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	slug := Make(requested)
+	if requested != "" {
+		if slug == "" {
+			return "", nil, utils.NewValidationError(utils.FieldError{Field: Field, Code: "slug", Message: "slug must hold letters or digits"})
+		}
+
+		used, err := taken(repo, id, slug)
+		if err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+		if used {
+			return "", nil, utils.NewValidationError(utils.FieldError{Field: Field, Code: "unique", Message: fmt.Sprintf("slug %s is already used", slug)})
+		}
+	} else {
+		base := Make(source)
+		if base == "" {
+			base = id.Hex()
+		}
+
+		// the slug is kept as long as it is made from the same text
+		if current != "" && (current == base || numbered(current, base)) {
+			return current, previous, nil
+		}
+
+		if slug, err = unique(repo, id, base); err != nil {
+			return "", nil, echo.NewHTTPError(http.StatusBadRequest, err.Error())
+		}
+	}
+
+	kept := make([]string, 0, len(previous)+1)
+	for _, p := range previous {
+		if p != slug && p != current {
+			kept = append(kept, p)
+		}
+	}
+	if current != "" && current != slug {
+		kept = append(kept, current)
+	}
+
+	return slug, kept, nil
+}
+
+// Index makes the slugs unique in the collection, two documents created at
+// once can not both get a slug Next found unused. The documents without
+// slug are left out.
+func Index(collection string) error {
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return err
+	}
+
+	return repo.EnsureUnique(Field)
+}
+
+// Conflict returns the error to respond with when the write failed
+// because the slug is already used, nil for any other error
+func Conflict(err error, slug interface{}) error {
+	if !repository.IsDuplicate(err) {
+		return nil
+	}
+
+	return utils.NewValidationError(utils.FieldError{Field: Field, Code: "unique", Message: fmt.Sprintf("slug %v is already used", slug)})
+}
+
+// Selector returns the selector of the document of a public URL, by ID or
+// by slug
+func Selector(param string) bson.M {
+	if id, err := primitive.ObjectIDFromHex(param); err == nil {
+		return bson.M{"_id": id}
+	}
+
+	return bson.M{Field: param}
+}
+
+// Redirect is the answer to a URL with a slug the document had before,
+// the client moves to the current one
+type Redirect struct {
+	Slug     string `json:"slug"`
+	Location string `json:"location"`
+}
+
+// Moved looks up the active document that had the slug, within the scope,
+// and responds with a 301 redirect to its current slug. It returns
+// repository.ErrNotFound when there is none.
+func Moved(c echo.Context, collection string, param string, scope bson.M) error {
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	var document bson.M
+
+	selector := bson.M{"$and": bson.A{trash.Active(bson.M{PreviousField: param}), scope}}
+	if err = repo.FindOne(selector, &document); err != nil {
+		return repository.ErrNotFound
+	}
+
+	current, _ := document[Field].(string)
+	location := strings.TrimSuffix(c.Request().URL.Path, "/"+param) + "/" + current
+
+	c.Response().Header().Set(echo.HeaderLocation, location)
+
+	moved := utils.NewSuccess(&Redirect{Slug: current, Location: location}, "Moved to "+current)
+	moved.Code = http.StatusMovedPermanently
+
+	return c.JSON(http.StatusMovedPermanently, moved)
+}
+
+// unique returns the base, or the base with the first number that makes
+// it unused by other documents
+func unique(repo repository.Repository, id primitive.ObjectID, base string) (string, error) {
+	slug := base
+
+	for n := 2; ; n++ {
+		used, err := taken(repo, id, slug)
+		if err != nil || !used {
+			return slug, err
+		}
+
+		slug = base + "-" + strconv.Itoa(n)
+	}
+}
+
+// taken tells whether another document, even in the trash, has or had the slug
+func taken(repo repository.Repository, id primitive.ObjectID, slug string) (bool, error) {
+	count, err := repo.Count(bson.M{
+		"_id": bson.M{"$ne": id},
+		"$or": bson.A{bson.M{Field: slug}, bson.M{PreviousField: slug}},
+	})
+
+	return count > 0, err
+}
+
+// numbered tells whether the slug is the base with a number added
+func numbered(slug string, base string) bool {
+	if !strings.HasPrefix(slug, base+"-") {
+		return false
+	}
+
+	_, err := strconv.Atoi(strings.TrimPrefix(slug, base+"-"))
+
+	return err == nil
+}
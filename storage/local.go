@@ -0,0 +1,67 @@
+package storage
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/labstack/echo/v4"
+)
+
+const defaultLocalPath = "./uploaded_files"
+
+// Local keeps the files on the disk of the running instance
+type Local struct {
+	root string
+}
+
+func NewLocal(root string) *Local {
+	if root == "" {
+		root = defaultLocalPath
+	}
+
+	return &Local{root: root}
+}
+
+func (l *Local) path(key string) string {
+	// clean against an absolute path so a key can never leave the root
+	return filepath.Join(l.root, filepath.FromSlash(path.Clean("/"+key)))
+}
+
+func (l *Local) Put(key string, src io.Reader, contentType string) error {
+	filePath := l.path(key)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(filePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	_, err = io.Copy(dst, src)
+
+	return err
+}
+
+func (l *Local) Delete(key string) error {
+	err := os.Remove(l.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (l *Local) Serve(c echo.Context, key string) error {
+	key, err := url.PathUnescape(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file name")
+	}
+
+	return c.File(l.path(key))
+}
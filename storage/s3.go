@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
+	"github.com/labstack/echo/v4"
+)
+
+// signed urls of served files stay valid for this long
+const signedURLExpiry = 15 * time.Minute
+
+type S3Config struct {
+	// Endpoint of an S3 compatible service (e.g. MinIO), empty for AWS
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	// ForcePathStyle addresses the bucket in the path, required by most S3 compatible services
+	ForcePathStyle bool
+}
+
+// S3 keeps the files in a bucket shared by every instance
+type S3 struct {
+	bucket   string
+	client   *s3.S3
+	uploader *s3manager.Uploader
+}
+
+func NewS3(config S3Config) (*S3, error) {
+	if config.Bucket == "" {
+		return nil, fmt.Errorf("S3_BUCKET is required for the s3 storage driver")
+	}
+
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+
+	awsConfig := aws.NewConfig().
+		WithRegion(config.Region).
+		WithS3ForcePathStyle(config.ForcePathStyle)
+
+	if config.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(config.Endpoint)
+	}
+
+	if config.AccessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(config.AccessKey, config.SecretKey, ""))
+	}
+
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	return &S3{
+		bucket:   config.Bucket,
+		client:   s3.New(sess),
+		uploader: s3manager.NewUploader(sess),
+	}, nil
+}
+
+func (s *S3) Put(key string, src io.Reader, contentType string) error {
+	input := &s3manager.UploadInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		Body:   src,
+	}
+
+	if contentType != "" {
+		input.ContentType = aws.String(contentType)
+	}
+
+	_, err := s.uploader.Upload(input)
+
+	return err
+}
+
+func (s *S3) Delete(key string) error {
+	_, err := s.client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	return err
+}
+
+func (s *S3) Serve(c echo.Context, key string) error {
+	key, err := url.PathUnescape(key)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid file name")
+	}
+
+	req, _ := s.client.GetObjectRequest(&s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+
+	signedURL, err := req.Presign(signedURLExpiry)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+
+	return c.Redirect(http.StatusTemporaryRedirect, signedURL)
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/labstack/echo/v4"
+	"github.com/rs/xid"
+)
+
+// Storage keeps the uploaded files. Every file is addressed by a key made
+// of the directory of its component and a generated name, e.g. "blog/ct23cs47.jpg"
+type Storage interface {
+	Put(key string, src io.Reader, contentType string) error
+	Delete(key string) error
+	// Serve writes the file to the response or redirects to a signed url
+	Serve(c echo.Context, key string) error
+}
+
+/* Used to create a singleton object of the configured storage. */
+var defaultStorage Storage
+var defaultStorageError error
+
+// Used to execute storage creation procedure only once.
+var storageOnce sync.Once
+
+// Default returns the storage selected by STORAGE_DRIVER, "local" (default) or "s3"
+func Default() (Storage, error) {
+	storageOnce.Do(func() {
+		switch driver := os.Getenv("STORAGE_DRIVER"); driver {
+		case "", "local":
+			defaultStorage = NewLocal(os.Getenv("STORAGE_LOCAL_PATH"))
+		case "s3":
+			defaultStorage, defaultStorageError = NewS3(S3Config{
+				Endpoint:       os.Getenv("S3_ENDPOINT"),
+				Region:         os.Getenv("S3_REGION"),
+				Bucket:         os.Getenv("S3_BUCKET"),
+				AccessKey:      os.Getenv("S3_ACCESS_KEY"),
+				SecretKey:      os.Getenv("S3_SECRET_KEY"),
+				ForcePathStyle: os.Getenv("S3_FORCE_PATH_STYLE") == "true",
+			})
+		default:
+			defaultStorageError = fmt.Errorf("unknown storage driver %s", driver)
+		}
+	})
+
+	return defaultStorage, defaultStorageError
+}
+
+// Key joins the component directory and the file name
+func Key(dir string, name string) string {
+	return dir + "/" + name
+}
+
+// URL returns the public path of the file, see Handler
+func URL(dir string, name string) string {
+	return "/uploaded_files/" + Key(dir, name)
+}
+
+// Upload stores the uploaded file in dir under a generated name and returns the name
+func Upload(file *multipart.FileHeader, dir string) (string, error) {
+	store, err := Default()
+	if err != nil {
+		return "", err
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return "", err
+	}
+	defer src.Close()
+
+	filename := xid.New().String() + filepath.Ext(file.Filename)
+	if err = store.Put(Key(dir, filename), src, file.Header.Get(echo.HeaderContentType)); err != nil {
+		return "", err
+	}
+
+	return filename, nil
+}
+
+// Remove deletes the file from dir, files that don't exist are skipped
+func Remove(dir string, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	store, err := Default()
+	if err != nil {
+		return err
+	}
+
+	return store.Delete(Key(dir, name))
+}
+
+// Handler serves the uploaded files, mounted on /uploaded_files/*
+func Handler(c echo.Context) error {
+	store, err := Default()
+	if err != nil {
+		return err
+	}
+
+	return store.Serve(c, c.Param("*"))
+}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/storage/storagetest"
+)
+
+// serve runs Serve for the key and returns the response
+func serve(t *testing.T, store Storage, key string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodGet, "/uploaded_files/"+key, nil), rec)
+	if err := store.Serve(c, key); err != nil {
+		e.HTTPErrorHandler(err, c)
+	}
+
+	return rec
+}
+
+func TestLocal(t *testing.T) {
+	root, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := NewLocal(root)
+	if err = store.Put("blog/a.txt", strings.NewReader("hello"), "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	rec := serve(t, store, "blog/a.txt")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" {
+		t.Fatalf("Serve = %d %q, want 200 hello", rec.Code, rec.Body.String())
+	}
+
+	if err = store.Delete("blog/a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if rec = serve(t, store, "blog/a.txt"); rec.Code != http.StatusNotFound {
+		t.Fatalf("Serve after Delete = %d, want 404", rec.Code)
+	}
+	if err = store.Delete("blog/a.txt"); err != nil {
+		t.Fatalf("Delete of a missing file: %v", err)
+	}
+}
+
+func TestLocalKeepsKeysInRoot(t *testing.T) {
+	root, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	store := NewLocal(filepath.Join(root, "files"))
+	if err = store.Put("../../escaped.txt", strings.NewReader("x"), ""); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(root, "files", "escaped.txt")); err != nil {
+		t.Fatalf("the file is not in the root: %v", err)
+	}
+	if _, err = os.Stat(filepath.Join(root, "escaped.txt")); !os.IsNotExist(err) {
+		t.Fatal("the key left the root")
+	}
+}
+
+func TestS3(t *testing.T) {
+	server := storagetest.NewServer("uploads")
+	defer server.Close()
+
+	store, err := NewS3(S3Config{
+		Endpoint:       server.URL(),
+		Bucket:         "uploads",
+		AccessKey:      "key",
+		SecretKey:      "secret",
+		ForcePathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+
+	if err = store.Put("blog/a.jpg", strings.NewReader("image"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	object, ok := server.Object("blog/a.jpg")
+	if !ok || string(object.Body) != "image" || object.ContentType != "image/jpeg" {
+		t.Fatalf("stored object = %+v %v", object, ok)
+	}
+
+	/* files are served by a signed url the client is redirected to */
+	rec := serve(t, store, "blog/a.jpg")
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Serve = %d, want 307", rec.Code)
+	}
+	location := rec.Header().Get("Location")
+	if !strings.HasPrefix(location, server.URL()+"/uploads/blog/a.jpg?") {
+		t.Fatalf("Serve redirects to %s", location)
+	}
+
+	res, err := http.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || string(body) != "image" {
+		t.Fatalf("signed url = %d %q, want 200 image", res.StatusCode, body)
+	}
+
+	if err = store.Delete("blog/a.jpg"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok = server.Object("blog/a.jpg"); ok {
+		t.Fatal("the object is still stored after Delete")
+	}
+}
+
+func TestS3ServesEscapedKeys(t *testing.T) {
+	server := storagetest.NewServer("uploads")
+	defer server.Close()
+
+	store, err := NewS3(S3Config{Endpoint: server.URL(), Bucket: "uploads", AccessKey: "key", SecretKey: "secret", ForcePathStyle: true})
+	if err != nil {
+		t.Fatalf("NewS3: %v", err)
+	}
+	if err = store.Put("blog/my photo.jpg", strings.NewReader("image"), "image/jpeg"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	/* the key comes from the path of the request, escaped like on the local storage */
+	rec := serve(t, store, "blog/my%20photo.jpg")
+	if rec.Code != http.StatusTemporaryRedirect {
+		t.Fatalf("Serve = %d, want 307", rec.Code)
+	}
+
+	res, err := http.Get(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("signed url = %d, want 200", res.StatusCode)
+	}
+}
+
+func TestS3RequiresBucket(t *testing.T) {
+	if _, err := NewS3(S3Config{}); err == nil {
+		t.Fatal("NewS3 without a bucket succeeded")
+	}
+}
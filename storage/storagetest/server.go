@@ -0,0 +1,108 @@
+// Package storagetest runs a local S3 compatible server that keeps the
+// objects in memory, to test the s3 storage driver without MinIO
+package storagetest
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+)
+
+// Object is a file as the server stored it
+type Object struct {
+	Body        []byte
+	ContentType string
+}
+
+// Server is a local S3 compatible server holding a single bucket, it
+// understands path style put, get, head and delete object requests and
+// only checks that they are signed
+type Server struct {
+	bucket  string
+	server  *httptest.Server
+	mutex   sync.Mutex
+	objects map[string]Object
+}
+
+// NewServer starts a server with the bucket on a free port of the
+// loopback interface
+func NewServer(bucket string) *Server {
+	s := &Server{bucket: bucket, objects: map[string]Object{}}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+
+	return s
+}
+
+// URL returns the endpoint of the server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Object returns the object stored under the key
+func (s *Server) Object(key string) (Object, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	object, ok := s.objects[key]
+
+	return object, ok
+}
+
+// Close stops the server
+func (s *Server) Close() {
+	s.server.Close()
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	signed := r.Header.Get("Authorization") != "" || r.URL.Query().Get("X-Amz-Signature") != ""
+	if !signed {
+		s.fail(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	key := strings.TrimPrefix(r.URL.Path, "/"+s.bucket+"/")
+	if key == r.URL.Path || key == "" {
+		s.fail(w, http.StatusNotFound, "NoSuchBucket")
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			s.fail(w, http.StatusBadRequest, "IncompleteBody")
+			return
+		}
+		s.objects[key] = Object{Body: body, ContentType: r.Header.Get("Content-Type")}
+		w.Header().Set("ETag", `"stored"`)
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet, http.MethodHead:
+		object, ok := s.objects[key]
+		if !ok {
+			s.fail(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.Header().Set("Content-Type", object.ContentType)
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(object.Body)
+		}
+	case http.MethodDelete:
+		// like S3, deleting a missing object succeeds
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		s.fail(w, http.StatusMethodNotAllowed, "MethodNotAllowed")
+	}
+}
+
+func (s *Server) fail(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?><Error><Code>` + code + `</Code></Error>`))
+}
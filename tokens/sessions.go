@@ -0,0 +1,150 @@
+package tokens
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	DB "github.com/muhammadardie/echo-cms/db"
+)
+
+// Session is a login of a user on a device, it lasts across the refreshes
+// of its tokens until it is revoked or the refresh token expires
+type Session struct {
+	ID         string    `json:"id"`
+	UserId     string    `json:"userId"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"userAgent"`
+	Device     string    `json:"device"`
+	Current    bool      `json:"current"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+	// keys of the access and refresh tokens of the session
+	Keys []string `json:"-"`
+}
+
+// stored is the session as kept in Redis, with the keys of its tokens
+type stored struct {
+	Session
+	Keys []string `json:"keys"`
+}
+
+// Save keeps the session and adds it to the sessions of the user, it
+// replaces the one of the same ID
+func Save(session *Session) error {
+	client := DB.InitRedis()
+	ttl := time.Until(session.ExpiresAt)
+
+	session.Current = false
+	value, err := json.Marshal(stored{Session: *session, Keys: session.Keys})
+	if err != nil {
+		return err
+	}
+
+	if err = client.Set(ctx, sessionKey(session.ID), value, ttl).Err(); err != nil {
+		return err
+	}
+	if err = client.SAdd(ctx, sessionsKey(session.UserId), session.ID).Err(); err != nil {
+		return err
+	}
+
+	/* the index lasts as long as the newest session, the sessions all live as long */
+	return client.Expire(ctx, sessionsKey(session.UserId), ttl).Err()
+}
+
+// Find returns the session of the ID, ErrInvalid when it expired or was
+// revoked
+func Find(id string) (*Session, error) {
+	value, err := DB.InitRedis().Get(ctx, sessionKey(id)).Bytes()
+	if err == redis.Nil {
+		return nil, ErrInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s stored
+	if err = json.Unmarshal(value, &s); err != nil {
+		return nil, err
+	}
+	s.Session.Keys = s.Keys
+
+	return &s.Session, nil
+}
+
+// Sessions returns the sessions of the user, the newest first
+func Sessions(userid string) ([]Session, error) {
+	client := DB.InitRedis()
+
+	ids, err := client.SMembers(ctx, sessionsKey(userid)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	sessions := make([]Session, 0, len(ids))
+	for _, id := range ids {
+		session, err := Find(id)
+		if err == ErrInvalid {
+			/* expired with its tokens, the index is tidied up on the way */
+			client.SRem(ctx, sessionsKey(userid), id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, *session)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].CreatedAt.After(sessions[j].CreatedAt)
+	})
+
+	return sessions, nil
+}
+
+// End revokes the session of the user, its tokens stop working. It returns
+// ErrInvalid when the user has no such session.
+func End(userid string, id string) error {
+	session, err := Find(id)
+	if err != nil {
+		return err
+	}
+	if session.UserId != userid {
+		return ErrInvalid
+	}
+
+	client := DB.InitRedis()
+	if err = client.Del(ctx, append(session.Keys, sessionKey(id))...).Err(); err != nil {
+		return err
+	}
+
+	return client.SRem(ctx, sessionsKey(userid), id).Err()
+}
+
+// Revoke ends every session of the user, the access and refresh tokens
+// given before stop working
+func Revoke(userid string) error {
+	sessions, err := Sessions(userid)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if err = End(userid, session.ID); err != nil && err != ErrInvalid {
+			return err
+		}
+	}
+
+	return DB.InitRedis().Del(ctx, sessionsKey(userid)).Err()
+}
+
+func sessionKey(id string) string {
+	return "session:" + id
+}
+
+func sessionsKey(userid string) string {
+	return "sessions:" + userid
+}
@@ -0,0 +1,67 @@
+package tokens
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	DB "github.com/muhammadardie/echo-cms/db"
+)
+
+// ErrInvalid is returned for a token that was never issued, has expired or
+// was used already
+var ErrInvalid = errors.New("invalid or expired token")
+
+var ctx = context.Background()
+
+// Issue returns a random token of the kind standing for the value until
+// the ttl is over. Redis only holds a hash of it, a leak of Redis doesn't
+// give the tokens away.
+func Issue(kind string, value string, ttl time.Duration) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(b)
+
+	if err := DB.InitRedis().Set(ctx, key(kind, token), value, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// Redeem returns the value of the token and drops it, a token is used once
+func Redeem(kind string, token string) (string, error) {
+	client := DB.InitRedis()
+	k := key(kind, token)
+
+	value, err := client.Get(ctx, k).Result()
+	if err == redis.Nil {
+		return "", ErrInvalid
+	}
+	if err != nil {
+		return "", err
+	}
+
+	/* the request that removes the token is the one that uses it */
+	removed, err := client.Del(ctx, k).Result()
+	if err != nil {
+		return "", err
+	}
+	if removed == 0 {
+		return "", ErrInvalid
+	}
+
+	return value, nil
+}
+
+func key(kind string, token string) string {
+	sum := sha256.Sum256([]byte(token))
+
+	return kind + ":" + hex.EncodeToString(sum[:])
+}
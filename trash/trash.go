@@ -0,0 +1,188 @@
+package trash
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/components/audits"
+	"github.com/muhammadardie/echo-cms/repository"
+	"github.com/muhammadardie/echo-cms/utils"
+	"github.com/muhammadardie/echo-cms/version"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Field marks a document as deleted, it holds the time it was moved to the trash
+const Field = "deleted_at"
+
+// deleted documents are purged after 30 days unless TRASH_RETENTION_DAYS says otherwise
+const defaultRetention = 30 * 24 * time.Hour
+
+// how often the trash is checked for documents to purge
+const purgeInterval = time.Hour
+
+// fields that can be used to sort the trash
+var listFields = utils.Fields{
+	"deletedAt": Field,
+}
+
+// PurgeFunc frees what a document holds besides itself, like its uploaded
+// files, right before it is deleted for good
+type PurgeFunc func(id primitive.ObjectID) error
+
+/* Used to know the collections to purge and how. */
+var purgers = map[string]PurgeFunc{}
+var purgersMutex sync.Mutex
+
+// Register adds a collection with soft deleted documents to the purge,
+// purge may be nil when the documents hold nothing else
+func Register(collection string, purge PurgeFunc) {
+	purgersMutex.Lock()
+	defer purgersMutex.Unlock()
+
+	purgers[collection] = purge
+}
+
+// Retention returns how long deleted documents stay in the trash,
+// configured in days by TRASH_RETENTION_DAYS
+func Retention() time.Duration {
+	if value, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && value >= 0 {
+		return time.Duration(value) * 24 * time.Hour
+	}
+
+	return defaultRetention
+}
+
+// Active narrows the filter to the documents that are not in the trash
+func Active(filter bson.M) bson.M {
+	filter[Field] = bson.M{"$exists": false}
+
+	return filter
+}
+
+// Deleted narrows the filter to the documents in the trash
+func Deleted(filter bson.M) bson.M {
+	filter[Field] = bson.M{"$exists": true}
+
+	return filter
+}
+
+// Mark returns the update moving a document to the trash, it makes a
+// new version of the document
+func Mark() bson.M {
+	return version.Bump(bson.M{"$set": bson.M{Field: time.Now()}})
+}
+
+// List responds with the documents of the collection in the trash,
+// result must be a pointer to a slice of the collection model
+func List(c echo.Context, collection string, result interface{}) error {
+	query, err := utils.NewListQuery(c, listFields)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	Deleted(query.Filter)
+
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	total, err := repo.Count(query.Filter)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	if err = repo.FindAll(query.Selector(), query.FindOptions(), result); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+
+	return c.JSON(http.StatusOK, utils.NewPaginatedSuccess(result, query.Paginate(total, result)))
+}
+
+// Restore takes the document of the collection with the ID of the
+// request out of the trash
+func Restore(c echo.Context, collection string) error {
+	id, err := primitive.ObjectIDFromHex(c.Param("id"))
+
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Invalid ID")
+	}
+
+	repo, err := repository.Open(collection)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "Fail to connect DB")
+	}
+
+	selector := Deleted(bson.M{"_id": id})
+	update := version.Bump(bson.M{"$unset": bson.M{Field: ""}})
+
+	before := audits.Snapshot(collection, id)
+	result, err := repo.Update(selector, update)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err)
+	}
+
+	if result.MatchedCount == 0 {
+		return echo.NewHTTPError(http.StatusNotFound, "Record is not in the trash")
+	}
+
+	audits.Record(c, audits.ActionRestore, collection, id, before)
+
+	return c.JSON(http.StatusOK, utils.NewSuccess(result, "Restored"))
+}
+
+// Purge deletes for good the documents kept in the trash longer than the
+// retention period, the files they hold are removed only then. A document
+// that fails to be purged is logged and left to the next run, the others
+// are purged anyway.
+func Purge(logger echo.Logger) {
+	purgersMutex.Lock()
+	defer purgersMutex.Unlock()
+
+	expired := bson.M{Field: bson.M{"$lte": time.Now().Add(-Retention())}}
+
+	for collection, purge := range purgers {
+		repo, err := repository.Open(collection)
+		if err != nil {
+			logger.Errorf("trash %s: %s", collection, err)
+			continue
+		}
+
+		documents := make([]struct {
+			ID primitive.ObjectID `bson:"_id"`
+		}, 0)
+		if err = repo.FindAll(expired, options.Find().SetProjection(bson.M{"_id": 1}), &documents); err != nil {
+			logger.Errorf("trash %s: %s", collection, err)
+			continue
+		}
+
+		for _, document := range documents {
+			if purge != nil {
+				if err = purge(document.ID); err != nil {
+					logger.Errorf("trash %s %s: %s", collection, document.ID.Hex(), err)
+					continue
+				}
+			}
+
+			if _, err = repo.Delete(Deleted(bson.M{"_id": document.ID})); err != nil {
+				logger.Errorf("trash %s %s: %s", collection, document.ID.Hex(), err)
+			}
+		}
+	}
+}
+
+// Schedule purges the trash now and every hour in the background
+func Schedule(logger echo.Logger) {
+	go func() {
+		for {
+			Purge(logger)
+
+			time.Sleep(purgeInterval)
+		}
+	}()
+}
@@ -0,0 +1,52 @@
+package trash
+
+import (
+	"errors"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestPurgeGoesPastFailures(t *testing.T) {
+	repository.Use(repository.NewMemory())
+	repo, _ := repository.Open("notes")
+
+	expired := time.Now().Add(-2 * Retention())
+	broken, purged := primitive.NewObjectID(), primitive.NewObjectID()
+	for _, id := range []primitive.ObjectID{broken, purged} {
+		if err := repo.Insert(bson.M{"_id": id, Field: expired}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	kept := primitive.NewObjectID()
+	if err := repo.Insert(bson.M{"_id": kept, Field: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+
+	Register("notes", func(id primitive.ObjectID) error {
+		if id == broken {
+			return errors.New("file is locked")
+		}
+		return nil
+	})
+	defer func() {
+		purgersMutex.Lock()
+		delete(purgers, "notes")
+		purgersMutex.Unlock()
+	}()
+
+	logger := echo.New().Logger
+	logger.SetOutput(ioutil.Discard)
+	Purge(logger)
+
+	for id, want := range map[primitive.ObjectID]int64{broken: 1, purged: 0, kept: 1} {
+		if count, err := repo.Count(bson.M{"_id": id}); err != nil || count != want {
+			t.Errorf("documents %s = %d %v, want %d", id.Hex(), count, err, want)
+		}
+	}
+}
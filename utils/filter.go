@@ -0,0 +1,165 @@
+package utils
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	objectIDType = reflect.TypeOf(primitive.ObjectID{})
+)
+
+// fieldType returns the type of the field of the model stored under the
+// bson name, nil when the model has no such field
+func fieldType(model reflect.Type, name string) reflect.Type {
+	for model.Kind() == reflect.Ptr {
+		model = model.Elem()
+	}
+	if model.Kind() != reflect.Struct {
+		return nil
+	}
+
+	for i := 0; i < model.NumField(); i++ {
+		field := model.Field(i)
+		tag := strings.Split(field.Tag.Get("bson"), ",")
+
+		/* embedded structs keep their fields at the top of the document */
+		if field.Anonymous && (tag[0] == "" || hasOption(tag, "inline")) {
+			if t := fieldType(field.Type, name); t != nil {
+				return t
+			}
+			continue
+		}
+
+		key := tag[0]
+		if key == "" {
+			key = strings.ToLower(field.Name)
+		}
+		if key == name {
+			return field.Type
+		}
+	}
+
+	return nil
+}
+
+func hasOption(tag []string, option string) bool {
+	for _, value := range tag[1:] {
+		if value == option {
+			return true
+		}
+	}
+
+	return false
+}
+
+// condition converts the values of the filter on the key to the type of
+// the field, a date filters a time field on the whole day
+func condition(key string, values []string, t reflect.Type) (interface{}, error) {
+	for t != nil && (t.Kind() == reflect.Ptr || (t.Kind() == reflect.Slice && t.Elem().Kind() != reflect.Uint8)) {
+		// a list field matches the documents holding one of the values
+		t = t.Elem()
+	}
+
+	if t == timeType {
+		return timeCondition(key, values)
+	}
+
+	converted := make(bson.A, 0, len(values))
+	for _, value := range values {
+		v, err := convert(value, t)
+		if err != nil {
+			return nil, fmt.Errorf("%s %s", key, err.Error())
+		}
+		converted = append(converted, v)
+	}
+
+	if len(converted) > 1 {
+		return bson.M{"$in": converted}, nil
+	}
+
+	return converted[0], nil
+}
+
+// convert parses the value of the query string as the type, it stays a
+// string for the fields of unknown type
+func convert(value string, t reflect.Type) (interface{}, error) {
+	if t == nil {
+		return value, nil
+	}
+	if t == objectIDType {
+		id, err := primitive.ObjectIDFromHex(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be an ID")
+		}
+		return id, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		v, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return v, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		v, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return v, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		v, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a positive number")
+		}
+		return int64(v), nil
+	case reflect.Float32, reflect.Float64:
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("must be a number")
+		}
+		return v, nil
+	case reflect.String:
+		return value, nil
+	}
+
+	return nil, fmt.Errorf("can not be filtered")
+}
+
+// timeCondition matches a time, or every time of the day of a date
+func timeCondition(key string, values []string) (interface{}, error) {
+	if len(values) > 1 {
+		return nil, fmt.Errorf("%s accepts a single date", key)
+	}
+
+	date, wholeDay, err := ParseDate(values[0])
+	if err != nil {
+		return nil, fmt.Errorf("%s must be a date (YYYY-MM-DD) or RFC3339 time", key)
+	}
+
+	if wholeDay {
+		return bson.M{"$gte": date, "$lt": date.AddDate(0, 0, 1)}, nil
+	}
+
+	return date, nil
+}
+
+// ParseDate reads a date (YYYY-MM-DD), which stands for the whole day, or
+// an RFC3339 time
+func ParseDate(value string) (date time.Time, wholeDay bool, err error) {
+	if date, err = time.Parse("2006-01-02", value); err == nil {
+		return date, true, nil
+	}
+
+	date, err = time.Parse(time.RFC3339, value)
+
+	return date, false, err
+}
@@ -0,0 +1,32 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FormList returns the values of a form field sent once per value or as
+// a comma separated list, ok is false when the form does not have the
+// field so the current values are kept. An empty value clears the list.
+func FormList(c echo.Context, name string) ([]string, bool) {
+	if _, err := c.FormParams(); err != nil {
+		return nil, false
+	}
+
+	raw, ok := c.Request().PostForm[name]
+	if !ok {
+		return nil, false
+	}
+
+	values := make([]string, 0)
+	for _, value := range raw {
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+	}
+
+	return values, true
+}
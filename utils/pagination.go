@@ -0,0 +1,335 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+	DefaultSort  = "-createdAt"
+)
+
+// Fields maps the field names accepted in the query string to the
+// document fields stored in MongoDB. Only whitelisted fields can be used
+// for sorting and filtering.
+type Fields map[string]string
+
+// timestamps can be sorted on every collection but not filtered
+var timestamps = Fields{
+	"createdAt": "created_at",
+	"updatedAt": "updated_at",
+}
+
+// ListQuery holds the paging, sorting and filtering options of a list request
+type ListQuery struct {
+	Page   int64
+	Limit  int64
+	Sort   bson.D
+	Filter bson.M
+
+	// position the page starts from when the request has a cursor, the
+	// page number is ignored then
+	cursor *cursor
+}
+
+// cursor is the position of a document in the list, by the values of the
+// sort fields. The page is made of the documents after it, or before it.
+type cursor struct {
+	Values bson.M `bson:"v"`
+	Before bool   `bson:"b,omitempty"`
+}
+
+// Pagination is returned as meta data of every list response
+type Pagination struct {
+	Total int64  `json:"total"`
+	Page  int64  `json:"page,omitempty"`
+	Limit int64  `json:"limit"`
+	Next  *int64 `json:"next"`
+	Prev  *int64 `json:"prev"`
+	// cursors of the pages around this one, see ?cursor=
+	NextCursor string `json:"nextCursor,omitempty"`
+	PrevCursor string `json:"prevCursor,omitempty"`
+}
+
+// NewListQuery reads ?page=, ?limit=, ?sort=, ?cursor= and the whitelisted
+// field filters from the request. Sort accepts a comma separated list of
+// fields, a leading "-" sorts descending (e.g. ?sort=-createdAt,title).
+// The filters are converted to the type of the field in the model of the
+// documents, a date filters a time on the whole day.
+func NewListQuery(c echo.Context, filters Fields, model ...interface{}) (*ListQuery, error) {
+	query := &ListQuery{
+		Page:   1,
+		Limit:  DefaultLimit,
+		Filter: bson.M{},
+	}
+
+	if page := c.QueryParam("page"); page != "" {
+		value, err := strconv.ParseInt(page, 10, 64)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("page must be a positive number")
+		}
+		query.Page = value
+	}
+
+	if limit := c.QueryParam("limit"); limit != "" {
+		value, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || value < 1 {
+			return nil, fmt.Errorf("limit must be a positive number")
+		}
+		if value > MaxLimit {
+			value = MaxLimit
+		}
+		query.Limit = value
+	}
+
+	sort := c.QueryParam("sort")
+	if sort == "" {
+		sort = DefaultSort
+	}
+
+	for _, key := range strings.Split(sort, ",") {
+		key = strings.TrimSpace(key)
+		order := 1
+		if strings.HasPrefix(key, "-") {
+			key = strings.TrimPrefix(key, "-")
+			order = -1
+		}
+
+		field, ok := filters[key]
+		if !ok {
+			field, ok = timestamps[key]
+		}
+		if !ok {
+			return nil, fmt.Errorf("sort by %s is not allowed", key)
+		}
+		query.Sort = append(query.Sort, bson.E{Key: field, Value: order})
+	}
+
+	if value := c.QueryParam("cursor"); value != "" {
+		position, err := decodeCursor(value)
+		if err != nil {
+			return nil, err
+		}
+		query.Page = 1
+		query.cursor = position
+
+		/* a cursor holds the values of the sort it was taken with */
+		keys := query.keys()
+		if len(keys) != len(position.Values) {
+			return nil, fmt.Errorf("cursor does not match the sort")
+		}
+		for _, key := range keys {
+			if _, ok := position.Values[key.Key]; !ok {
+				return nil, fmt.Errorf("cursor does not match the sort")
+			}
+		}
+	}
+
+	for key, values := range c.QueryParams() {
+		field, ok := filters[key]
+		if !ok || len(values) == 0 || values[0] == "" {
+			continue
+		}
+
+		var t reflect.Type
+		if len(model) > 0 {
+			t = fieldType(reflect.TypeOf(model[0]), field)
+		}
+
+		value, err := condition(key, values, t)
+		if err != nil {
+			return nil, err
+		}
+		query.Filter[field] = value
+	}
+
+	return query, nil
+}
+
+// HasCursor reports whether the request asked for the page of a cursor
+func (q *ListQuery) HasCursor() bool {
+	return q.cursor != nil
+}
+
+// Where narrows the filter of the request with the given condition,
+// the condition always applies whatever the request asked for
+func (q *ListQuery) Where(condition bson.M) {
+	q.Filter = bson.M{"$and": bson.A{q.Filter, condition}}
+}
+
+// Selector returns the filter of the documents of the page, the ones of
+// the list after or before the cursor when the request has one
+func (q *ListQuery) Selector() bson.M {
+	if q.cursor == nil {
+		return q.Filter
+	}
+
+	/* the documents after the cursor come later in one of the sort fields,
+	   being equal in the fields before it */
+	keys := q.keys()
+	or := make(bson.A, 0, len(keys))
+	for i, key := range keys {
+		operator := "$gt"
+		if (key.Value.(int) < 0) != q.cursor.Before {
+			operator = "$lt"
+		}
+
+		and := bson.M{key.Key: bson.M{operator: q.cursor.Values[key.Key]}}
+		for _, previous := range keys[:i] {
+			and[previous.Key] = q.cursor.Values[previous.Key]
+		}
+		or = append(or, and)
+	}
+
+	return bson.M{"$and": bson.A{q.Filter, bson.M{"$or": or}}}
+}
+
+// keys returns the sort fields, the ID last so the order is the same on
+// every request
+func (q *ListQuery) keys() bson.D {
+	keys := make(bson.D, 0, len(q.Sort)+1)
+	for _, key := range q.Sort {
+		if key.Key == "_id" {
+			return append(keys, key)
+		}
+		keys = append(keys, key)
+	}
+
+	return append(keys, bson.E{Key: "_id", Value: 1})
+}
+
+// FindOptions converts the query into mongo find options
+func (q *ListQuery) FindOptions() *options.FindOptions {
+	opts := options.Find().SetLimit(q.Limit)
+
+	if q.cursor == nil {
+		return opts.SetSort(q.keys()).SetSkip((q.Page - 1) * q.Limit)
+	}
+
+	/* the page before the cursor is read backward from it */
+	sort := q.keys()
+	if q.cursor.Before {
+		for i := range sort {
+			sort[i].Value = -sort[i].Value.(int)
+		}
+	}
+
+	return opts.SetSort(sort)
+}
+
+// Paginate builds the pagination meta data for the given total of records
+// and the documents of the page, which are put back in the order of the
+// list when the page is the one before a cursor
+func (q *ListQuery) Paginate(total int64, documents interface{}) *Pagination {
+	pagination := &Pagination{
+		Total: total,
+		Limit: q.Limit,
+	}
+
+	items := reflect.ValueOf(documents)
+	for items.Kind() == reflect.Ptr {
+		items = items.Elem()
+	}
+	if items.Kind() != reflect.Slice {
+		items = reflect.ValueOf([]interface{}{})
+	}
+	count := int64(items.Len())
+
+	if q.cursor == nil {
+		pagination.Page = q.Page
+
+		if q.Page*q.Limit < total {
+			next := q.Page + 1
+			pagination.Next = &next
+			pagination.NextCursor = q.encodeCursor(items, count-1, false)
+		}
+
+		if q.Page > 1 {
+			prev := q.Page - 1
+			pagination.Prev = &prev
+			pagination.PrevCursor = q.encodeCursor(items, 0, true)
+		}
+
+		return pagination
+	}
+
+	if q.cursor.Before {
+		swap := reflect.Swapper(items.Interface())
+		for i, j := 0, items.Len()-1; i < j; i, j = i+1, j-1 {
+			swap(i, j)
+		}
+	}
+
+	/* a full page may have more documents past it, the side of the cursor
+	   always has the documents it was taken from */
+	if count == q.Limit || q.cursor.Before {
+		pagination.NextCursor = q.encodeCursor(items, count-1, false)
+	}
+	if count == q.Limit || !q.cursor.Before {
+		pagination.PrevCursor = q.encodeCursor(items, 0, true)
+	}
+
+	return pagination
+}
+
+// encodeCursor returns the cursor of the document at the index of the
+// page, empty when there is none
+func (q *ListQuery) encodeCursor(items reflect.Value, index int64, before bool) string {
+	if index < 0 || index >= int64(items.Len()) {
+		return ""
+	}
+
+	raw, err := bson.Marshal(items.Index(int(index)).Interface())
+	if err != nil {
+		return ""
+	}
+	document := bson.M{}
+	if err = bson.Unmarshal(raw, &document); err != nil {
+		return ""
+	}
+
+	position := cursor{Values: bson.M{}, Before: before}
+	for _, key := range q.keys() {
+		position.Values[key.Key] = document[key.Key]
+	}
+
+	raw, err = bson.Marshal(position)
+	if err != nil {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(value string) (*cursor, error) {
+	invalid := fmt.Errorf("cursor is not valid")
+
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, invalid
+	}
+
+	position := new(cursor)
+	if err = bson.Unmarshal(raw, position); err != nil || position.Values == nil {
+		return nil, invalid
+	}
+
+	/* the values end up in the filter, they must not bring operators */
+	for _, v := range position.Values {
+		switch v.(type) {
+		case bson.M, bson.D, bson.A:
+			return nil, invalid
+		}
+	}
+
+	return position, nil
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"golang.org/x/time/rate"
+)
+
+// RateLimit limits the requests sent from an IP to a number per minute,
+// read from the environment variable or else the fallback. Requests over
+// the limit get 429 with the message. The IP is c.RealIP(), given by the
+// IPExtractor of the server, see TRUSTED_PROXIES.
+func RateLimit(variable string, fallback int, message string) echo.MiddlewareFunc {
+	limit, err := strconv.Atoi(os.Getenv(variable))
+	if err != nil || limit < 1 {
+		limit = fallback
+	}
+
+	return middleware.RateLimiterWithConfig(middleware.RateLimiterConfig{
+		Store: middleware.NewRateLimiterMemoryStoreWithConfig(middleware.RateLimiterMemoryStoreConfig{
+			Rate:      rate.Limit(float64(limit) / time.Minute.Seconds()),
+			Burst:     limit,
+			ExpiresIn: time.Minute,
+		}),
+		ErrorHandler: func(c echo.Context, err error) error {
+			return echo.NewHTTPError(http.StatusForbidden, "Fail to identify the sender")
+		},
+		DenyHandler: func(c echo.Context, identifier string, err error) error {
+			return echo.NewHTTPError(http.StatusTooManyRequests, message)
+		},
+	})
+}
@@ -1,9 +1,12 @@
 package utils
 
 type HttpError struct {
-	Status  bool   `json:"status" default:"false"`
-	Code    int    `json:"code" example:"500"`
-	Message string `json:"message"`
+	Status  bool         `json:"status" default:"false"`
+	Code    int          `json:"code" example:"500"`
+	Message string       `json:"message"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Version int64        `json:"version,omitempty" example:"3"`
+	Current interface{}  `json:"current,omitempty" swaggertype:"object"`
 }
 
 type HttpSuccess struct {
@@ -11,6 +14,7 @@ type HttpSuccess struct {
 	Code    int         `json:"code" example:"200"`
 	Message string      `json:"message"`
 	Data    interface{} `json:"data" swaggertype:"object"`
+	Meta    *Pagination `json:"meta,omitempty"`
 }
 
 // Warp the error info in a object
@@ -25,6 +29,14 @@ func NewSuccess(data interface{}, message string) *HttpSuccess {
 	}
 }
 
+// Warp the paginated list in a object
+func NewPaginatedSuccess(data interface{}, pagination *Pagination) *HttpSuccess {
+	success := NewSuccess(data, "")
+	success.Meta = pagination
+
+	return success
+}
+
 // Warp the error info in a object
 func NewError(code int, message string) *HttpError {
 	formattedMessage := GetMessage(message)
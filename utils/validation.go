@@ -0,0 +1,71 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/labstack/echo/v4"
+)
+
+// FieldError is a field of the request failing the validation, Code is the
+// failing rule like required or email so clients can tell them apart
+type FieldError struct {
+	Field   string `json:"field" example:"title"`
+	Code    string `json:"code" example:"required"`
+	Message string `json:"message" example:"title is required"`
+}
+
+// ValidationError lists every field of the request failing the validation
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// NewValidationError returns the error of the failing fields
+func NewValidationError(fields ...FieldError) *ValidationError {
+	return &ValidationError{Fields: fields}
+}
+
+// Error makes it compatible with `error` interface.
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.Message)
+	}
+
+	return strings.Join(messages, ", ")
+}
+
+// Validate checks the struct against its validate tags, the errors of the
+// except fields are left out, like an image that is checked on its own
+// since it may be picked from the media library
+func Validate(c echo.Context, i interface{}, except ...string) error {
+	err := c.Validate(i)
+
+	errs, ok := err.(validator.ValidationErrors)
+	if !ok || len(except) == 0 {
+		return err
+	}
+
+	invalid := make(validator.ValidationErrors, 0, len(errs))
+	for _, fieldErr := range errs {
+		if !excepted(fieldErr, except) {
+			invalid = append(invalid, fieldErr)
+		}
+	}
+
+	if len(invalid) == 0 {
+		return nil
+	}
+
+	return invalid
+}
+
+func excepted(fieldErr validator.FieldError, except []string) bool {
+	for _, name := range except {
+		if fieldErr.Field() == name || strings.EqualFold(fieldErr.StructField(), name) {
+			return true
+		}
+	}
+
+	return false
+}
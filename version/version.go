@@ -0,0 +1,140 @@
+package version
+
+import (
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/muhammadardie/echo-cms/repository"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Field holds the version of a document, it goes up by one on every change
+const Field = "version"
+
+const (
+	headerETag    = "ETag"
+	headerIfMatch = "If-Match"
+)
+
+// Conflict is returned when a change is based on an outdated version of
+// the document, it carries the current one so the client can merge
+type Conflict struct {
+	Version int64
+	Current interface{}
+}
+
+// Error makes it compatible with `error` interface.
+func (e *Conflict) Error() string {
+	return "Record has been changed by someone else, merge your changes with version " + strconv.FormatInt(e.Version, 10)
+}
+
+// Submitted returns the version the change of the request is based on,
+// from the If-Match header or else from the version of the body. Zero
+// means none was sent, or If-Match is *, and the change is not checked.
+func Submitted(c echo.Context, body int64) (int64, error) {
+	header := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	if header == "" {
+		return body, nil
+	}
+	if header == "*" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || value < 1 {
+		return 0, echo.NewHTTPError(http.StatusBadRequest, "If-Match must be the ETag of the record")
+	}
+
+	return value, nil
+}
+
+// Check returns a Conflict with the current document when a version was
+// submitted and it is not the current one
+func Check(submitted int64, current int64, record interface{}) error {
+	if submitted != 0 && submitted != current {
+		return &Conflict{Version: current, Current: record}
+	}
+
+	return nil
+}
+
+// Selector narrows the selector to the document still at the version,
+// a concurrent change makes the update match nothing
+func Selector(selector bson.M, current int64) bson.M {
+	if current == 0 {
+		// documents written before they had a version
+		return bson.M{"$and": bson.A{selector, bson.M{Field: bson.M{"$in": bson.A{nil, 0}}}}}
+	}
+
+	return bson.M{"$and": bson.A{selector, bson.M{Field: current}}}
+}
+
+// Bump adds the increment of the version to the update
+func Bump(update bson.M) bson.M {
+	update["$inc"] = bson.M{Field: 1}
+
+	return update
+}
+
+// Reload returns the Conflict of a document changed between the time it
+// was read and the update, record receives the current document
+func Reload(repo repository.Repository, id primitive.ObjectID, record interface{}) error {
+	if err := repo.FindByID(id, record); err != nil {
+		return echo.NewHTTPError(http.StatusNotFound, "Record not found")
+	}
+
+	return &Conflict{Version: Of(record), Current: record}
+}
+
+// Of returns the version of a document, a bson.M or a pointer to a model
+func Of(record interface{}) int64 {
+	if document, ok := record.(bson.M); ok {
+		return number(document[Field])
+	}
+	if document, ok := record.(*bson.M); ok {
+		return number((*document)[Field])
+	}
+
+	value := reflect.Indirect(reflect.ValueOf(record))
+	if value.Kind() != reflect.Struct {
+		return 0
+	}
+
+	for i := 0; i < value.NumField(); i++ {
+		if strings.Split(value.Type().Field(i).Tag.Get("bson"), ",")[0] == Field {
+			return number(value.Field(i).Interface())
+		}
+	}
+
+	return 0
+}
+
+func number(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case int:
+		return int64(v)
+	case float64:
+		return int64(v)
+	}
+
+	return 0
+}
+
+// ETag returns the entity tag of a version
+func ETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// SetETag tells the client the version of the document, to be sent back
+// in If-Match or as the version of the next change
+func SetETag(c echo.Context, version int64) {
+	c.Response().Header().Set(headerETag, ETag(version))
+}